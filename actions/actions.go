@@ -0,0 +1,217 @@
+// Package actions shows GitHub Actions workflow runs on the dashboard.
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+const (
+	defaultAPIURL = "https://api.github.com"
+	// pageSize is GitHub's largest page. Only the first page of workflow
+	// runs is read, the most recent runs being all the dashboard shows.
+	pageSize = 100
+	// slugPrefix keeps the slugs of repositories apart from CircleCI's
+	// github/owner/repo ones.
+	slugPrefix = "github-actions/"
+)
+
+type Config struct {
+	APIURL string
+	Token  string
+	// Repos lists repositories as owner/repo, or owner/* for all of an
+	// owner's repositories.
+	Repos []string
+}
+
+// Client is a Provider of the workflow runs of GitHub repositories.
+type Client struct {
+	Config *Config
+	Client *resty.Client
+}
+
+func NewClient(config *Config) (*Client, error) {
+	if config.APIURL == "" {
+		config.APIURL = defaultAPIURL
+	}
+	if len(config.Repos) == 0 {
+		return nil, fmt.Errorf("Must list at least one repository")
+	}
+	client := resty.New()
+	if config.Token != "" {
+		client.SetAuthToken(config.Token)
+	}
+	return &Client{Client: client, Config: config}, nil
+}
+
+func (c *Client) get(urlPath string, result interface{}) error {
+	resp, err := c.Client.R().
+		SetHeader("Accept", "application/vnd.github.v3+json").
+		Get(fmt.Sprintf("%s/%s", strings.TrimSuffix(c.Config.APIURL, "/"), urlPath))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() > 299 {
+		return fmt.Errorf("GitHub returned %s for %s", resp.Status(), urlPath)
+	}
+	return json.Unmarshal(resp.Body(), result)
+}
+
+type repository struct {
+	FullName string `json:"full_name"`
+	Archived bool   `json:"archived"`
+}
+
+// ownerRepos lists the repositories of an organisation, or of a user if
+// there is no such organisation.
+func (c *Client) ownerRepos(owner string) ([]string, error) {
+	base := fmt.Sprintf("orgs/%s/repos", owner)
+	var names []string
+	for page := 1; ; page++ {
+		var repos []repository
+		err := c.get(fmt.Sprintf("%s?per_page=%d&page=%d", base, pageSize, page), &repos)
+		if err != nil && page == 1 {
+			base = fmt.Sprintf("users/%s/repos", owner)
+			if c.get(fmt.Sprintf("%s?per_page=%d&page=%d", base, pageSize, page), &repos) == nil {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range repos {
+			if !repo.Archived {
+				names = append(names, repo.FullName)
+			}
+		}
+		if len(repos) < pageSize {
+			return names, nil
+		}
+	}
+}
+
+func (c *Client) Projects() ([]dashboard.Project, error) {
+	var (
+		projects []dashboard.Project
+		seen     = map[string]bool{}
+	)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			projects = append(projects, dashboard.Project{Name: name, Slug: slugPrefix + name})
+		}
+	}
+	for _, repo := range c.Config.Repos {
+		parts := strings.SplitN(repo, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid repository %q, must be owner/repo", repo)
+		}
+		if !strings.ContainsAny(parts[1], "*?[") {
+			add(repo)
+			continue
+		}
+		names, err := c.ownerRepos(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if matched, _ := path.Match(repo, name); matched {
+				add(name)
+			}
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+type workflowRun struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	HeadBranch string    `json:"head_branch"`
+	HeadSHA    string    `json:"head_sha"`
+	Event      string    `json:"event"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HTMLURL    string    `json:"html_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type workflowRuns struct {
+	WorkflowRuns []workflowRun `json:"workflow_runs"`
+}
+
+// state maps GitHub's status and conclusion onto the dashboard's states. Runs
+// that were skipped have no state, and are left off.
+func (r workflowRun) state() dashboard.State {
+	switch r.Status {
+	case "completed":
+	case "waiting", "action_required":
+		return dashboard.StateOnHold
+	default:
+		return dashboard.StateRunning
+	}
+	switch r.Conclusion {
+	case "success", "neutral":
+		return dashboard.StateSuccess
+	case "failure", "timed_out":
+		return dashboard.StateFailed
+	case "startup_failure":
+		return dashboard.StateError
+	case "cancelled":
+		return dashboard.StateCanceled
+	case "action_required":
+		return dashboard.StateOnHold
+	case "skipped", "stale":
+		return ""
+	}
+	return dashboard.StateUnknown
+}
+
+func (r workflowRun) trigger() string {
+	if r.Event == "schedule" {
+		return circleci.TriggerSourceScheduled
+	}
+	return circleci.TriggerSourcePush
+}
+
+func (c *Client) WorkflowRuns(project dashboard.Project) (dashboard.WorkflowRuns, error) {
+	var runs workflowRuns
+	if err := c.get(fmt.Sprintf("repos/%s/actions/runs?per_page=%d", project.Name, pageSize), &runs); err != nil {
+		return nil, err
+	}
+	var neutral dashboard.WorkflowRuns
+	for _, run := range runs.WorkflowRuns {
+		state := run.state()
+		if state == "" {
+			continue
+		}
+		neutralRun := dashboard.WorkflowRun{
+			ID:         fmt.Sprint(run.ID),
+			Name:       run.Name,
+			PipelineID: run.HeadSHA,
+			Branch:     run.HeadBranch,
+			Trigger:    run.trigger(),
+			Revision:   run.HeadSHA,
+			State:      state,
+			Link:       run.HTMLURL,
+			CreatedAt:  run.CreatedAt,
+		}
+		if run.Status == "completed" {
+			stoppedAt := run.UpdatedAt
+			neutralRun.StoppedAt = &stoppedAt
+		}
+		neutral = append(neutral, neutralRun)
+	}
+	sort.SliceStable(neutral, func(i, j int) bool { return neutral[i].CreatedAt.After(neutral[j].CreatedAt) })
+	return neutral, nil
+}
@@ -0,0 +1,140 @@
+package actions_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/actions"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+const exampleRuns = `{"total_count": 5, "workflow_runs": [
+	{"id": 5, "name": "CI", "head_branch": "main", "head_sha": "ccc", "event": "push", "status": "in_progress", "conclusion": null, "html_url": "https://github.com/myorg/example/actions/runs/5", "created_at": "2021-03-01T12:00:00Z", "updated_at": "2021-03-01T12:01:00Z"},
+	{"id": 4, "name": "Lint", "head_branch": "main", "head_sha": "bbb", "event": "push", "status": "completed", "conclusion": "skipped", "html_url": "https://github.com/myorg/example/actions/runs/4", "created_at": "2021-03-01T11:30:00Z", "updated_at": "2021-03-01T11:30:00Z"},
+	{"id": 3, "name": "CI", "head_branch": "main", "head_sha": "bbb", "event": "push", "status": "completed", "conclusion": "failure", "html_url": "https://github.com/myorg/example/actions/runs/3", "created_at": "2021-03-01T11:00:00Z", "updated_at": "2021-03-01T11:10:00Z"},
+	{"id": 2, "name": "Nightly", "head_branch": "main", "head_sha": "aaa", "event": "schedule", "status": "completed", "conclusion": "success", "html_url": "https://github.com/myorg/example/actions/runs/2", "created_at": "2021-03-01T02:00:00Z", "updated_at": "2021-03-01T02:30:00Z"},
+	{"id": 1, "name": "CI", "head_branch": "feature", "head_sha": "fff", "event": "pull_request", "status": "completed", "conclusion": "cancelled", "html_url": "https://github.com/myorg/example/actions/runs/1", "created_at": "2021-02-28T09:00:00Z", "updated_at": "2021-02-28T09:01:00Z"}
+]}`
+
+var _ = Describe("Actions", func() {
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		client   *actions.Client
+		repos    []string
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		repos = []string{"myorg/example"}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			switch r.URL.Path {
+			case "/repos/myorg/example/actions/runs":
+				fmt.Fprint(w, exampleRuns)
+			case "/orgs/myorg/repos":
+				fmt.Fprint(w, `[{"full_name": "myorg/example"}, {"full_name": "myorg/other"}, {"full_name": "myorg/old", "archived": true}]`)
+			case "/users/someone/repos":
+				fmt.Fprint(w, `[{"full_name": "someone/dotfiles"}]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		client, err = actions.NewClient(&actions.Config{APIURL: server.URL, Token: "secret", Repos: repos})
+		Ω(err).Should(BeNil())
+	})
+
+	Describe("#NewClient", func() {
+		It("needs repositories", func() {
+			_, err := actions.NewClient(&actions.Config{})
+			Ω(err).Should(MatchError("Must list at least one repository"))
+		})
+	})
+
+	Describe("#Projects", func() {
+		It("lists the configured repositories", func() {
+			projects, err := client.Projects()
+			Ω(err).Should(BeNil())
+			Ω(projects).Should(Equal([]dashboard.Project{{Name: "myorg/example", Slug: "github-actions/myorg/example"}}))
+			Ω(requests).Should(BeEmpty())
+		})
+
+		Context("with an organisation's repositories", func() {
+			BeforeEach(func() {
+				repos = []string{"myorg/*", "myorg/example"}
+			})
+
+			It("lists the unarchived ones once each", func() {
+				projects, err := client.Projects()
+				Ω(err).Should(BeNil())
+				Ω(projects).Should(HaveLen(2))
+				Ω(projects[0].Name).Should(Equal("myorg/example"))
+				Ω(projects[1].Name).Should(Equal("myorg/other"))
+				Ω(requests[0].Header.Get("Authorization")).Should(Equal("Bearer secret"))
+			})
+		})
+
+		Context("with a user's repositories", func() {
+			BeforeEach(func() {
+				repos = []string{"someone/*"}
+			})
+
+			It("falls back from organisations to users", func() {
+				projects, err := client.Projects()
+				Ω(err).Should(BeNil())
+				Ω(projects).Should(HaveLen(1))
+				Ω(projects[0].Name).Should(Equal("someone/dotfiles"))
+			})
+		})
+	})
+
+	Describe("#WorkflowRuns", func() {
+		It("maps GitHub's runs onto workflow runs, leaving out skipped ones", func() {
+			runs, err := client.WorkflowRuns(dashboard.Project{Name: "myorg/example"})
+			Ω(err).Should(BeNil())
+			Ω(runs).Should(HaveLen(4))
+			Ω(runs[0].Name).Should(Equal("CI"))
+			Ω(runs[0].State).Should(Equal(dashboard.StateRunning))
+			Ω(runs[0].StoppedAt).Should(BeNil())
+			Ω(runs[1].State).Should(Equal(dashboard.StateFailed))
+			Ω(runs[1].Revision).Should(Equal("bbb"))
+			Ω(runs[1].StoppedAt).ShouldNot(BeNil())
+			Ω(runs[2].Trigger).Should(Equal("scheduled"))
+			Ω(runs[3].State).Should(Equal(dashboard.StateCanceled))
+		})
+
+		It("reports errors from GitHub", func() {
+			_, err := client.WorkflowRuns(dashboard.Project{Name: "myorg/missing"})
+			Ω(err).Should(MatchError("GitHub returned 404 Not Found for repos/myorg/missing/actions/runs?per_page=100"))
+		})
+	})
+
+	Describe("as a provider", func() {
+		It("builds a tile per workflow and branch from the latest runs", func() {
+			monitors, err := dashboard.BuildProvider(context.Background(), client, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(3))
+			Ω(monitors[0].Workflow).Should(Equal("CI"))
+			Ω(monitors[0].Branch).Should(Equal("feature"))
+			Ω(monitors[0].Status).Should(Equal("canceled unknown"))
+			Ω(monitors[1].Branch).Should(Equal("main"))
+			Ω(monitors[1].Status).Should(Equal("running failed"))
+			Ω(monitors[1].Link).Should(Equal("https://github.com/myorg/example/actions/runs/5"))
+			Ω(monitors[2].Workflow).Should(Equal("Nightly"))
+			Ω(monitors[2].Status).Should(Equal("success"))
+		})
+	})
+})
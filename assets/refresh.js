@@ -1,4 +1,5 @@
 window.refresh_interval = window.refresh_interval || 30;
+var error_retry_interval = Math.max(refresh_interval, 5);
 
 var styles = document.createElement("style");
 document.head.appendChild(styles);
@@ -55,6 +56,11 @@ var scaleboxes = function () {
   }, 10);
 };
 
+var nextRefresh = function (doc) {
+  var el = doc.getElementById('countdown');
+  var seconds = el ? parseInt(el.innerText, 10) : NaN;
+  return isNaN(seconds) || seconds < 1 ? refresh_interval : seconds;
+};
 var onerror = function () {
   document.body.innerHTML = '<div class="time">' + Date() + ' (<span id="countdown">' + refresh_interval + '</span>)</div><h1>ERROR</h1>';
   document.head.setAttribute("rel", "error");
@@ -69,24 +75,43 @@ var onsuccess = function (request) {
 
   scaleboxes()
 };
-setInterval(function () {
+var refresh = function () {
   var request = new XMLHttpRequest();
   request.open('GET', location.href, true);
   request.onload = function () {
     if (request.status >= 200 && request.status < 400) {
       onsuccess(request);
+      scheduleRefresh(nextRefresh(document));
     } else {
       onerror();
+      scheduleRefresh(error_retry_interval);
     }
   };
-  request.onerror = onerror;
+  request.onerror = function () {
+    onerror();
+    scheduleRefresh(error_retry_interval);
+  };
   request.send();
-}, refresh_interval * 1000);
+};
+var refreshTimeout;
+var scheduleRefresh = function (seconds) {
+  clearTimeout(refreshTimeout);
+  refreshTimeout = setTimeout(refresh, seconds * 1000);
+};
+scheduleRefresh(refresh_interval);
+if (window.EventSource) {
+  new EventSource('/events').addEventListener('refresh', function () {
+    clearTimeout(refreshTimeout);
+    refresh();
+  });
+}
 setInterval(function () {
   var el = document.getElementById('countdown');
   if (el) {
     var counter = parseInt(el.innerText, 10);
-    el.innerText = counter - 1;
+    if (counter > 0) {
+      el.innerText = counter - 1;
+    }
   }
 }, 1000);
 
@@ -0,0 +1,317 @@
+// Package auth optionally restricts the dashboard to known viewers and
+// operators. Viewers can see the dashboard, operators can also change it,
+// e.g. acknowledge a red monitor.
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Role string
+
+const (
+	Viewer   Role = "viewer"
+	Operator Role = "operator"
+)
+
+const (
+	roleKey = "auth.role"
+	userKey = "auth.user"
+	// KioskCookie remembers a kiosk token from a share link, so that the
+	// page's own requests, e.g. for live updates, are let in too.
+	KioskCookie = "kiosk_token"
+	kioskParam  = "token"
+)
+
+func parseRole(role string) (Role, error) {
+	switch Role(role) {
+	case Viewer, Operator:
+		return Role(role), nil
+	}
+	return "", fmt.Errorf("Unknown role %q, must be viewer or operator", role)
+}
+
+// User is an HTTP basic user.
+type User struct {
+	Password string
+	Role     Role
+}
+
+// Config says how requests are authenticated. With nothing set everyone is an
+// operator, as before authentication existed.
+type Config struct {
+	// Tokens are bearer tokens and their roles.
+	Tokens map[string]Role
+	// Users are HTTP basic users by name.
+	Users map[string]User
+	// KioskTokens are view only tokens that can be put in a share link as
+	// ?token=, for screens that cannot log in.
+	KioskTokens []string
+	// TrustedHeader names a header holding the user, set by an auth proxy in
+	// front of the dashboard. Users listed in Operators are operators, the
+	// rest viewers.
+	TrustedHeader string
+	Operators     []string
+	// TrustedProxies are the networks TrustedHeader is accepted from, so
+	// that clients that reach the dashboard directly cannot name themselves.
+	// TrustedHeader needs them.
+	TrustedProxies []*net.IPNet
+	// ForwardSecret, shared by the replicas, signs the users of the requests
+	// they forward to the leader.
+	ForwardSecret []byte
+}
+
+// Validate checks that the config is safe to use.
+func (c Config) Validate() error {
+	if c.TrustedHeader != "" && len(c.TrustedProxies) == 0 {
+		return fmt.Errorf("A trusted header needs trusted proxies, or any client could set it")
+	}
+	return nil
+}
+
+func (c Config) Enabled() bool {
+	return len(c.Tokens) > 0 || len(c.Users) > 0 || len(c.KioskTokens) > 0 || c.TrustedHeader != ""
+}
+
+// ParseTokens reads a comma separated list of role:token pairs.
+func ParseTokens(tokens string) (map[string]Role, error) {
+	parsed := map[string]Role{}
+	for _, entry := range splitList(tokens) {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid token, must be role:token")
+		}
+		role, err := parseRole(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		parsed[parts[1]] = role
+	}
+	return parsed, nil
+}
+
+// ParseUsers reads a comma separated list of role:user:password entries.
+func ParseUsers(users string) (map[string]User, error) {
+	parsed := map[string]User{}
+	for _, entry := range splitList(users) {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("Invalid user, must be role:user:password")
+		}
+		role, err := parseRole(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		parsed[parts[1]] = User{Password: parts[2], Role: role}
+	}
+	return parsed, nil
+}
+
+// ParseNetworks reads a comma separated list of IP addresses and CIDR ranges.
+func ParseNetworks(networks string) ([]*net.IPNet, error) {
+	var parsed []*net.IPNet
+	for _, entry := range splitList(networks) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid network %q, must be an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid network %q, must be an IP address or CIDR range", entry)
+		}
+		parsed = append(parsed, network)
+	}
+	return parsed, nil
+}
+
+// ParseList reads a comma separated list, ignoring blanks.
+func ParseList(list string) []string {
+	return splitList(list)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (c Config) tokenRole(token string) (Role, bool) {
+	var (
+		role  Role
+		found bool
+	)
+	for candidate, candidateRole := range c.Tokens {
+		if equal(candidate, token) {
+			role, found = candidateRole, true
+		}
+	}
+	return role, found
+}
+
+func (c Config) kioskToken(token string) bool {
+	var found bool
+	for _, candidate := range c.KioskTokens {
+		if equal(candidate, token) {
+			found = true
+		}
+	}
+	return found
+}
+
+func (c Config) operator(user string) bool {
+	for _, operator := range c.Operators {
+		if operator == user {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy reports whether the request came straight from one of the
+// TrustedProxies, never the case when there are none.
+func (c Config) fromTrustedProxy(request *http.Request) bool {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the user and role of the request, or false if it has
+// no valid credentials. Tokens and kiosk links do not name a user.
+func (c Config) authenticate(ctx *gin.Context) (string, Role, bool) {
+	request := ctx.Request
+	if user, role, ok := c.forwarded(request); ok {
+		return user, role, true
+	}
+	if header := request.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		role, ok := c.tokenRole(strings.TrimPrefix(header, "Bearer "))
+		return "", role, ok
+	}
+	if name, password, ok := request.BasicAuth(); ok {
+		user, found := c.Users[name]
+		if !found || !equal(user.Password, password) {
+			return "", "", false
+		}
+		return name, user.Role, true
+	}
+	if c.TrustedHeader != "" && c.fromTrustedProxy(request) {
+		if user := request.Header.Get(c.TrustedHeader); user != "" {
+			if c.operator(user) {
+				return user, Operator, true
+			}
+			return user, Viewer, true
+		}
+	}
+	if token := ctx.Query(kioskParam); token != "" && c.kioskToken(token) {
+		ctx.SetCookie(KioskCookie, token, 0, "/", "", false, true)
+		return "", Viewer, true
+	}
+	if token, err := ctx.Cookie(KioskCookie); err == nil && c.kioskToken(token) {
+		return "", Viewer, true
+	}
+	return "", "", false
+}
+
+// Middleware rejects requests without valid credentials and records the user
+// and role of the rest. When authentication is not configured everyone is an
+// operator.
+func Middleware(config Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Enabled() {
+			c.Set(roleKey, Operator)
+			return
+		}
+		user, role, ok := config.authenticate(c)
+		if !ok {
+			if len(config.Users) > 0 {
+				c.Header("WWW-Authenticate", `Basic realm="CircleCI Dashboard"`)
+			}
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if user != "" {
+			c.Set(userKey, user)
+		}
+		c.Set(roleKey, role)
+	}
+}
+
+// UserOf is the signed in user Middleware found, or "" if the request does
+// not name one, e.g. because it used a token or authentication is off.
+func UserOf(c *gin.Context) string {
+	return c.GetString(userKey)
+}
+
+// RoleOf is the role Middleware gave the request, or Viewer if it did not run.
+func RoleOf(c *gin.Context) Role {
+	if role, ok := c.Get(roleKey); ok {
+		return role.(Role)
+	}
+	return Viewer
+}
+
+// CanOperate reports whether the request may use the mutating actions.
+func CanOperate(c *gin.Context) bool {
+	return RoleOf(c) == Operator
+}
+
+// SameOrigin rejects requests that a page on another site could have made a
+// browser send with the credentials it keeps, such as basic auth or the
+// proxy's cookie. Bearer tokens are never sent by browsers on their own.
+func SameOrigin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := c.Request
+		if strings.HasPrefix(request.Header.Get("Authorization"), "Bearer ") {
+			return
+		}
+		origin := request.Header.Get("Origin")
+		if origin == "" {
+			origin = request.Header.Get("Referer")
+		}
+		if parsed, err := url.Parse(origin); err != nil || origin == "" || parsed.Host != request.Host {
+			c.String(http.StatusForbidden, "Cross-site requests are not allowed")
+			c.Abort()
+		}
+	}
+}
+
+// RequireOperator guards the mutating actions.
+func RequireOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CanOperate(c) {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+	}
+}
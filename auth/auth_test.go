@@ -0,0 +1,271 @@
+package auth_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/auth"
+)
+
+var _ = Describe("Auth", func() {
+	Describe("#ParseTokens", func() {
+		It("reads role:token pairs", func() {
+			tokens, err := auth.ParseTokens("operator:abc, viewer:d:ef")
+			Ω(err).Should(BeNil())
+			Ω(tokens).Should(Equal(map[string]auth.Role{"abc": auth.Operator, "d:ef": auth.Viewer}))
+		})
+
+		It("rejects unknown roles", func() {
+			_, err := auth.ParseTokens("admin:abc")
+			Ω(err).Should(MatchError(`Unknown role "admin", must be viewer or operator`))
+		})
+
+		It("rejects tokens without a role", func() {
+			_, err := auth.ParseTokens("abc")
+			Ω(err).Should(MatchError("Invalid token, must be role:token"))
+		})
+	})
+
+	Describe("#ParseUsers", func() {
+		It("reads role:user:password entries", func() {
+			users, err := auth.ParseUsers("operator:alice:pa:ss")
+			Ω(err).Should(BeNil())
+			Ω(users).Should(Equal(map[string]auth.User{"alice": {Password: "pa:ss", Role: auth.Operator}}))
+		})
+
+		It("rejects users without a password", func() {
+			_, err := auth.ParseUsers("viewer:bob")
+			Ω(err).Should(MatchError("Invalid user, must be role:user:password"))
+		})
+	})
+
+	Describe("#ParseNetworks", func() {
+		It("reads addresses and ranges", func() {
+			networks, err := auth.ParseNetworks("10.0.0.0/8, 192.168.1.1,::1")
+			Ω(err).Should(BeNil())
+			Ω(networks).Should(HaveLen(3))
+			Ω(networks[1].String()).Should(Equal("192.168.1.1/32"))
+			Ω(networks[2].String()).Should(Equal("::1/128"))
+		})
+
+		It("rejects anything else", func() {
+			_, err := auth.ParseNetworks("proxy.internal")
+			Ω(err).Should(MatchError(`Invalid network "proxy.internal", must be an IP address or CIDR range`))
+		})
+	})
+
+	Describe("#Validate", func() {
+		It("refuses a trusted header without trusted proxies", func() {
+			Ω(auth.Config{TrustedHeader: "X-Forwarded-User"}.Validate()).Should(MatchError("A trusted header needs trusted proxies, or any client could set it"))
+			proxies, err := auth.ParseNetworks("10.0.0.0/8")
+			Ω(err).Should(BeNil())
+			Ω(auth.Config{TrustedHeader: "X-Forwarded-User", TrustedProxies: proxies}.Validate()).Should(Succeed())
+		})
+	})
+
+	Describe("#SameOrigin", func() {
+		var router *gin.Engine
+
+		BeforeEach(func() {
+			router = gin.New()
+			router.POST("/act", auth.SameOrigin(), func(c *gin.Context) { c.String(200, "done") })
+		})
+
+		post := func(headers map[string]string) int {
+			request := httptest.NewRequest("POST", "http://dashboard.example.com/act", nil)
+			for name, value := range headers {
+				request.Header.Set(name, value)
+			}
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			return response.Code
+		}
+
+		It("lets in requests from the dashboard's own pages", func() {
+			Ω(post(map[string]string{"Origin": "http://dashboard.example.com"})).Should(Equal(200))
+			Ω(post(map[string]string{"Referer": "http://dashboard.example.com/monitors/1"})).Should(Equal(200))
+		})
+
+		It("lets in bearer tokens, which browsers do not send on their own", func() {
+			Ω(post(map[string]string{"Authorization": "Bearer op-token"})).Should(Equal(200))
+		})
+
+		It("rejects requests from other sites or from nowhere", func() {
+			Ω(post(map[string]string{"Origin": "https://evil.example.com"})).Should(Equal(http.StatusForbidden))
+			Ω(post(map[string]string{})).Should(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("#Middleware", func() {
+		var (
+			config auth.Config
+			router *gin.Engine
+		)
+
+		BeforeEach(func() {
+			gin.SetMode(gin.TestMode)
+			config = auth.Config{
+				Tokens:        map[string]auth.Role{"op-token": auth.Operator, "view-token": auth.Viewer},
+				Users:         map[string]auth.User{"alice": {Password: "secret", Role: auth.Operator}},
+				KioskTokens:   []string{"tv"},
+				TrustedHeader: "X-Forwarded-User",
+				Operators:     []string{"carol"},
+				// httptest requests come from 192.0.2.1.
+				TrustedProxies: []*net.IPNet{{IP: net.IPv4(192, 0, 2, 0), Mask: net.CIDRMask(24, 32)}},
+			}
+		})
+
+		JustBeforeEach(func() {
+			router = gin.New()
+			r := router.Group("/", auth.Middleware(config))
+			r.GET("/", func(c *gin.Context) { c.String(200, string(auth.RoleOf(c))) })
+			r.GET("/user", func(c *gin.Context) { c.String(200, auth.UserOf(c)) })
+			r.POST("/act", auth.RequireOperator(), func(c *gin.Context) { c.String(200, "done") })
+		})
+
+		serve := func(request *http.Request) *httptest.ResponseRecorder {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			return response
+		}
+
+		It("rejects requests without credentials", func() {
+			response := serve(httptest.NewRequest("GET", "/", nil))
+			Ω(response.Code).Should(Equal(http.StatusUnauthorized))
+			Ω(response.Header().Get("WWW-Authenticate")).Should(ContainSubstring("Basic"))
+		})
+
+		It("accepts bearer tokens", func() {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("Authorization", "Bearer view-token")
+			response := serve(request)
+			Ω(response.Code).Should(Equal(200))
+			Ω(response.Body.String()).Should(Equal("viewer"))
+		})
+
+		It("rejects unknown bearer tokens", func() {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("Authorization", "Bearer nope")
+			Ω(serve(request).Code).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("accepts basic users", func() {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.SetBasicAuth("alice", "secret")
+			Ω(serve(request).Body.String()).Should(Equal("operator"))
+		})
+
+		It("rejects wrong passwords", func() {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.SetBasicAuth("alice", "guess")
+			Ω(serve(request).Code).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("trusts the proxy's header", func() {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("X-Forwarded-User", "carol")
+			Ω(serve(request).Body.String()).Should(Equal("operator"))
+			request.Header.Set("X-Forwarded-User", "dave")
+			Ω(serve(request).Body.String()).Should(Equal("viewer"))
+		})
+
+		It("only trusts the header from the trusted proxies", func() {
+			proxies, err := auth.ParseNetworks("10.0.0.0/8, 192.168.1.1")
+			Ω(err).Should(BeNil())
+			config.TrustedProxies = proxies
+			router = gin.New()
+			router.Group("/", auth.Middleware(config)).GET("/", func(c *gin.Context) { c.String(200, string(auth.RoleOf(c))) })
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("X-Forwarded-User", "carol")
+			request.RemoteAddr = "10.1.2.3:4567"
+			Ω(serve(request).Body.String()).Should(Equal("operator"))
+			request.RemoteAddr = "192.168.1.1:4567"
+			Ω(serve(request).Body.String()).Should(Equal("operator"))
+			request.RemoteAddr = "203.0.113.7:4567"
+			Ω(serve(request).Code).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("signs in requests a replica forwarded with its user", func() {
+			config.ForwardSecret = []byte("shared")
+			router = gin.New()
+			r := router.Group("/", auth.Middleware(config))
+			r.GET("/user", func(c *gin.Context) { c.String(200, auth.UserOf(c)+" "+string(auth.RoleOf(c))) })
+			var forwarded *http.Request
+			r.GET("/forward", func(c *gin.Context) {
+				forwarded = httptest.NewRequest("GET", "/user", nil)
+				forwarded.Header.Set("X-Forwarded-User", "carol")
+				config.Forward(c, forwarded)
+			})
+			request := httptest.NewRequest("GET", "/forward", nil)
+			request.Header.Set("X-Forwarded-User", "carol")
+			serve(request)
+			forwarded.RemoteAddr = "203.0.113.7:4567"
+			Ω(serve(forwarded).Body.String()).Should(Equal("carol operator"))
+
+			forwarded.Header.Set("X-Dashboard-Forwarded-Role", "operator")
+			forwarded.Header.Set("X-Dashboard-Forwarded-User", "mallory")
+			Ω(serve(forwarded).Code).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("names the signed in user", func() {
+			request := httptest.NewRequest("GET", "/user", nil)
+			request.SetBasicAuth("alice", "secret")
+			Ω(serve(request).Body.String()).Should(Equal("alice"))
+			request = httptest.NewRequest("GET", "/user", nil)
+			request.Header.Set("X-Forwarded-User", "carol")
+			Ω(serve(request).Body.String()).Should(Equal("carol"))
+			request = httptest.NewRequest("GET", "/user", nil)
+			request.Header.Set("Authorization", "Bearer op-token")
+			Ω(serve(request).Body.String()).Should(BeEmpty())
+		})
+
+		It("lets kiosk links in as viewers and remembers them", func() {
+			response := serve(httptest.NewRequest("GET", "/?token=tv", nil))
+			Ω(response.Body.String()).Should(Equal("viewer"))
+			cookies := response.Result().Cookies()
+			Ω(cookies).Should(HaveLen(1))
+			Ω(cookies[0].Name).Should(Equal(auth.KioskCookie))
+			Ω(cookies[0].HttpOnly).Should(BeTrue())
+
+			request := httptest.NewRequest("GET", "/", nil)
+			request.AddCookie(cookies[0])
+			Ω(serve(request).Body.String()).Should(Equal("viewer"))
+		})
+
+		It("does not accept operator tokens in links", func() {
+			Ω(serve(httptest.NewRequest("GET", "/?token=op-token", nil)).Code).Should(Equal(http.StatusUnauthorized))
+		})
+
+		It("only lets operators use actions", func() {
+			request := httptest.NewRequest("POST", "/act", nil)
+			request.Header.Set("Authorization", "Bearer view-token")
+			Ω(serve(request).Code).Should(Equal(http.StatusForbidden))
+			request.Header.Set("Authorization", "Bearer op-token")
+			Ω(serve(request).Code).Should(Equal(200))
+		})
+
+		It("ignores the header without trusted proxies", func() {
+			config.TrustedProxies = nil
+			router = gin.New()
+			router.Group("/", auth.Middleware(config)).GET("/", func(c *gin.Context) { c.String(200, string(auth.RoleOf(c))) })
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("X-Forwarded-User", "carol")
+			Ω(serve(request).Code).Should(Equal(http.StatusUnauthorized))
+		})
+
+		Context("when nothing is configured", func() {
+			BeforeEach(func() {
+				config = auth.Config{}
+			})
+
+			It("lets everyone operate", func() {
+				Ω(serve(httptest.NewRequest("POST", "/act", nil)).Code).Should(Equal(200))
+			})
+		})
+	})
+})
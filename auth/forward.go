@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The headers a replica forwards a request to the leader with, naming who it
+// signed in. The leader cannot sign them in itself, as it would see a trusted
+// header coming from the replica rather than the proxy.
+const (
+	forwardedUserHeader      = "X-Dashboard-Forwarded-User"
+	forwardedRoleHeader      = "X-Dashboard-Forwarded-Role"
+	forwardedAtHeader        = "X-Dashboard-Forwarded-At"
+	forwardedSignatureHeader = "X-Dashboard-Forwarded-Signature"
+)
+
+// forwardedMaxAge bounds how long a forwarded request can be replayed for.
+const forwardedMaxAge = time.Minute
+
+func forwardedSignature(secret []byte, at, role, user string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(at + "\n" + role + "\n" + user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Forward signs the user and role Middleware gave the request onto the copy of
+// it that is forwarded to the leader. It does nothing without a ForwardSecret
+// or when Middleware did not run.
+func (c Config) Forward(ctx *gin.Context, forwarded *http.Request) {
+	for _, header := range []string{forwardedUserHeader, forwardedRoleHeader, forwardedAtHeader, forwardedSignatureHeader} {
+		forwarded.Header.Del(header)
+	}
+	role, ok := ctx.Get(roleKey)
+	if len(c.ForwardSecret) == 0 || !ok {
+		return
+	}
+	at := strconv.FormatInt(time.Now().Unix(), 10)
+	user := UserOf(ctx)
+	forwarded.Header.Set(forwardedUserHeader, user)
+	forwarded.Header.Set(forwardedRoleHeader, string(role.(Role)))
+	forwarded.Header.Set(forwardedAtHeader, at)
+	forwarded.Header.Set(forwardedSignatureHeader, forwardedSignature(c.ForwardSecret, at, string(role.(Role)), user))
+}
+
+// forwarded returns the user and role a replica signed onto a forwarded
+// request, or false if it is not one or the signature does not check out.
+func (c Config) forwarded(request *http.Request) (string, Role, bool) {
+	signature := request.Header.Get(forwardedSignatureHeader)
+	if len(c.ForwardSecret) == 0 || signature == "" {
+		return "", "", false
+	}
+	at := request.Header.Get(forwardedAtHeader)
+	user := request.Header.Get(forwardedUserHeader)
+	role, err := parseRole(request.Header.Get(forwardedRoleHeader))
+	if err != nil || !equal(forwardedSignature(c.ForwardSecret, at, string(role), user), signature) {
+		return "", "", false
+	}
+	seconds, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return "", "", false
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > forwardedMaxAge || age < -forwardedMaxAge {
+		return "", "", false
+	}
+	return user, role, true
+}
@@ -1,11 +1,16 @@
 package circleci
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
+
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
 )
 
 const (
@@ -23,6 +28,10 @@ const (
 
 const statusRespError = "Status Code was not ok"
 
+// tagPipelinePages bounds how far back GetTagPipelines looks, as tags can
+// only be found by paging through every pipeline of a project.
+const tagPipelinePages = 5
+
 var completedStatuses = map[string]interface{}{
 	statusSuccess: nil,
 	statusFailed:  nil,
@@ -45,8 +54,10 @@ type CircleCI interface {
 	CreateProjectEnvVar(string, string, string) (ProjectEnvVar, error)
 	DeleteProjectEnvVar(string, string) error
 	GetAllPipelines(Project) (Pipelines, error)
+	GetTagPipelines(Project) (Pipelines, error)
 	GetWorkflowsForPipeline(Pipeline) (Workflows, error)
 	GetJobsForWorkflow(Workflow) (Jobs, error)
+	GetJobTests(string, int) (TestResults, error)
 	PreviousCompleteWorkflowState(Pipelines, string) (string, error)
 	WorkflowLink(Project, Pipeline, Workflow) string
 	JobLink(Project, Job) string
@@ -56,12 +67,21 @@ type CircleCI interface {
 type Client struct {
 	Config *Config
 	Client *resty.Client
+
+	ctx      context.Context
+	recorder *recordingTransport
 }
 
 type Config struct {
 	APIURL   string
 	JobsURL  string
 	APIToken string
+	// RecordDir, if set, saves every API request and response there as a
+	// fixture with the API token scrubbed, until StopRecording is called.
+	RecordDir string
+	// ReplayDir, if set, answers every API request from fixtures saved by
+	// RecordDir instead of calling CircleCI.
+	ReplayDir string
 }
 
 func DefaultConfig() *Config {
@@ -79,23 +99,54 @@ func NewClient(config *Config) (*Client, error) {
 	if config.JobsURL == "" {
 		config.JobsURL = defaultConfig.JobsURL
 	}
-	if config.APIToken == "" {
+	if config.APIToken == "" && config.ReplayDir == "" {
 		return nil, fmt.Errorf("Must provide an API Token")
 	}
 	client := resty.New()
 	client.SetBasicAuth(config.APIToken, "")
-	return &Client{Client: client, Config: config}, nil
+	var recorder *recordingTransport
+	switch {
+	case config.ReplayDir != "":
+		client.SetTransport(&replayTransport{dir: config.ReplayDir})
+	case config.RecordDir != "":
+		recorder = &recordingTransport{dir: config.RecordDir, token: config.APIToken, next: http.DefaultTransport}
+		client.SetTransport(recorder)
+	}
+	client.SetTransport(telemetry.Transport(client.GetClient().Transport))
+	return &Client{Client: client, Config: config, recorder: recorder}, nil
+}
+
+// StopRecording stops saving fixtures, leaving those saved so far as one
+// consistent recording. It does nothing if the client is not recording.
+func (c *Client) StopRecording() {
+	if c.recorder != nil {
+		c.recorder.stopped.Store(true)
+	}
+}
+
+// WithContext returns a copy of the client whose API calls carry the context,
+// so that their spans are traced as part of whatever it belongs to.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	return &Client{Config: c.Config, Client: c.Client, ctx: ctx, recorder: c.recorder}
+}
+
+func (c *Client) request() *resty.Request {
+	request := c.Client.R()
+	if c.ctx != nil {
+		request.SetContext(c.ctx)
+	}
+	return request
 }
 
 func (c *Client) get(urlPath string) (*resty.Response, error) {
-	return c.Client.R().
+	return c.request().
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Accept", "application/json").
 		Get(fmt.Sprintf("%s/%s", c.Config.APIURL, urlPath))
 }
 
 func (c *Client) post(urlPath string, body interface{}) (*resty.Response, error) {
-	return c.Client.R().
+	return c.request().
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Accept", "application/json").
 		SetBody(body).
@@ -103,7 +154,7 @@ func (c *Client) post(urlPath string, body interface{}) (*resty.Response, error)
 }
 
 func (c *Client) delete(urlPath string) (*resty.Response, error) {
-	return c.Client.R().
+	return c.request().
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Accept", "application/json").
 		Delete(fmt.Sprintf("%s/%s", c.Config.APIURL, urlPath))
@@ -118,6 +169,12 @@ func (c *Client) callAPIV2(apiTarget string) (*resty.Response, error) {
 }
 
 func (c *Client) pagedCallAPIV2(apiTarget string) ([]json.RawMessage, error) {
+	return c.limitedPagedCallAPIV2(apiTarget, 0)
+}
+
+// limitedPagedCallAPIV2 fetches at most maxPages pages, or every page if
+// maxPages is 0.
+func (c *Client) limitedPagedCallAPIV2(apiTarget string, maxPages int) ([]json.RawMessage, error) {
 	var (
 		nextPage   string
 		items      []json.RawMessage
@@ -148,6 +205,9 @@ func (c *Client) pagedCallAPIV2(apiTarget string) ([]json.RawMessage, error) {
 		}
 		items = append(items, pagedResponse.Items)
 		nextPageToken = pagedResponse.NextPageToken
+		if maxPages > 0 && len(items) >= maxPages {
+			break
+		}
 	}
 	return items, nil
 }
@@ -193,14 +253,33 @@ func (c *Client) CreateProjectEnvVar(projectSlug, key, value string) (ProjectEnv
 	if err != nil {
 		return ProjectEnvVar{}, err
 	}
+	if err := messageError(resp); err != nil {
+		return ProjectEnvVar{}, err
+	}
 	var respEnvVar ProjectEnvVar
 	err = json.Unmarshal(resp.Body(), &respEnvVar)
 	return respEnvVar, err
 }
 
 func (c *Client) DeleteProjectEnvVar(projectSlug, key string) error {
-	_, err := c.delete(fmt.Sprintf("api/v2/project/%s/envvar/%s", projectSlug, key))
-	return err
+	resp, err := c.delete(fmt.Sprintf("api/v2/project/%s/envvar/%s", projectSlug, key))
+	if err != nil {
+		return err
+	}
+	return messageError(resp)
+}
+
+// messageError turns a non-2xx response into an error carrying CircleCI's
+// message, if it sent one.
+func messageError(resp *resty.Response) error {
+	if resp.StatusCode() <= 299 {
+		return nil
+	}
+	var message MessageResponse
+	if err := json.Unmarshal(resp.Body(), &message); err == nil && message.Message != "" {
+		return errors.New(message.Message)
+	}
+	return errors.New(statusRespError)
 }
 
 func (c *Client) GetAllPipelines(project Project) (Pipelines, error) {
@@ -224,6 +303,31 @@ func (c *Client) GetAllPipelines(project Project) (Pipelines, error) {
 	return pipelines, nil
 }
 
+// GetTagPipelines returns the project's recent pipelines that were triggered
+// by a git tag, newest first.
+func (c *Client) GetTagPipelines(project Project) (Pipelines, error) {
+	items, err := c.limitedPagedCallAPIV2(fmt.Sprintf("project/%s/pipeline", project.Slug()), tagPipelinePages)
+	if err != nil {
+		return nil, err
+	}
+	var pipelines Pipelines
+	for _, item := range items {
+		if len(item) == 0 {
+			continue
+		}
+		var pagedPipelines Pipelines
+		if err := json.Unmarshal(item, &pagedPipelines); err != nil {
+			return nil, err
+		}
+		for _, pipeline := range pagedPipelines {
+			if pipeline.VCS.Tag != "" {
+				pipelines = append(pipelines, pipeline)
+			}
+		}
+	}
+	return pipelines, nil
+}
+
 func (c *Client) GetWorkflowsForPipeline(pipeline Pipeline) (Workflows, error) {
 	var workflows Workflows
 	items, err := c.pagedCallAPIV2(fmt.Sprintf("pipeline/%s/workflow", pipeline.ID))
@@ -262,9 +366,33 @@ func (c *Client) GetJobsForWorkflow(workflow Workflow) (Jobs, error) {
 	return jobs, nil
 }
 
+// GetJobTests returns the test results a job stored, given the project slug
+// and the job number.
+func (c *Client) GetJobTests(projectSlug string, jobNumber int) (TestResults, error) {
+	var tests TestResults
+	items, err := c.pagedCallAPIV2(fmt.Sprintf("project/%s/%d/tests", projectSlug, jobNumber))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if len(item) == 0 {
+			continue
+		}
+		var pagedTests TestResults
+		if err := json.Unmarshal(item, &pagedTests); err != nil {
+			return nil, err
+		}
+		tests = append(tests, pagedTests...)
+	}
+	return tests, nil
+}
+
 func (c *Client) PreviousCompleteWorkflowState(pipelines Pipelines, workflowName string) (string, error) {
 	status := statusUnknown
 	for _, pipeline := range pipelines {
+		if pipeline.ConfigError() {
+			continue
+		}
 		var workflowInPipeline bool
 		workflows, err := c.GetWorkflowsForPipeline(pipeline)
 		if err != nil {
@@ -185,6 +185,37 @@ var _ = Describe("Client", func() {
 			Ω(err).Should(BeNil())
 			Ω(envVar).Should(Equal(circleci.ProjectEnvVar{Name: "foo", Value: "xxxxxxx"}))
 		})
+
+		Context("when circleci rejects the env var", func() {
+			BeforeEach(func() {
+				teardown()
+				mocks := []MockRoute{
+					{"POST", "/api/v2/project/github/foobar/example/envvar", `{"message": "Permission denied"}`, 403, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("returns circleci's message as an error", func() {
+				envVar, err := client.CreateProjectEnvVar(projectSlug, "foo", "bar")
+				Ω(err).Should(MatchError("Permission denied"))
+				Ω(envVar).Should(Equal(circleci.ProjectEnvVar{}))
+			})
+		})
+
+		Context("when circleci's message has a percent sign in it", func() {
+			BeforeEach(func() {
+				teardown()
+				mocks := []MockRoute{
+					{"POST", "/api/v2/project/github/foobar/example/envvar", `{"message": "Value must be under 100%s"}`, 400, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("returns the message as it is", func() {
+				_, err := client.CreateProjectEnvVar(projectSlug, "foo", "bar")
+				Ω(err).Should(MatchError("Value must be under 100%s"))
+			})
+		})
 	})
 
 	Describe("#DeleteProjectEnvVar", func() {
@@ -201,6 +232,21 @@ var _ = Describe("Client", func() {
 			err := client.DeleteProjectEnvVar(projectSlug, "foo")
 			Ω(err).Should(BeNil())
 		})
+
+		Context("when circleci returns an error without a message", func() {
+			BeforeEach(func() {
+				teardown()
+				mocks := []MockRoute{
+					{"DELETE", "/api/v2/project/github/foobar/example/envvar/foo", "", 500, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("returns an error", func() {
+				err := client.DeleteProjectEnvVar(projectSlug, "foo")
+				Ω(err).Should(MatchError("Status Code was not ok"))
+			})
+		})
 	})
 
 	Describe("#GetAllPipelines", func() {
@@ -301,6 +347,38 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("#GetTagPipelines", func() {
+		var project = circleci.Project{
+			VCSType:  "github",
+			Username: "foobar",
+			Reponame: "example",
+		}
+
+		Context("when circleci returns an error", func() {
+			BeforeEach(func() {
+				setup(MockRoute{"GET", "/api/v2/project/github/foobar/example/pipeline", "[]", 500, "", nil})
+			})
+
+			It("returns an error", func() {
+				pipelines, err := client.GetTagPipelines(project)
+				Ω(err).Should(MatchError("Status Code was not ok"))
+				Ω(pipelines).Should(BeEmpty())
+			})
+		})
+
+		Context("when circleci returns valid json", func() {
+			BeforeEach(func() {
+				setup(MockRoute{"GET", "/api/v2/project/github/foobar/example/pipeline", tag_pipeline_resp, 200, "", nil})
+			})
+
+			It("returns only the tag pipelines", func() {
+				pipelines, err := client.GetTagPipelines(project)
+				Ω(err).Should(BeNil())
+				Ω(pipelines).Should(Equal(circleci.Pipelines{{ID: "2", State: "created", VCS: circleci.VCS{Tag: "v1.2.0"}}}))
+			})
+		})
+	})
+
 	Describe("#GetWorkflowsForPipeline", func() {
 		var pipeline = circleci.Pipeline{
 			ID: "1",
@@ -490,6 +568,45 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("#GetJobTests", func() {
+		Context("when circleci returns an error", func() {
+			BeforeEach(func() {
+				mocks := []MockRoute{
+					{"GET", "/api/v2/project/github/foobar/example/42/tests", "{}", 500, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("returns an error", func() {
+				tests, err := client.GetJobTests("github/foobar/example", 42)
+				Ω(err).ShouldNot(BeNil())
+				Ω(tests).Should(BeEmpty())
+			})
+		})
+
+		Context("when circleci returns valid json", func() {
+			BeforeEach(func() {
+				mocks := []MockRoute{
+					{"GET", "/api/v2/project/github/foobar/example/42/tests", tests_resp, 200, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("returns the test results", func() {
+				tests, err := client.GetJobTests("github/foobar/example", 42)
+				Ω(err).Should(BeNil())
+				Ω(tests).Should(HaveLen(2))
+				Ω(tests[1]).Should(Equal(circleci.TestResult{
+					Name:      "loads the config",
+					Classname: "config_test",
+					Result:    "failure",
+					Message:   "expected true to be false",
+					RunTime:   0.03,
+				}))
+			})
+		})
+	})
+
 	Describe("#PreviousCompleteWorkflowState", func() {
 		var pipelines = circleci.Pipelines{
 			{
@@ -554,6 +671,36 @@ var _ = Describe("Client", func() {
 			})
 		})
 
+		Context("when a pipeline in the history has a config error", func() {
+			var erroredPipelines = circleci.Pipelines{
+				{
+					ID: "1",
+				},
+				{
+					ID:    "2",
+					State: circleci.PipelineStateErrored,
+				},
+				{
+					ID: "3",
+				},
+			}
+
+			BeforeEach(func() {
+				mocks := []MockRoute{
+					{"GET", "/api/v2/pipeline/1/workflow", workflow_resp_previous_state_running, 200, "", nil},
+					{"GET", "/api/v2/pipeline/2/workflow", workflows_resp_3, 200, "", nil},
+					{"GET", "/api/v2/pipeline/3/workflow", workflow_resp_previous_state_failed, 200, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("skips the errored pipeline", func() {
+				status, err := client.PreviousCompleteWorkflowState(erroredPipelines, "previous_status")
+				Ω(err).To(BeNil())
+				Ω(status).To(Equal("failed"))
+			})
+		})
+
 		Context("when status is unknown", func() {
 			BeforeEach(func() {
 				mocks := []MockRoute{
@@ -670,5 +817,42 @@ var _ = Describe("Client", func() {
 				Ω(status).Should(Equal("canceled success"))
 			})
 		})
+
+		Context("when the history is running, errored then failed", func() {
+			var (
+				history = circleci.Pipelines{
+					{
+						ID: "1",
+					},
+					{
+						ID:    "2",
+						State: circleci.PipelineStateErrored,
+					},
+					{
+						ID: "3",
+					},
+				}
+				runningWorkflow = circleci.Workflow{
+					ID:     "1",
+					Status: "running",
+					Name:   "previous_status",
+				}
+			)
+
+			BeforeEach(func() {
+				mocks := []MockRoute{
+					{"GET", "/api/v2/pipeline/1/workflow", workflow_resp_previous_state_running, 200, "", nil},
+					{"GET", "/api/v2/pipeline/2/workflow", workflows_resp_3, 200, "", nil},
+					{"GET", "/api/v2/pipeline/3/workflow", workflow_resp_previous_state_failed, 200, "", nil},
+				}
+				setupMultiple(mocks)
+			})
+
+			It("reports the failed state from before the config error", func() {
+				status, err := client.WorkflowStatus(history, runningWorkflow)
+				Ω(err).Should(BeNil())
+				Ω(status).Should(Equal("running failed"))
+			})
+		})
 	})
 })
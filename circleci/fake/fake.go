@@ -0,0 +1,352 @@
+// Package fake serves a realistic, evolving imitation of the CircleCI API so
+// the dashboard can be developed, demoed and tested without a token or network.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+const (
+	pageSize       = 20
+	historyLength  = 50
+	DefaultToken   = "fake-circleci-token"
+	defaultHistory = 30
+	// historyStepInterval spaces out the history when Options.StepInterval
+	// is not set.
+	historyStepInterval = 30 * time.Second
+)
+
+type Options struct {
+	// Seed makes the simulation repeatable.
+	Seed int64
+	// StepInterval advances the simulation one step each interval of wall
+	// clock time. Zero means the simulation only moves when Step is called.
+	StepInterval time.Duration
+	// History is the number of steps simulated before the server starts.
+	History int
+	Now     func() time.Time
+}
+
+type Server struct {
+	URL string
+
+	mu         sync.Mutex
+	server     *httptest.Server
+	simulation *simulation
+	options    Options
+	lastStep   time.Time
+}
+
+// NewServer starts a fake CircleCI API on a local port.
+func NewServer(options Options) *Server {
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+	if options.History == 0 {
+		options.History = defaultHistory
+	}
+	// History is simulated as if it happened before now, so that finished
+	// workflows have realistic durations.
+	stepInterval := options.StepInterval
+	if stepInterval <= 0 {
+		stepInterval = historyStepInterval
+	}
+	replayed := options.Now().Add(-time.Duration(options.History) * stepInterval)
+	s := &Server{
+		options:    options,
+		simulation: newSimulation(options.Seed, func() time.Time { return replayed }),
+		lastStep:   options.Now(),
+	}
+	for i := 0; i < options.History; i++ {
+		s.simulation.step()
+		replayed = replayed.Add(stepInterval)
+	}
+	s.simulation.now = options.Now
+	s.server = httptest.NewServer(s.router())
+	s.URL = s.server.URL
+	return s
+}
+
+// Config returns a client config pointing at the fake server.
+func (s *Server) Config() *circleci.Config {
+	return &circleci.Config{
+		APIURL:   s.URL,
+		JobsURL:  s.URL,
+		APIToken: DefaultToken,
+	}
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Step advances the simulation by one step.
+func (s *Server) Step() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.simulation.step()
+	s.trimHistory()
+}
+
+func (s *Server) catchUp() {
+	if s.options.StepInterval <= 0 {
+		return
+	}
+	now := s.options.Now()
+	for now.Sub(s.lastStep) >= s.options.StepInterval {
+		s.simulation.step()
+		s.lastStep = s.lastStep.Add(s.options.StepInterval)
+	}
+	s.trimHistory()
+}
+
+func (s *Server) trimHistory() {
+	for _, project := range s.simulation.projects {
+		if len(project.pipelines) > historyLength {
+			project.pipelines = project.pipelines[:historyLength]
+		}
+	}
+}
+
+func (s *Server) router() http.Handler {
+	router := mux.NewRouter()
+	router.Use(s.middleware)
+	router.HandleFunc("/api/v1.1/projects", s.getProjects).Methods("GET")
+	router.HandleFunc("/api/v2/project/{vcs}/{org}/{repo}/pipeline", s.getPipelines).Methods("GET")
+	router.HandleFunc("/api/v2/project/{vcs}/{org}/{repo}/envvar", s.getEnvVars).Methods("GET")
+	router.HandleFunc("/api/v2/project/{vcs}/{org}/{repo}/envvar", s.createEnvVar).Methods("POST")
+	router.HandleFunc("/api/v2/project/{vcs}/{org}/{repo}/envvar/{name}", s.deleteEnvVar).Methods("DELETE")
+	router.HandleFunc("/api/v2/pipeline/{id}/workflow", s.getWorkflows).Methods("GET")
+	router.HandleFunc("/api/v2/workflow/{id}/job", s.getJobs).Methods("GET")
+	router.HandleFunc("/api/v2/project/{vcs}/{org}/{repo}/{number:[0-9]+}/tests", s.getJobTests).Methods("GET")
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 404, circleci.MessageResponse{Message: "Not found"})
+	})
+	return router
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, _, ok := r.BasicAuth(); !ok || token != DefaultToken {
+			writeJSON(w, 401, circleci.MessageResponse{Message: "You must log in first."})
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.catchUp()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writePage pages items the same way the v2 API does, using the offset of the
+// next page as its token.
+func writePage(w http.ResponseWriter, r *http.Request, items []interface{}) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("page-token"))
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + pageSize
+	var nextPageToken *string
+	if end < len(items) {
+		token := strconv.Itoa(end)
+		nextPageToken = &token
+	} else {
+		end = len(items)
+	}
+	page := items[start:end]
+	if page == nil {
+		page = []interface{}{}
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"items":           page,
+		"next_page_token": nextPageToken,
+	})
+}
+
+func (s *Server) projectFromRequest(w http.ResponseWriter, r *http.Request) *project {
+	vars := mux.Vars(r)
+	project := s.simulation.project(fmt.Sprintf("%s/%s/%s", vars["vcs"], vars["org"], vars["repo"]))
+	if project == nil {
+		writeJSON(w, 404, circleci.MessageResponse{Message: "Project not found"})
+	}
+	return project
+}
+
+func (s *Server) getProjects(w http.ResponseWriter, r *http.Request) {
+	var projects circleci.Projects
+	for _, project := range s.simulation.projects {
+		branches := map[string]interface{}{}
+		for _, branch := range project.Branches {
+			branches[branch] = map[string]interface{}{}
+		}
+		projects = append(projects, circleci.Project{
+			VCSType:  project.VCSType,
+			Username: project.Username,
+			Reponame: project.Reponame,
+			Branches: branches,
+		})
+	}
+	writeJSON(w, 200, projects)
+}
+
+func (s *Server) getPipelines(w http.ResponseWriter, r *http.Request) {
+	project := s.projectFromRequest(w, r)
+	if project == nil {
+		return
+	}
+	branch := r.URL.Query().Get("branch")
+	var items []interface{}
+	for _, pipeline := range project.pipelines {
+		if branch != "" && pipeline.Branch != branch {
+			continue
+		}
+		items = append(items, circleci.Pipeline{
+			ID:        pipeline.ID,
+			Number:    pipeline.Number,
+			State:     pipeline.State,
+			CreatedAt: pipeline.CreatedAt,
+			Errors:    pipeline.Errors,
+			Trigger:   circleci.Trigger{Type: pipeline.Trigger},
+			VCS:       circleci.VCS{Branch: pipeline.Branch, Tag: pipeline.Tag, Revision: pipeline.Revision},
+		})
+	}
+	writePage(w, r, items)
+}
+
+func (s *Server) getWorkflows(w http.ResponseWriter, r *http.Request) {
+	pipeline := s.simulation.pipeline(mux.Vars(r)["id"])
+	if pipeline == nil {
+		writeJSON(w, 404, circleci.MessageResponse{Message: "Pipeline not found"})
+		return
+	}
+	var items []interface{}
+	for _, workflow := range pipeline.Workflows {
+		items = append(items, circleci.Workflow{
+			ID:        workflow.ID,
+			Name:      workflow.Name,
+			Status:    workflow.Status,
+			CreatedAt: workflow.CreatedAt,
+			StoppedAt: workflow.StoppedAt,
+		})
+	}
+	writePage(w, r, items)
+}
+
+func (s *Server) getJobs(w http.ResponseWriter, r *http.Request) {
+	workflow := s.simulation.workflow(mux.Vars(r)["id"])
+	if workflow == nil {
+		writeJSON(w, 404, circleci.MessageResponse{Message: "Workflow not found"})
+		return
+	}
+	var items []interface{}
+	for _, job := range workflow.Jobs {
+		jobType := "build"
+		if strings.HasPrefix(job.Name, "approve-") {
+			jobType = "approval"
+		}
+		items = append(items, circleci.Job{
+			ID:        job.ID,
+			JobNumber: job.Number,
+			Name:      job.Name,
+			Type:      jobType,
+			Status:    job.Status,
+			StartedAt: job.StartedAt,
+			StoppedAt: job.StoppedAt,
+		})
+	}
+	writePage(w, r, items)
+}
+
+func (s *Server) getJobTests(w http.ResponseWriter, r *http.Request) {
+	project := s.projectFromRequest(w, r)
+	if project == nil {
+		return
+	}
+	number, _ := strconv.Atoi(mux.Vars(r)["number"])
+	job := s.simulation.job(project.slug(), number)
+	if job == nil {
+		writeJSON(w, 404, circleci.MessageResponse{Message: "Job not found"})
+		return
+	}
+	var items []interface{}
+	for _, test := range job.Tests {
+		items = append(items, test)
+	}
+	writePage(w, r, items)
+}
+
+func maskValue(value string) string {
+	if len(value) <= 4 {
+		return "xxxx"
+	}
+	return "xxxx" + value[len(value)-4:]
+}
+
+func (s *Server) getEnvVars(w http.ResponseWriter, r *http.Request) {
+	project := s.projectFromRequest(w, r)
+	if project == nil {
+		return
+	}
+	var items []interface{}
+	for _, name := range sortedKeys(project.EnvVars) {
+		items = append(items, circleci.ProjectEnvVar{Name: name, Value: maskValue(project.EnvVars[name])})
+	}
+	writePage(w, r, items)
+}
+
+func (s *Server) createEnvVar(w http.ResponseWriter, r *http.Request) {
+	project := s.projectFromRequest(w, r)
+	if project == nil {
+		return
+	}
+	var envVar circleci.ProjectEnvVar
+	if err := json.NewDecoder(r.Body).Decode(&envVar); err != nil || envVar.Name == "" {
+		writeJSON(w, 400, circleci.MessageResponse{Message: "Invalid environment variable"})
+		return
+	}
+	project.EnvVars[envVar.Name] = envVar.Value
+	writeJSON(w, 201, circleci.ProjectEnvVar{Name: envVar.Name, Value: maskValue(envVar.Value)})
+}
+
+func (s *Server) deleteEnvVar(w http.ResponseWriter, r *http.Request) {
+	project := s.projectFromRequest(w, r)
+	if project == nil {
+		return
+	}
+	delete(project.EnvVars, mux.Vars(r)["name"])
+	writeJSON(w, 200, circleci.MessageResponse{Message: "Environment variable deleted."})
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch typed := m.(type) {
+	case map[string]string:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	case map[string][]string:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
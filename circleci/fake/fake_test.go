@@ -0,0 +1,223 @@
+package fake_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/circleci/fake"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/dora"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		server *fake.Server
+		client *circleci.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = fake.NewServer(fake.Options{Seed: 1})
+		client, err = circleci.NewClient(server.Config())
+		Ω(err).Should(BeNil())
+		client.Client.SetDisableWarn(true)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("rejects the wrong token", func() {
+		config := server.Config()
+		config.APIToken = "wrong"
+		badClient, err := circleci.NewClient(config)
+		Ω(err).Should(BeNil())
+		badClient.Client.SetDisableWarn(true)
+		_, err = badClient.GetProjectEnvVars("github/demo-org/payments-api")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("serves projects with pipeline history", func() {
+		projects, err := client.GetAllProjects()
+		Ω(err).Should(BeNil())
+		Ω(projects).Should(HaveLen(4))
+		for _, project := range projects {
+			pipelines, err := client.GetAllPipelines(project)
+			Ω(err).Should(BeNil())
+			Ω(pipelines.LatestPerBranch()).Should(HaveLen(len(project.Branches)))
+		}
+	})
+
+	It("pages through long pipeline histories", func() {
+		for i := 0; i < 200; i++ {
+			server.Step()
+		}
+		projects, err := client.GetAllProjects()
+		Ω(err).Should(BeNil())
+		pipelines, err := client.GetAllPipelines(projects[0])
+		Ω(err).Should(BeNil())
+		Ω(len(pipelines)).Should(BeNumerically(">", 20))
+	})
+
+	It("traces each project along with the API calls made for it", func() {
+		previous := otel.GetTracerProvider()
+		defer otel.SetTracerProvider(previous)
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+		provider := dashboard.NewCircleCIProvider(client, &circleci.Filter{})
+		_, err := dashboard.BuildProvider(context.Background(), provider, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+		Ω(err).Should(BeNil())
+
+		projectSpans := map[trace.SpanID]bool{}
+		var calls []sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			switch span.Name() {
+			case "collect project":
+				projectSpans[span.SpanContext().SpanID()] = true
+			case "HTTP GET":
+				calls = append(calls, span)
+			}
+		}
+		Ω(projectSpans).Should(HaveLen(4))
+		Ω(len(calls)).Should(BeNumerically(">", 4))
+		var projectCalls int
+		for _, call := range calls {
+			if projectSpans[call.Parent().SpanID()] {
+				projectCalls++
+			}
+		}
+		// Only listing the projects happens outside of a project.
+		Ω(projectCalls).Should(Equal(len(calls) - 1))
+	})
+
+	It("builds a dashboard", func() {
+		monitors, err := dashboard.Build(client, &circleci.Filter{}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+		Ω(err).Should(BeNil())
+		Ω(monitors).ShouldNot(BeEmpty())
+	})
+
+	It("builds release monitors from tag pipelines", func() {
+		monitors, err := dashboard.Build(client, &circleci.Filter{}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{TagPatterns: []string{"v*"}})
+		Ω(err).Should(BeNil())
+		var tags []string
+		for _, monitor := range monitors {
+			if monitor.Tag != "" {
+				Ω(monitor.Name).Should(Equal("demo-org/payments-api"))
+				tags = append(tags, monitor.Tag)
+			}
+		}
+		Ω(tags).Should(HaveLen(2))
+		Ω(tags[0]).Should(HavePrefix("v1."))
+		Ω(tags[1]).Should(Equal(tags[0]))
+	})
+
+	It("builds separate monitors for scheduled pipelines", func() {
+		for i := 0; i < 100; i++ {
+			server.Step()
+		}
+		monitors, err := dashboard.Build(client, &circleci.Filter{}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{SplitByTrigger: true})
+		Ω(err).Should(BeNil())
+		var triggers []string
+		for _, monitor := range monitors {
+			if monitor.Name == "demo-org/web-frontend" && monitor.Branch == "master" {
+				triggers = append(triggers, monitor.Trigger)
+			}
+		}
+		Ω(triggers).Should(ConsistOf("push", "scheduled"))
+	})
+
+	It("reruns some failed workflows, making them flaky", func() {
+		for i := 0; i < 200; i++ {
+			server.Step()
+		}
+		monitors, err := dashboard.Build(client, &circleci.Filter{}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{FlakyHistory: 20})
+		Ω(err).Should(BeNil())
+		Ω(monitors.MostFlaky()).ShouldNot(BeEmpty())
+	})
+
+	It("records deployments for DORA metrics", func() {
+		for i := 0; i < 100; i++ {
+			server.Step()
+		}
+		config := dora.Config{Deployments: map[string][]string{"demo-org/payments-api": {"deploy"}}}
+		deployments, err := dora.Collect(client, &circleci.Filter{}, config, time.Time{})
+		Ω(err).Should(BeNil())
+		Ω(deployments).ShouldNot(BeEmpty())
+		for _, deployment := range deployments {
+			Ω(deployment.Workflow).Should(Equal("deploy"))
+			Ω(deployment.LeadTime()).Should(BeNumerically(">", 0))
+		}
+	})
+
+	It("starts, runs and finishes workflows as it steps", func() {
+		seen := map[string]bool{}
+		for i := 0; i < 100; i++ {
+			server.Step()
+			monitors, err := dashboard.Build(client, &circleci.Filter{}, &dashboard.FeatureFlags{AnimatedBuildErrors: true}, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			for _, monitor := range monitors {
+				for _, status := range strings.Fields(monitor.Status) {
+					seen[status] = true
+				}
+				if monitor.FailedTests > 0 {
+					seen["failed tests"] = true
+				}
+			}
+		}
+		Ω(seen).Should(HaveKey("running"))
+		Ω(seen).Should(HaveKey("on_hold"))
+		Ω(seen).Should(HaveKey("success"))
+		Ω(seen).Should(HaveKey("failed"))
+		Ω(seen).Should(HaveKey("errored"))
+		Ω(seen).Should(HaveKey("config-invalid"))
+		Ω(seen).Should(HaveKey("failed tests"))
+	})
+
+	It("manages environment variables without revealing their values", func() {
+		slug := "bitbucket/demo-team/mobile-app"
+		_, err := client.CreateProjectEnvVar(slug, "API_KEY", "secret-value-1234")
+		Ω(err).Should(BeNil())
+		envVars, err := client.GetProjectEnvVars(slug)
+		Ω(err).Should(BeNil())
+		Ω(envVars).Should(Equal(circleci.ProjectEnvVars{{Name: "API_KEY", Value: "xxxx1234"}}))
+		Ω(client.DeleteProjectEnvVar(slug, "API_KEY")).Should(Succeed())
+		envVars, err = client.GetProjectEnvVars(slug)
+		Ω(err).Should(BeNil())
+		Ω(envVars).Should(BeEmpty())
+	})
+
+	Context("when a step interval is set", func() {
+		var now time.Time
+
+		BeforeEach(func() {
+			server.Close()
+			now = time.Date(2020, 9, 4, 12, 0, 0, 0, time.UTC)
+			server = fake.NewServer(fake.Options{Seed: 1, StepInterval: time.Minute, Now: func() time.Time { return now }})
+			var err error
+			client, err = circleci.NewClient(server.Config())
+			Ω(err).Should(BeNil())
+			client.Client.SetDisableWarn(true)
+		})
+
+		It("catches up on steps as time passes", func() {
+			projects, err := client.GetAllProjects()
+			Ω(err).Should(BeNil())
+			before, err := client.GetAllPipelines(projects[0])
+			Ω(err).Should(BeNil())
+			now = now.Add(time.Hour)
+			after, err := client.GetAllPipelines(projects[0])
+			Ω(err).Should(BeNil())
+			Ω(len(after)).Should(BeNumerically(">", len(before)))
+		})
+	})
+})
@@ -0,0 +1,416 @@
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+const (
+	statusSuccess = "success"
+	statusRunning = "running"
+	statusFailed  = "failed"
+	statusOnHold  = "on_hold"
+	statusNotRun  = "not_run"
+	statusBlocked = "blocked"
+)
+
+const (
+	triggerPush      = "webhook"
+	triggerScheduled = "scheduled_pipeline"
+)
+
+type job struct {
+	ID        string
+	Number    int
+	Name      string
+	Status    string
+	StartedAt *time.Time
+	StoppedAt *time.Time
+	Tests     circleci.TestResults
+}
+
+type workflow struct {
+	ID        string
+	Name      string
+	Status    string
+	CreatedAt time.Time
+	StoppedAt *time.Time
+	Jobs      []*job
+	failAt    int
+	holdAt    int
+	held      int
+	rerun     bool
+}
+
+type pipeline struct {
+	ID        string
+	Number    int
+	Branch    string
+	Tag       string
+	Trigger   string
+	Revision  string
+	CreatedAt time.Time
+	State     string
+	Errors    []circleci.PipelineError
+	Workflows []*workflow
+}
+
+type project struct {
+	VCSType   string
+	Username  string
+	Reponame  string
+	Branches  []string
+	Workflows map[string][]string
+	EnvVars   map[string]string
+	// ReleaseTags makes the project occasionally push a v1.N.0 tag, which
+	// runs the same workflows with no branch.
+	ReleaseTags bool
+	// Scheduled is a branch that also gets occasional scheduled pipelines.
+	Scheduled string
+	releases  int
+	pipelines []*pipeline
+	healthy   map[string]bool
+}
+
+func (p *project) slug() string {
+	return fmt.Sprintf("%s/%s/%s", p.VCSType, p.Username, p.Reponame)
+}
+
+func (p *project) running(branch string) bool {
+	for _, pipeline := range p.pipelines {
+		if pipeline.Branch != branch {
+			continue
+		}
+		for _, workflow := range pipeline.Workflows {
+			if workflow.StoppedAt == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func defaultProjects() []*project {
+	return []*project{
+		{
+			VCSType:  "github",
+			Username: "demo-org",
+			Reponame: "web-frontend",
+			Branches: []string{"master", "develop"},
+			Workflows: map[string][]string{
+				"build-and-test": {"install", "lint", "unit-test", "build"},
+			},
+			EnvVars:   map[string]string{"NPM_TOKEN": "npm-0123456789"},
+			Scheduled: "master",
+		},
+		{
+			VCSType:  "github",
+			Username: "demo-org",
+			Reponame: "payments-api",
+			Branches: []string{"master"},
+			Workflows: map[string][]string{
+				"test":   {"unit-test", "integration-test"},
+				"deploy": {"build-image", "approve-production", "deploy-production"},
+			},
+			EnvVars:     map[string]string{"DEPLOY_KEY": "deploy-abcdef", "DATABASE_URL": "postgres://demo"},
+			ReleaseTags: true,
+		},
+		{
+			VCSType:  "github",
+			Username: "demo-org",
+			Reponame: "infrastructure",
+			Branches: []string{"master"},
+			Workflows: map[string][]string{
+				"plan-and-apply": {"plan", "approve-apply", "apply"},
+			},
+			EnvVars: map[string]string{"DEPLOY_KEY": "deploy-abcdef"},
+		},
+		{
+			VCSType:  "bitbucket",
+			Username: "demo-team",
+			Reponame: "mobile-app",
+			Branches: []string{"master", "release"},
+			Workflows: map[string][]string{
+				"build": {"checkout", "test", "package"},
+			},
+			EnvVars: map[string]string{},
+		},
+	}
+}
+
+// simulation evolves projects over discrete steps: pipelines are started,
+// their jobs run one per step, pause on approvals, and either pass or fail.
+// Unhealthy branches tend to recover, healthy branches occasionally break,
+// some failed workflows are rerun, and now and then a pipeline fails to
+// compile its config.
+type simulation struct {
+	rand     *rand.Rand
+	now      func() time.Time
+	projects []*project
+	counter  int
+}
+
+func newSimulation(seed int64, now func() time.Time) *simulation {
+	s := &simulation{
+		rand:     rand.New(rand.NewSource(seed)),
+		now:      now,
+		projects: defaultProjects(),
+	}
+	for _, project := range s.projects {
+		project.healthy = map[string]bool{}
+		for _, branch := range project.Branches {
+			project.healthy[branch] = true
+			s.startPipeline(project, branch, "", triggerPush)
+		}
+		if project.ReleaseTags {
+			project.healthy[""] = true
+			s.startRelease(project)
+		}
+	}
+	return s
+}
+
+func (s *simulation) nextID(kind string) string {
+	s.counter++
+	return fmt.Sprintf("%s-%08d-0000-4000-8000-000000000000", kind, s.counter)
+}
+
+func (s *simulation) step() {
+	for _, project := range s.projects {
+		for _, pipeline := range project.pipelines {
+			for _, workflow := range pipeline.Workflows {
+				s.advanceWorkflow(project, pipeline, workflow)
+			}
+		}
+		for _, branch := range project.Branches {
+			if !project.running(branch) && s.rand.Float64() < 0.2 {
+				s.startPipeline(project, branch, "", triggerPush)
+			}
+		}
+		if project.Scheduled != "" && s.rand.Float64() < 0.05 {
+			s.startPipeline(project, project.Scheduled, "", triggerScheduled)
+		}
+		if project.ReleaseTags && !project.running("") && s.rand.Float64() < 0.05 {
+			s.startRelease(project)
+		}
+	}
+}
+
+func (s *simulation) startRelease(project *project) {
+	project.releases++
+	s.startPipeline(project, "", fmt.Sprintf("v1.%d.0", project.releases), triggerPush)
+}
+
+func (s *simulation) startPipeline(project *project, branch, tag, trigger string) {
+	now := s.now()
+	number := 1
+	if len(project.pipelines) > 0 {
+		number = project.pipelines[0].Number + 1
+	}
+	started := &pipeline{
+		ID:        s.nextID("pipeline"),
+		Number:    number,
+		Branch:    branch,
+		Tag:       tag,
+		Trigger:   trigger,
+		Revision:  fmt.Sprintf("%040x", s.rand.Int63()),
+		CreatedAt: now,
+		State:     "created",
+	}
+	project.pipelines = append([]*pipeline{started}, project.pipelines...)
+	if s.rand.Float64() < 0.05 {
+		started.State = circleci.PipelineStateErrored
+		started.Errors = []circleci.PipelineError{{
+			Type:    "config",
+			Message: "Config does not conform to schema: workflows > build > jobs: unknown job 'tset'",
+		}}
+		return
+	}
+	if s.rand.Float64() < 0.05 {
+		stopped := now
+		started.Workflows = []*workflow{{
+			ID:        s.nextID("workflow"),
+			Name:      "Build Error",
+			Status:    statusFailed,
+			CreatedAt: now,
+			StoppedAt: &stopped,
+		}}
+		return
+	}
+	failureChance := 0.15
+	if !project.healthy[branch] {
+		failureChance = 0.3
+	}
+	for _, name := range sortedKeys(project.Workflows) {
+		started.Workflows = append(started.Workflows, s.newWorkflow(name, project.Workflows[name], failureChance))
+	}
+}
+
+func (s *simulation) newWorkflow(name string, jobNames []string, failureChance float64) *workflow {
+	created := &workflow{
+		ID:        s.nextID("workflow"),
+		Name:      name,
+		Status:    statusRunning,
+		CreatedAt: s.now(),
+		failAt:    -1,
+		holdAt:    -1,
+	}
+	if s.rand.Float64() < failureChance {
+		created.failAt = s.rand.Intn(len(jobNames))
+	}
+	for index, jobName := range jobNames {
+		if len(jobName) > 8 && jobName[:8] == "approve-" {
+			created.holdAt = index
+		}
+		created.Jobs = append(created.Jobs, &job{
+			ID:     s.nextID("job"),
+			Number: s.counter,
+			Name:   jobName,
+			Status: statusBlocked,
+		})
+	}
+	return created
+}
+
+func (s *simulation) advanceWorkflow(project *project, pipeline *pipeline, workflow *workflow) {
+	if workflow.StoppedAt != nil {
+		return
+	}
+	now := s.now()
+	for index, job := range workflow.Jobs {
+		switch job.Status {
+		case statusSuccess:
+			continue
+		case statusBlocked:
+			if index == workflow.holdAt {
+				job.Status = statusOnHold
+				workflow.Status = statusOnHold
+				return
+			}
+			job.Status = statusRunning
+			job.StartedAt = &now
+			return
+		case statusOnHold:
+			workflow.held++
+			if workflow.held < 3 {
+				return
+			}
+			job.Status = statusSuccess
+			job.StartedAt = &now
+			job.StoppedAt = &now
+			workflow.Status = statusRunning
+			return
+		case statusRunning:
+			job.StoppedAt = &now
+			job.Tests = s.testResults(job.Name, index == workflow.failAt)
+			if index == workflow.failAt {
+				job.Status = statusFailed
+				for _, remaining := range workflow.Jobs[index+1:] {
+					remaining.Status = statusNotRun
+				}
+				s.finishWorkflow(project, pipeline, workflow, statusFailed)
+				return
+			}
+			job.Status = statusSuccess
+			if index == len(workflow.Jobs)-1 {
+				s.finishWorkflow(project, pipeline, workflow, statusSuccess)
+			}
+			return
+		}
+	}
+}
+
+func (s *simulation) finishWorkflow(project *project, pipeline *pipeline, finished *workflow, status string) {
+	now := s.now()
+	finished.Status = status
+	finished.StoppedAt = &now
+	project.healthy[pipeline.Branch] = status == statusSuccess
+	// Someone reruns some failures, which usually pass the second time.
+	if status == statusFailed && !finished.rerun && s.rand.Float64() < 0.3 {
+		rerun := s.newWorkflow(finished.Name, project.Workflows[finished.Name], 0.15)
+		rerun.rerun = true
+		pipeline.Workflows = append([]*workflow{rerun}, pipeline.Workflows...)
+	}
+}
+
+func (s *simulation) project(slug string) *project {
+	for _, project := range s.projects {
+		if project.slug() == slug {
+			return project
+		}
+	}
+	return nil
+}
+
+func (s *simulation) pipeline(id string) *pipeline {
+	for _, project := range s.projects {
+		for _, pipeline := range project.pipelines {
+			if pipeline.ID == id {
+				return pipeline
+			}
+		}
+	}
+	return nil
+}
+
+var testNames = []string{
+	"handles an empty basket",
+	"rejects an expired card",
+	"retries a timed out request",
+	"renders the account page",
+	"migrates the schema",
+}
+
+// testResults makes up a few tests for a job, some of them failing if the job
+// failed.
+func (s *simulation) testResults(jobName string, failed bool) circleci.TestResults {
+	var tests circleci.TestResults
+	for index, name := range testNames {
+		test := circleci.TestResult{
+			Name:      name,
+			Classname: jobName,
+			Result:    "success",
+			File:      fmt.Sprintf("%s_test.go", jobName),
+			RunTime:   float64(index+1) / 10,
+		}
+		if failed && (index == 0 || s.rand.Float64() < 0.3) {
+			test.Result = "failure"
+			test.Message = fmt.Sprintf("expected %d, got %d", index+1, 0)
+		}
+		tests = append(tests, test)
+	}
+	return tests
+}
+
+func (s *simulation) job(slug string, number int) *job {
+	project := s.project(slug)
+	if project == nil {
+		return nil
+	}
+	for _, pipeline := range project.pipelines {
+		for _, workflow := range pipeline.Workflows {
+			for _, job := range workflow.Jobs {
+				if job.Number == number {
+					return job
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *simulation) workflow(id string) *workflow {
+	for _, project := range s.projects {
+		for _, pipeline := range project.pipelines {
+			for _, workflow := range pipeline.Workflows {
+				if workflow.ID == id {
+					return workflow
+				}
+			}
+		}
+	}
+	return nil
+}
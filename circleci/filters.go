@@ -1,3 +1,23 @@
 package circleci
 
+import "path"
+
 type Filter map[string]interface{}
+
+// Matches reports whether the project's username/reponame equals, or matches
+// as a glob such as "myorg/*", any of the filter's keys. An empty filter
+// matches every project.
+func (f Filter) Matches(project Project) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for projectFilter := range f {
+		if project.Name() == projectFilter {
+			return true
+		}
+		if matched, err := path.Match(projectFilter, project.Name()); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
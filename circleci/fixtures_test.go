@@ -0,0 +1,88 @@
+package circleci_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/circleci/fake"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("Fixtures", func() {
+	var (
+		fakeServer   *fake.Server
+		fixtureDir   string
+		featureFlags = &dashboard.FeatureFlags{AnimatedBuildErrors: true}
+		filter       = &circleci.Filter{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		fakeServer = fake.NewServer(fake.Options{Seed: 1})
+		fixtureDir, err = ioutil.TempDir("", "circleci-fixtures")
+		Ω(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		fakeServer.Close()
+		os.RemoveAll(fixtureDir)
+	})
+
+	recordDashboard := func() dashboard.Monitors {
+		config := fakeServer.Config()
+		config.RecordDir = fixtureDir
+		client, err := circleci.NewClient(config)
+		Ω(err).Should(BeNil())
+		client.Client.SetDisableWarn(true)
+		monitors, err := dashboard.Build(client, filter, featureFlags, &dashboard.MonitorConfig{})
+		Ω(err).Should(BeNil())
+		return monitors
+	}
+
+	It("records every request with the token scrubbed", func() {
+		recordDashboard()
+		files, err := filepath.Glob(filepath.Join(fixtureDir, "*.json"))
+		Ω(err).Should(BeNil())
+		Ω(files).ShouldNot(BeEmpty())
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			Ω(err).Should(BeNil())
+			Ω(string(data)).ShouldNot(ContainSubstring(fake.DefaultToken))
+		}
+	})
+
+	It("stops recording when asked to", func() {
+		config := fakeServer.Config()
+		config.RecordDir = fixtureDir
+		client, err := circleci.NewClient(config)
+		Ω(err).Should(BeNil())
+		client.StopRecording()
+		_, err = client.GetAllProjects()
+		Ω(err).Should(BeNil())
+		files, err := filepath.Glob(filepath.Join(fixtureDir, "*.json"))
+		Ω(err).Should(BeNil())
+		Ω(files).Should(BeEmpty())
+	})
+
+	It("replays the recorded dashboard without calling CircleCI", func() {
+		recorded := recordDashboard()
+		fakeServer.Close()
+		client, err := circleci.NewClient(&circleci.Config{ReplayDir: fixtureDir, JobsURL: fakeServer.URL})
+		Ω(err).Should(BeNil())
+		replayed, err := dashboard.Build(client, filter, featureFlags, &dashboard.MonitorConfig{})
+		Ω(err).Should(BeNil())
+		Ω(replayed).Should(Equal(recorded))
+	})
+
+	It("errors when a request was not recorded", func() {
+		client, err := circleci.NewClient(&circleci.Config{ReplayDir: fixtureDir})
+		Ω(err).Should(BeNil())
+		_, err = client.GetAllProjects()
+		Ω(err).Should(MatchError(ContainSubstring("No recorded response for GET /api/v1.1/projects")))
+	})
+})
@@ -1,7 +1,53 @@
 package circleci
 
+import "time"
+
+var finishedJobStatuses = map[string]bool{
+	statusSuccess:         true,
+	statusFailed:          true,
+	statusCanceled:        true,
+	statusNotRunn:         true,
+	statusUnauthorized:    true,
+	"infrastructure_fail": true,
+	"timedout":            true,
+}
+
 type Job struct {
-	ID string `json:"id"`
+	ID        string     `json:"id"`
+	JobNumber int        `json:"job_number,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Type      string     `json:"type,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+}
+
+func (j Job) Finished() bool {
+	return finishedJobStatuses[j.Status]
 }
 
 type Jobs []Job
+
+// Finished counts the jobs that will not run any further.
+func (j Jobs) Finished() int {
+	var finished int
+	for _, job := range j {
+		if job.Finished() {
+			finished++
+		}
+	}
+	return finished
+}
+
+// Current returns the job the workflow is waiting on: the first running job,
+// or failing that the first job on hold for an approval.
+func (j Jobs) Current() (Job, bool) {
+	for _, status := range []string{statusRunning, statusOnHold} {
+		for _, job := range j {
+			if job.Status == status {
+				return job, true
+			}
+		}
+	}
+	return Job{}, false
+}
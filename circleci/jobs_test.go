@@ -0,0 +1,43 @@
+package circleci_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+var _ = Describe("Jobs", func() {
+	var jobs = circleci.Jobs{
+		{Name: "build", Status: "success"},
+		{Name: "lint", Status: "failed"},
+		{Name: "approve", Status: "on_hold"},
+		{Name: "test", Status: "running"},
+		{Name: "deploy", Status: "blocked"},
+	}
+
+	Describe("#Finished", func() {
+		It("counts the jobs that will not run any further", func() {
+			Ω(jobs.Finished()).Should(Equal(2))
+		})
+	})
+
+	Describe("#Current", func() {
+		It("prefers a running job to one on hold", func() {
+			job, ok := jobs.Current()
+			Ω(ok).Should(BeTrue())
+			Ω(job.Name).Should(Equal("test"))
+		})
+
+		It("falls back to a job on hold", func() {
+			job, ok := jobs[:3].Current()
+			Ω(ok).Should(BeTrue())
+			Ω(job.Name).Should(Equal("approve"))
+		})
+
+		It("returns false when nothing is waiting", func() {
+			_, ok := jobs[:2].Current()
+			Ω(ok).Should(BeFalse())
+		})
+	})
+})
@@ -82,6 +82,25 @@ const pipeline_resp_3 = `{
 	"next_page_token": null
 }`
 
+const tag_pipeline_resp = `{
+	"next_page_token": null,
+	"items": [
+		{
+			"id": "1",
+			"vcs": {
+				"branch": "master"
+			}
+		},
+		{
+			"id": "2",
+			"state": "created",
+			"vcs": {
+				"tag": "v1.2.0"
+			}
+		}
+	]
+}`
+
 const workflows_resp_single_page = `{
 	"next_page_token": null,
 	"items": [
@@ -205,6 +224,28 @@ const workflow_resp_previous_state_3 = `{
 	]
 }`
 
+const workflow_resp_previous_state_running = `{
+	"next_page_token": null,
+	"items": [
+		{
+			"ID": "1",
+			"Name": "previous_status",
+			"Status": "running"
+		}
+	]
+}`
+
+const workflow_resp_previous_state_failed = `{
+	"next_page_token": null,
+	"items": [
+		{
+			"ID": "3",
+			"Name": "previous_status",
+			"Status": "failed"
+		}
+	]
+}`
+
 const projecteEnvVarResp = `{
   "next_page_token" : null,
   "items" : [ {
@@ -229,3 +270,23 @@ const projecteEnvVarResp = `{
 }`
 
 const createEnvVarResp = `{"name":"foo", "value": "xxxxxxx"}`
+
+const tests_resp = `{
+	"next_page_token": null,
+	"items": [
+		{
+			"name": "renders the dashboard",
+			"classname": "dashboard_test",
+			"result": "success",
+			"message": "",
+			"run_time": 0.12
+		},
+		{
+			"name": "loads the config",
+			"classname": "config_test",
+			"result": "failure",
+			"message": "expected true to be false",
+			"run_time": 0.03
+		}
+	]
+}`
@@ -1,13 +1,82 @@
 package circleci
 
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Pipeline states that mean CircleCI could not turn the config into
+// workflows, e.g. invalid YAML or a failed setup workflow.
+const (
+	PipelineStateErrored = "errored"
+	PipelineStateFailed  = "failed"
+)
+
+// Trigger sources that monitors can be split by. Scheduled pipelines are
+// those run by a schedule, everything else counts as a push.
+const (
+	TriggerSourceScheduled = "scheduled"
+	TriggerSourcePush      = "push"
+)
+
+var scheduledTriggerTypes = map[string]bool{
+	"scheduled_pipeline": true,
+	"schedule":           true,
+}
+
+type Trigger struct {
+	Type string `json:"type"`
+}
+
 type VCS struct {
-	Branch string `json:"branch"`
+	Branch   string `json:"branch"`
+	Tag      string `json:"tag,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+type PipelineError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
 
 type Pipeline struct {
-	ID     string `json:"id"`
-	Number int    `json:"number"`
-	VCS    VCS    `json:"vcs"`
+	ID        string          `json:"id"`
+	Number    int             `json:"number"`
+	State     string          `json:"state,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Errors    []PipelineError `json:"errors,omitempty"`
+	Trigger   Trigger         `json:"trigger"`
+	VCS       VCS             `json:"vcs"`
+}
+
+// TriggerSource is TriggerSourceScheduled or TriggerSourcePush.
+func (p Pipeline) TriggerSource() string {
+	if scheduledTriggerTypes[p.Trigger.Type] {
+		return TriggerSourceScheduled
+	}
+	return TriggerSourcePush
+}
+
+// ConfigError reports whether the pipeline never got as far as running
+// workflows because its config could not be compiled or set up.
+func (p Pipeline) ConfigError() bool {
+	return p.State == PipelineStateErrored || p.State == PipelineStateFailed || len(p.Errors) > 0
+}
+
+// ErrorMessage joins the messages of the pipeline's errors.
+func (p Pipeline) ErrorMessage() string {
+	var messages []string
+	for _, pipelineError := range p.Errors {
+		messages = append(messages, pipelineError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// TagOnly reports whether the pipeline was triggered by a git tag rather than
+// a push to a branch.
+func (p Pipeline) TagOnly() bool {
+	return p.VCS.Tag != "" && p.VCS.Branch == ""
 }
 
 type Pipelines []Pipeline
@@ -15,6 +84,9 @@ type Pipelines []Pipeline
 func (p Pipelines) FilteredPerBranch(branchFilter string) map[string]Pipelines {
 	filteredPipelines := make(map[string]Pipelines)
 	for _, pipeline := range p {
+		if pipeline.TagOnly() {
+			continue
+		}
 		if pipeline.VCS.Branch != branchFilter && branchFilter != "" {
 			continue
 		}
@@ -28,6 +100,9 @@ func (p Pipelines) FilteredPerBranch(branchFilter string) map[string]Pipelines {
 func (p Pipelines) LatestPerBranch() map[string]Pipeline {
 	latestPipelines := make(map[string]Pipeline)
 	for _, pipeline := range p {
+		if pipeline.TagOnly() {
+			continue
+		}
 		if _, ok := latestPipelines[pipeline.VCS.Branch]; ok {
 			continue
 		}
@@ -35,3 +110,36 @@ func (p Pipelines) LatestPerBranch() map[string]Pipeline {
 	}
 	return latestPipelines
 }
+
+// MatchingTag returns the tag pipelines whose tag matches a glob such as
+// "v*", newest first. Unlike a path, a tag has no segments, so "*" also
+// matches "/", as in "release/1.2".
+func (p Pipelines) MatchingTag(pattern string) Pipelines {
+	var matching Pipelines
+	for _, pipeline := range p {
+		if pipeline.VCS.Tag == "" {
+			continue
+		}
+		if matchTag(pattern, pipeline.VCS.Tag) {
+			matching = append(matching, pipeline)
+		}
+	}
+	return matching
+}
+
+// matchTag matches a tag against a glob with path.Match, swapping "/", which
+// path.Match will not let "*" or "?" match, for NUL, which no tag contains.
+func matchTag(pattern, tag string) bool {
+	matched, err := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(tag, "/", "\x00"))
+	return err == nil && matched
+}
+
+// PerTriggerSource groups pipelines by TriggerSource, keeping their order.
+func (p Pipelines) PerTriggerSource() map[string]Pipelines {
+	perSource := make(map[string]Pipelines)
+	for _, pipeline := range p {
+		source := pipeline.TriggerSource()
+		perSource[source] = append(perSource[source], pipeline)
+	}
+	return perSource
+}
@@ -57,4 +57,82 @@ var _ = Describe("Pipelines", func() {
 			Ω(filteredPipelines["develop"].ID).Should(Equal("4"))
 		})
 	})
+
+	Describe("tag pipelines", func() {
+		var tagged = append(circleci.Pipelines{
+			{ID: "10", VCS: circleci.VCS{Tag: "v1.1.0"}},
+			{ID: "11", VCS: circleci.VCS{Tag: "nightly-20200901"}},
+			{ID: "12", VCS: circleci.VCS{Tag: "v1.0.0"}},
+		}, pipelines...)
+
+		It("are not treated as an empty branch", func() {
+			Ω(tagged.LatestPerBranch()).ShouldNot(HaveKey(""))
+			Ω(tagged.FilteredPerBranch("")).ShouldNot(HaveKey(""))
+		})
+
+		It("can be matched by a tag pattern, newest first", func() {
+			Ω(tagged.MatchingTag("v*")).Should(Equal(circleci.Pipelines{tagged[0], tagged[2]}))
+			Ω(tagged.MatchingTag("*")).Should(HaveLen(3))
+			Ω(tagged.MatchingTag("release-*")).Should(BeEmpty())
+		})
+
+		It("match tags with slashes in them, as a tag is not a path", func() {
+			slashed := circleci.Pipelines{
+				{ID: "20", VCS: circleci.VCS{Tag: "release/1.2"}},
+				{ID: "21", VCS: circleci.VCS{Tag: "release/1.1/hotfix"}},
+			}
+			Ω(slashed.MatchingTag("*")).Should(HaveLen(2))
+			Ω(slashed.MatchingTag("release/*")).Should(HaveLen(2))
+			Ω(slashed.MatchingTag("release?1.2")).Should(Equal(circleci.Pipelines{slashed[0]}))
+			Ω(slashed.MatchingTag("hotfix/*")).Should(BeEmpty())
+		})
+	})
+
+	Describe("trigger sources", func() {
+		var triggered = circleci.Pipelines{
+			{ID: "1", Trigger: circleci.Trigger{Type: "webhook"}},
+			{ID: "2", Trigger: circleci.Trigger{Type: "scheduled_pipeline"}},
+			{ID: "3", Trigger: circleci.Trigger{Type: "api"}},
+			{ID: "4", Trigger: circleci.Trigger{Type: "schedule"}},
+		}
+
+		It("treats scheduled pipelines as scheduled and everything else as a push", func() {
+			Ω(triggered[0].TriggerSource()).Should(Equal(circleci.TriggerSourcePush))
+			Ω(triggered[1].TriggerSource()).Should(Equal(circleci.TriggerSourceScheduled))
+			Ω(triggered[2].TriggerSource()).Should(Equal(circleci.TriggerSourcePush))
+			Ω(triggered[3].TriggerSource()).Should(Equal(circleci.TriggerSourceScheduled))
+		})
+
+		It("groups pipelines by trigger source in order", func() {
+			Ω(triggered.PerTriggerSource()).Should(Equal(map[string]circleci.Pipelines{
+				circleci.TriggerSourcePush:      {triggered[0], triggered[2]},
+				circleci.TriggerSourceScheduled: {triggered[1], triggered[3]},
+			}))
+		})
+	})
+
+	Describe("#ConfigError", func() {
+		It("is false for a pipeline that created workflows", func() {
+			Ω(circleci.Pipeline{State: "created"}.ConfigError()).Should(BeFalse())
+		})
+
+		It("is true for an errored or failed pipeline", func() {
+			Ω(circleci.Pipeline{State: circleci.PipelineStateErrored}.ConfigError()).Should(BeTrue())
+			Ω(circleci.Pipeline{State: circleci.PipelineStateFailed}.ConfigError()).Should(BeTrue())
+		})
+
+		It("is true for a pipeline with errors", func() {
+			Ω(circleci.Pipeline{State: "created", Errors: []circleci.PipelineError{{Type: "config", Message: "bad"}}}.ConfigError()).Should(BeTrue())
+		})
+	})
+
+	Describe("#ErrorMessage", func() {
+		It("joins the messages of every error", func() {
+			pipeline := circleci.Pipeline{Errors: []circleci.PipelineError{
+				{Type: "config", Message: "Unknown key 'jbos'"},
+				{Type: "config-fetch", Message: "Could not fetch orb"},
+			}}
+			Ω(pipeline.ErrorMessage()).Should(Equal("Unknown key 'jbos'; Could not fetch orb"))
+		})
+	})
 })
@@ -32,10 +32,8 @@ func (p Projects) Filter(filter *Filter) Projects {
 	}
 	var keepProjects Projects
 	for _, project := range p {
-		for projectFitler, _ := range *filter {
-			if project.Name() == projectFitler {
-				keepProjects = append(keepProjects, project)
-			}
+		if filter.Matches(project) {
+			keepProjects = append(keepProjects, project)
 		}
 	}
 	return keepProjects
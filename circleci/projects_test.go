@@ -69,5 +69,12 @@ var _ = Describe("Projects", func() {
 				Ω(filteredProjects).Should(HaveLen(2))
 			})
 		})
+
+		Context("if the filter contains globs", func() {
+			It("returns the projects matching the globs", func() {
+				filteredProjects := projects.Filter(&circleci.Filter{"foobar/*": nil, "*/fish": nil})
+				Ω(filteredProjects).Should(Equal(circleci.Projects{projects[0], projects[2], projects[3]}))
+			})
+		})
 	})
 })
@@ -0,0 +1,30 @@
+package circleci
+
+// TestResult is one test from the metadata CircleCI collects with
+// store_test_results.
+type TestResult struct {
+	Name      string  `json:"name"`
+	Classname string  `json:"classname"`
+	Result    string  `json:"result"`
+	Message   string  `json:"message"`
+	File      string  `json:"file"`
+	Source    string  `json:"source"`
+	RunTime   float64 `json:"run_time"`
+}
+
+// Failed reports whether the test failed or errored.
+func (t TestResult) Failed() bool {
+	return t.Result == "failure" || t.Result == "error"
+}
+
+type TestResults []TestResult
+
+func (t TestResults) Failed() TestResults {
+	var failed TestResults
+	for _, test := range t {
+		if test.Failed() {
+			failed = append(failed, test)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,22 @@
+package circleci_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+var _ = Describe("TestResults", func() {
+	Describe("#Failed", func() {
+		It("keeps failures and errors", func() {
+			tests := circleci.TestResults{
+				{Name: "passes", Result: "success"},
+				{Name: "fails", Result: "failure"},
+				{Name: "skipped", Result: "skipped"},
+				{Name: "errors", Result: "error"},
+			}
+			Ω(tests.Failed()).Should(Equal(circleci.TestResults{tests[1], tests[3]}))
+		})
+	})
+})
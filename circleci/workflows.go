@@ -1,9 +1,22 @@
 package circleci
 
+import "time"
+
 type Workflow struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+}
+
+// Duration is how long a finished workflow took, or false if it has not
+// finished.
+func (w Workflow) Duration() (time.Duration, bool) {
+	if w.StoppedAt == nil || w.CreatedAt.IsZero() {
+		return 0, false
+	}
+	return w.StoppedAt.Sub(w.CreatedAt), true
 }
 
 type Workflows []Workflow
@@ -0,0 +1,93 @@
+// Package cluster lets several replicas of the dashboard share the work: one
+// replica, the leader, collects from CircleCI and the others serve what it
+// collected.
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+// Election decides which replica is the leader.
+type Election interface {
+	// Campaign takes the lead if it is free, or renews it if this replica
+	// holds it. It returns whether this replica leads, and the address of
+	// the replica that does.
+	Campaign() (leading bool, leader string, err error)
+	// Interval is how often to campaign to keep the lead.
+	Interval() time.Duration
+}
+
+// Single is the Election of a dashboard without replicas, which always leads.
+type Single struct{}
+
+func (Single) Campaign() (bool, string, error) {
+	return true, "", nil
+}
+
+func (Single) Interval() time.Duration {
+	return time.Hour
+}
+
+// State is the outcome of the latest campaign.
+type State struct {
+	Election Election
+
+	mu      sync.Mutex
+	leading bool
+	leader  string
+	err     error
+}
+
+func NewState(election Election) *State {
+	return &State{Election: election}
+}
+
+// Campaign runs a campaign and records its outcome. A replica that cannot
+// reach the election follows, so that two replicas never both collect.
+func (s *State) Campaign() {
+	leading, leader, err := s.Election.Campaign()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leading = leading && err == nil
+	s.leader = leader
+	s.err = err
+}
+
+// Run campaigns on the election's interval until stop is closed, calling
+// onChange whenever this replica gains or loses the lead.
+func (s *State) Run(stop <-chan struct{}, onChange func(leading bool)) {
+	ticker := time.NewTicker(s.Election.Interval())
+	defer ticker.Stop()
+	for {
+		was := s.Leading()
+		s.Campaign()
+		if now := s.Leading(); now != was && onChange != nil {
+			onChange(now)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *State) Leading() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leading
+}
+
+// Leader is the address of the leader, if known.
+func (s *State) Leader() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+func (s *State) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,57 @@
+package cluster_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/cluster"
+)
+
+type fakeElection struct {
+	leading bool
+	leader  string
+	err     error
+}
+
+func (e *fakeElection) Campaign() (bool, string, error) {
+	return e.leading, e.leader, e.err
+}
+
+func (e *fakeElection) Interval() time.Duration {
+	return 10 * time.Millisecond
+}
+
+var _ = Describe("State", func() {
+	It("always leads without replicas", func() {
+		state := cluster.NewState(cluster.Single{})
+		state.Campaign()
+		Ω(state.Leading()).Should(BeTrue())
+	})
+
+	It("records the leader", func() {
+		state := cluster.NewState(&fakeElection{leader: "http://other:8080"})
+		state.Campaign()
+		Ω(state.Leading()).Should(BeFalse())
+		Ω(state.Leader()).Should(Equal("http://other:8080"))
+	})
+
+	It("follows when the election errors", func() {
+		state := cluster.NewState(&fakeElection{leading: true, err: fmt.Errorf("Error reaching Redis")})
+		state.Campaign()
+		Ω(state.Leading()).Should(BeFalse())
+		Ω(state.Err()).Should(MatchError("Error reaching Redis"))
+	})
+
+	It("calls onChange when the lead changes hands", func() {
+		changes := make(chan bool, 10)
+		state := cluster.NewState(&fakeElection{leading: true})
+		stop := make(chan struct{})
+		defer close(stop)
+		go state.Run(stop, func(leading bool) { changes <- leading })
+		Eventually(changes).Should(Receive(BeTrue()))
+		Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+	})
+})
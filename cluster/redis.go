@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+// renewScript extends the lease only if this replica still holds it.
+const renewScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`
+
+// NewRedisClient connects to a redis:// URL.
+func NewRedisClient(redisURL string) (*redis.Client, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing Redis URL: %v", err)
+	}
+	return redis.NewClient(options), nil
+}
+
+// SharedSecret returns a secret shared by every replica using the Redis,
+// creating it the first time.
+func SharedSecret(client *redis.Client, prefix string) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := prefix + ":secret"
+	if err := client.SetNX(key, secret, 0).Err(); err != nil {
+		return nil, fmt.Errorf("Error sharing the replicas' secret: %v", err)
+	}
+	shared, err := client.Get(key).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("Error sharing the replicas' secret: %v", err)
+	}
+	return shared, nil
+}
+
+// RedisElection elects the leader with a lease in Redis, holding the address
+// of the replica that took it.
+type RedisElection struct {
+	Client *redis.Client
+	Key    string
+	// ID is this replica's address, at which the others can reach it.
+	ID    string
+	Lease time.Duration
+}
+
+func NewRedisElection(client *redis.Client, prefix, id string, lease time.Duration) *RedisElection {
+	return &RedisElection{Client: client, Key: prefix + ":leader", ID: id, Lease: lease}
+}
+
+func (e *RedisElection) Campaign() (bool, string, error) {
+	taken, err := e.Client.SetNX(e.Key, e.ID, e.Lease).Result()
+	if err != nil {
+		return false, "", err
+	}
+	if taken {
+		return true, e.ID, nil
+	}
+	renewed, err := e.Client.Eval(renewScript, []string{e.Key}, e.ID, e.Lease.Milliseconds()).Int()
+	if err != nil {
+		return false, "", err
+	}
+	if renewed == 1 {
+		return true, e.ID, nil
+	}
+	leader, err := e.Client.Get(e.Key).Result()
+	if err == redis.Nil {
+		return false, "", nil
+	}
+	return false, leader, err
+}
+
+// Interval renews the lease well before it runs out.
+func (e *RedisElection) Interval() time.Duration {
+	return e.Lease / 3
+}
+
+// sharedSnapshot carries a snapshot's errors, which are not otherwise saved,
+// as every replica shows them.
+type sharedSnapshot struct {
+	dashboard.Snapshot
+	Stale        bool          `json:"stale"`
+	Err          string        `json:"err,omitempty"`
+	SourceErrors []sourceError `json:"source_errors,omitempty"`
+}
+
+type sourceError struct {
+	Source string `json:"source"`
+	Err    string `json:"err"`
+}
+
+// RedisSnapshots keeps the latest snapshot in Redis for every replica to
+// serve. If Redis cannot be reached it serves the last snapshot it read, as
+// stale.
+type RedisSnapshots struct {
+	Client *redis.Client
+	Key    string
+
+	mu   sync.Mutex
+	last *dashboard.Snapshot
+}
+
+func NewRedisSnapshots(client *redis.Client, prefix string) *RedisSnapshots {
+	return &RedisSnapshots{Client: client, Key: prefix + ":snapshot"}
+}
+
+func (s *RedisSnapshots) Get() (dashboard.Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := s.Client.Get(s.Key).Bytes()
+	if err == redis.Nil {
+		return dashboard.Snapshot{}, false
+	}
+	var shared sharedSnapshot
+	if err == nil {
+		err = json.Unmarshal(data, &shared)
+	}
+	if err != nil {
+		if s.last == nil {
+			return dashboard.Snapshot{}, false
+		}
+		last := *s.last
+		last.Stale = true
+		return last, true
+	}
+	snapshot := shared.Snapshot
+	snapshot.Stale = shared.Stale
+	if shared.Err != "" {
+		snapshot.Err = errors.New(shared.Err)
+	}
+	for _, sourceErr := range shared.SourceErrors {
+		snapshot.SourceErrors = append(snapshot.SourceErrors, dashboard.SourceError{Source: sourceErr.Source, Err: errors.New(sourceErr.Err)})
+	}
+	s.last = &snapshot
+	return snapshot, true
+}
+
+func (s *RedisSnapshots) Set(snapshot dashboard.Snapshot) error {
+	if snapshot.Err != nil {
+		if previous, found := s.Get(); found {
+			snapshot.Monitors = previous.Monitors
+			snapshot.TakenAt = previous.TakenAt
+			snapshot.Stale = true
+		}
+	}
+	shared := sharedSnapshot{Snapshot: snapshot, Stale: snapshot.Stale}
+	if snapshot.Err != nil {
+		shared.Err = snapshot.Err.Error()
+	}
+	for _, sourceErr := range snapshot.SourceErrors {
+		shared.SourceErrors = append(shared.SourceErrors, sourceError{Source: sourceErr.Source, Err: sourceErr.Err.Error()})
+	}
+	data, err := json.Marshal(shared)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(s.Key, data, 0).Err()
+}
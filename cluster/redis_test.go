@@ -0,0 +1,155 @@
+package cluster_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/cluster"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("Redis", func() {
+	var (
+		server *miniredis.Miniredis
+		client *redis.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		server, err = miniredis.Run()
+		Ω(err).Should(BeNil())
+		client, err = cluster.NewRedisClient("redis://" + server.Addr())
+		Ω(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+
+	It("rejects a URL that is not Redis", func() {
+		_, err := cluster.NewRedisClient("http://localhost")
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(HavePrefix("Error parsing Redis URL"))
+	})
+
+	Describe("SharedSecret", func() {
+		It("gives every replica the same secret", func() {
+			first, err := cluster.SharedSecret(client, "test")
+			Ω(err).Should(BeNil())
+			Ω(first).Should(HaveLen(32))
+			second, err := cluster.SharedSecret(client, "test")
+			Ω(err).Should(BeNil())
+			Ω(second).Should(Equal(first))
+		})
+	})
+
+	Describe("RedisElection", func() {
+		var first, second *cluster.RedisElection
+
+		BeforeEach(func() {
+			first = cluster.NewRedisElection(client, "test", "http://first:8080", 15*time.Second)
+			second = cluster.NewRedisElection(client, "test", "http://second:8080", 15*time.Second)
+		})
+
+		It("lets one replica lead", func() {
+			leading, leader, err := first.Campaign()
+			Ω(err).Should(BeNil())
+			Ω(leading).Should(BeTrue())
+			Ω(leader).Should(Equal("http://first:8080"))
+
+			leading, leader, err = second.Campaign()
+			Ω(err).Should(BeNil())
+			Ω(leading).Should(BeFalse())
+			Ω(leader).Should(Equal("http://first:8080"))
+		})
+
+		It("keeps the lead while the leader renews it", func() {
+			first.Campaign()
+			server.FastForward(10 * time.Second)
+			leading, _, _ := first.Campaign()
+			Ω(leading).Should(BeTrue())
+			server.FastForward(10 * time.Second)
+			leading, _, _ = second.Campaign()
+			Ω(leading).Should(BeFalse())
+		})
+
+		It("hands over the lead when the leader stops renewing it", func() {
+			first.Campaign()
+			server.FastForward(16 * time.Second)
+			leading, leader, err := second.Campaign()
+			Ω(err).Should(BeNil())
+			Ω(leading).Should(BeTrue())
+			Ω(leader).Should(Equal("http://second:8080"))
+
+			leading, _, _ = first.Campaign()
+			Ω(leading).Should(BeFalse())
+		})
+
+		It("errors when Redis cannot be reached", func() {
+			server.Close()
+			leading, _, err := first.Campaign()
+			Ω(err).Should(HaveOccurred())
+			Ω(leading).Should(BeFalse())
+		})
+	})
+
+	Describe("RedisSnapshots", func() {
+		var (
+			takenAt  = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+			monitors = dashboard.Monitors{{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "success"}}
+			leader   *cluster.RedisSnapshots
+			follower *cluster.RedisSnapshots
+		)
+
+		BeforeEach(func() {
+			leader = cluster.NewRedisSnapshots(client, "test")
+			follower = cluster.NewRedisSnapshots(client, "test")
+		})
+
+		It("has nothing before the first snapshot", func() {
+			_, found := follower.Get()
+			Ω(found).Should(BeFalse())
+		})
+
+		It("shares snapshots between replicas", func() {
+			Ω(leader.Set(dashboard.Snapshot{
+				Monitors:     monitors,
+				TakenAt:      takenAt,
+				SourceErrors: dashboard.SourceErrors{{Source: "server", Err: fmt.Errorf("Error getting projects")}},
+			})).Should(Succeed())
+			snapshot, found := follower.Get()
+			Ω(found).Should(BeTrue())
+			Ω(snapshot.Monitors).Should(Equal(monitors))
+			Ω(snapshot.TakenAt.Equal(takenAt)).Should(BeTrue())
+			Ω(snapshot.Stale).Should(BeFalse())
+			Ω(snapshot.SourceErrors).Should(HaveLen(1))
+			Ω(snapshot.SourceErrors[0].Error()).Should(Equal("server: Error getting projects"))
+		})
+
+		It("keeps the previous monitors, as stale, when a refresh errors", func() {
+			Ω(leader.Set(dashboard.Snapshot{Monitors: monitors, TakenAt: takenAt})).Should(Succeed())
+			Ω(leader.Set(dashboard.Snapshot{TakenAt: takenAt.Add(time.Minute), Err: fmt.Errorf("Error getting projects")})).Should(Succeed())
+			snapshot, _ := follower.Get()
+			Ω(snapshot.Monitors).Should(Equal(monitors))
+			Ω(snapshot.TakenAt.Equal(takenAt)).Should(BeTrue())
+			Ω(snapshot.Stale).Should(BeTrue())
+			Ω(snapshot.Err).Should(MatchError("Error getting projects"))
+		})
+
+		It("serves the last snapshot it read, as stale, when Redis cannot be reached", func() {
+			Ω(leader.Set(dashboard.Snapshot{Monitors: monitors, TakenAt: takenAt})).Should(Succeed())
+			follower.Get()
+			server.Close()
+			snapshot, found := follower.Get()
+			Ω(found).Should(BeTrue())
+			Ω(snapshot.Monitors).Should(Equal(monitors))
+			Ω(snapshot.Stale).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/terminal"
+)
+
+const (
+	exitOK      = 0
+	exitFailing = 1
+	exitError   = 2
+)
+
+func selectorFlags(flags *flag.FlagSet) *terminal.Selector {
+	selector := &terminal.Selector{}
+	flags.StringVar(&selector.Project, "project", "", "Only include projects matching this glob, e.g. 'myorg/*'")
+	flags.StringVar(&selector.Workflow, "workflow", "", "Only include workflows matching this glob")
+	flags.StringVar(&selector.Branch, "branch", "", "Only include branches matching this glob")
+	flags.StringVar(&selector.Team, "team", "", "Only include monitors owned by a team matching this glob")
+	return selector
+}
+
+// selectProjects moves --project onto the sources, so that only the selected
+// projects are built, and matches it against their username/reponame rather
+// than the tile name, which HIDE_ORGANIZATION shortens.
+func selectProjects(sources []dashboard.Source, selector *terminal.Selector) []dashboard.Source {
+	sources = dashboard.SelectProjects(sources, selector.Project)
+	selector.Project = ""
+	return sources
+}
+
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// status prints the selected monitors once and exits non-zero if any of them
+// are red, so scripts can gate on e.g. "master is green everywhere".
+func status(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	format := flags.String("format", terminal.FormatTable, "Output format: table, json or csv")
+	demo := flags.Bool("demo", false, "Use a built-in fake CircleCI instead of the real API")
+	noColour := flags.Bool("no-colour", false, "Do not colour the table")
+	selector := selectorFlags(flags)
+	flags.Parse(args)
+	if !terminal.ValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, must be one of table, json or csv\n", *format)
+		return exitError
+	}
+	dashboardSources, closer, err := newSources(*demo)
+	defer closer()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	monitorConfig, err := getMonitorConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	dashboardSources = selectProjects(dashboardSources, selector)
+	monitors, buildErr := dashboard.BuildSources(context.Background(), dashboardSources, getDashboardFeatureFlags(), monitorConfig)
+	if buildErr != nil && len(monitors) == 0 {
+		fmt.Fprintln(os.Stderr, buildErr)
+		return exitError
+	}
+	monitors = selector.Select(monitors)
+	if err := terminal.Render(os.Stdout, monitors, *format, stdoutIsTerminal() && !*noColour); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	// Some sources failing is still an error, after showing what the others
+	// could.
+	if buildErr != nil {
+		fmt.Fprintln(os.Stderr, buildErr)
+		return exitError
+	}
+	if len(terminal.Failing(monitors)) > 0 {
+		return exitFailing
+	}
+	return exitOK
+}
+
+// watch redraws the selected monitors in the terminal until interrupted.
+func watch(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Int("interval", getRefershInterval(), "Seconds between refreshes")
+	demo := flags.Bool("demo", false, "Use a built-in fake CircleCI instead of the real API")
+	noColour := flags.Bool("no-colour", false, "Do not colour the table")
+	selector := selectorFlags(flags)
+	flags.Parse(args)
+	dashboardSources, closer, err := newSources(*demo)
+	defer closer()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	featureFlags := getDashboardFeatureFlags()
+	monitorConfig, err := getMonitorConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	dashboardSources = selectProjects(dashboardSources, selector)
+	build := func() (dashboard.Monitors, error) {
+		return dashboard.BuildSources(context.Background(), dashboardSources, featureFlags, monitorConfig)
+	}
+	stop := make(chan struct{})
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		close(stop)
+	}()
+	if err := terminal.Watch(os.Stdout, build, *selector, time.Duration(*interval)*time.Second, !*noColour, stop); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
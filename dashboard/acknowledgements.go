@@ -0,0 +1,124 @@
+package dashboard
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Acknowledgement is someone owning up to a red monitor, so that it is muted
+// on the dashboard and not notified about until it goes green or expires.
+type Acknowledgement struct {
+	Note      string    `json:"note"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (a Acknowledgement) Expired(now time.Time) bool {
+	return !now.Before(a.ExpiresAt)
+}
+
+// Acknowledgements holds the acknowledgements of red monitors, keyed by
+// Monitor.ID. If they were loaded from a file, every change is saved back to
+// it.
+type Acknowledgements struct {
+	mu       sync.Mutex
+	filename string
+	acks     map[string]Acknowledgement
+}
+
+// NewAcknowledgements keeps the acknowledgements in memory only.
+func NewAcknowledgements() *Acknowledgements {
+	return &Acknowledgements{acks: map[string]Acknowledgement{}}
+}
+
+// LoadAcknowledgements reads the acknowledgements saved in filename, which
+// need not exist yet.
+func LoadAcknowledgements(filename string) (*Acknowledgements, error) {
+	acknowledgements := NewAcknowledgements()
+	acknowledgements.filename = filename
+	if err := acknowledgements.Reload(); err != nil {
+		return nil, err
+	}
+	return acknowledgements, nil
+}
+
+// Reload reads the acknowledgements back from their file, replacing those
+// held in memory.
+func (a *Acknowledgements) Reload() error {
+	if a.filename == "" {
+		return nil
+	}
+	acks := map[string]Acknowledgement{}
+	if err := loadJSON(a.filename, &acks); err != nil {
+		return fmt.Errorf("Error loading acknowledgements from %s: %v", a.filename, err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acks = acks
+	return nil
+}
+
+// Acknowledge acknowledges a red monitor, replacing any earlier
+// acknowledgement of it.
+func (a *Acknowledgements) Acknowledge(monitor Monitor, ack Acknowledgement) error {
+	if !monitor.Failed() {
+		return fmt.Errorf("Only red monitors can be acknowledged")
+	}
+	if ack.Note == "" || ack.Owner == "" {
+		return fmt.Errorf("An acknowledgement needs a note and an owner")
+	}
+	if !ack.ExpiresAt.After(ack.CreatedAt) {
+		return fmt.Errorf("An acknowledgement must expire after it is made")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acks[monitor.ID()] = ack
+	return a.save()
+}
+
+// Clear removes the acknowledgement of the monitor with the given ID.
+func (a *Acknowledgements) Clear(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.acks[id]; !ok {
+		return nil
+	}
+	delete(a.acks, id)
+	return a.save()
+}
+
+// Apply sets Acknowledgement on each red monitor that has one, and drops the
+// acknowledgements of monitors that have gone green or that have expired. It
+// can be applied again to monitors it has already been applied to.
+func (a *Acknowledgements) Apply(monitors Monitors, now time.Time) (Monitors, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var changed bool
+	applied := make(Monitors, len(monitors))
+	for index, monitor := range monitors {
+		monitor.Acknowledgement = nil
+		id := monitor.ID()
+		if ack, ok := a.acks[id]; ok {
+			if monitor.Failed() && !ack.Expired(now) {
+				monitor.Acknowledgement = &ack
+			} else {
+				delete(a.acks, id)
+				changed = true
+			}
+		}
+		applied[index] = monitor
+	}
+	if !changed {
+		return applied, nil
+	}
+	return applied, a.save()
+}
+
+func (a *Acknowledgements) save() error {
+	if a.filename == "" {
+		return nil
+	}
+	return saveJSON(a.filename, a.acks)
+}
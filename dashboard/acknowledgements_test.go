@@ -0,0 +1,138 @@
+package dashboard_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("Acknowledgements", func() {
+	var (
+		now              = time.Date(2020, 9, 1, 9, 0, 0, 0, time.UTC)
+		red              = dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "failed"}
+		green            = dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "success"}
+		ack              = dashboard.Acknowledgement{Note: "Waiting on a fix upstream", Owner: "platform", CreatedAt: now, ExpiresAt: now.Add(72 * time.Hour)}
+		acknowledgements *dashboard.Acknowledgements
+	)
+
+	BeforeEach(func() {
+		acknowledgements = dashboard.NewAcknowledgements()
+	})
+
+	apply := func(monitor dashboard.Monitor, at time.Time) dashboard.Monitor {
+		monitors, err := acknowledgements.Apply(dashboard.Monitors{monitor}, at)
+		Ω(err).Should(BeNil())
+		return monitors[0]
+	}
+
+	Describe("#Acknowledge", func() {
+		It("marks the monitor as acknowledged", func() {
+			Ω(acknowledgements.Acknowledge(red, ack)).Should(Succeed())
+			Ω(apply(red, now.Add(time.Hour)).Acknowledgement).Should(Equal(&ack))
+		})
+
+		It("only acknowledges red monitors", func() {
+			Ω(acknowledgements.Acknowledge(green, ack)).Should(MatchError("Only red monitors can be acknowledged"))
+		})
+
+		It("needs a note, an owner and an expiry", func() {
+			withoutNote := ack
+			withoutNote.Note = ""
+			Ω(acknowledgements.Acknowledge(red, withoutNote)).Should(MatchError("An acknowledgement needs a note and an owner"))
+			expired := ack
+			expired.ExpiresAt = now
+			Ω(acknowledgements.Acknowledge(red, expired)).Should(MatchError("An acknowledgement must expire after it is made"))
+		})
+	})
+
+	Describe("#Apply", func() {
+		BeforeEach(func() {
+			Ω(acknowledgements.Acknowledge(red, ack)).Should(Succeed())
+		})
+
+		It("clears itself when the monitor goes green", func() {
+			Ω(apply(green, now.Add(time.Hour)).Acknowledgement).Should(BeNil())
+			Ω(apply(red, now.Add(2*time.Hour)).Acknowledgement).Should(BeNil())
+		})
+
+		It("clears itself when it expires", func() {
+			Ω(apply(red, now.Add(72*time.Hour)).Acknowledgement).Should(BeNil())
+		})
+
+		It("leaves other monitors alone", func() {
+			other := red
+			other.Branch = "develop"
+			Ω(apply(other, now).Acknowledgement).Should(BeNil())
+			Ω(apply(red, now).Acknowledgement).ShouldNot(BeNil())
+		})
+	})
+
+	Describe("#Clear", func() {
+		It("removes the acknowledgement", func() {
+			Ω(acknowledgements.Acknowledge(red, ack)).Should(Succeed())
+			Ω(acknowledgements.Clear(red.ID())).Should(Succeed())
+			Ω(apply(red, now).Acknowledgement).Should(BeNil())
+		})
+
+		It("takes the acknowledgement off monitors it was applied to", func() {
+			Ω(acknowledgements.Acknowledge(red, ack)).Should(Succeed())
+			acknowledged := apply(red, now)
+			Ω(acknowledgements.Clear(red.ID())).Should(Succeed())
+			Ω(apply(acknowledged, now).Acknowledgement).Should(BeNil())
+		})
+	})
+
+	Describe("#LoadAcknowledgements", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "acknowledgements")
+			Ω(err).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("keeps acknowledgements across restarts", func() {
+			filename := filepath.Join(dir, "acknowledgements.json")
+			acknowledgements, err := dashboard.LoadAcknowledgements(filename)
+			Ω(err).Should(BeNil())
+			Ω(acknowledgements.Acknowledge(red, ack)).Should(Succeed())
+
+			restarted, err := dashboard.LoadAcknowledgements(filename)
+			Ω(err).Should(BeNil())
+			monitors, err := restarted.Apply(dashboard.Monitors{red}, now)
+			Ω(err).Should(BeNil())
+			Ω(monitors[0].Acknowledgement).Should(Equal(&ack))
+		})
+
+		It("reloads what another replica saved", func() {
+			filename := filepath.Join(dir, "acknowledgements.json")
+			follower, err := dashboard.LoadAcknowledgements(filename)
+			Ω(err).Should(BeNil())
+			leader, err := dashboard.LoadAcknowledgements(filename)
+			Ω(err).Should(BeNil())
+			Ω(leader.Acknowledge(red, ack)).Should(Succeed())
+
+			Ω(follower.Reload()).Should(Succeed())
+			monitors, err := follower.Apply(dashboard.Monitors{red}, now)
+			Ω(err).Should(BeNil())
+			Ω(monitors[0].Acknowledgement).Should(Equal(&ack))
+		})
+
+		It("rejects a corrupt file", func() {
+			filename := filepath.Join(dir, "acknowledgements.json")
+			Ω(ioutil.WriteFile(filename, []byte("["), 0600)).Should(Succeed())
+			_, err := dashboard.LoadAcknowledgements(filename)
+			Ω(err).Should(MatchError(HavePrefix("Error loading acknowledgements from " + filename)))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package dashboard
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	TokenUnknown  = "unknown"
+	TokenValid    = "valid"
+	TokenRejected = "rejected"
+)
+
+// APIStats counts the API calls of a source, and whether its token was
+// accepted by the last of them to get a response.
+type APIStats struct {
+	mu    sync.Mutex
+	calls int
+	token string
+}
+
+func NewAPIStats() *APIStats {
+	return &APIStats{token: TokenUnknown}
+}
+
+// Watch counts every call made by the client.
+func (s *APIStats) Watch(client *resty.Client) *APIStats {
+	client.SetTransport(&countingTransport{stats: s, next: client.GetClient().Transport})
+	return s
+}
+
+// Record counts a call that got a response with the status code, or none if
+// it is 0. Only a success shows the token is valid and only a 401 or 403 that
+// it was rejected, other responses, e.g. a 404 or an outage, saying nothing
+// about it.
+func (s *APIStats) Record(statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		s.token = TokenRejected
+	case statusCode >= 200 && statusCode < 300:
+		s.token = TokenValid
+	}
+}
+
+func (s *APIStats) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// Token is TokenValid or TokenRejected, or TokenUnknown until a call gets a
+// response.
+func (s *APIStats) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+type countingTransport struct {
+	stats *APIStats
+	next  http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		t.stats.Record(0)
+		return resp, err
+	}
+	t.stats.Record(resp.StatusCode)
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package dashboard_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-resty/resty/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("APIStats", func() {
+	var (
+		server *httptest.Server
+		client *resty.Client
+		stats  *dashboard.APIStats
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/missing":
+				w.WriteHeader(http.StatusNotFound)
+			case r.URL.Path == "/down":
+				w.WriteHeader(http.StatusBadGateway)
+			case r.Header.Get("Authorization") == "Bearer forbidden":
+				w.WriteHeader(http.StatusForbidden)
+			case r.Header.Get("Authorization") != "Bearer valid":
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		client = resty.New()
+		stats = dashboard.NewAPIStats().Watch(client)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("does not know about the token before the first call", func() {
+		Ω(stats.Calls()).Should(Equal(0))
+		Ω(stats.Token()).Should(Equal(dashboard.TokenUnknown))
+	})
+
+	It("counts calls and tells whether the token was accepted", func() {
+		client.SetAuthToken("valid")
+		client.R().Get(server.URL)
+		client.R().Get(server.URL)
+		Ω(stats.Calls()).Should(Equal(2))
+		Ω(stats.Token()).Should(Equal(dashboard.TokenValid))
+		client.SetAuthToken("revoked")
+		client.R().Get(server.URL)
+		Ω(stats.Token()).Should(Equal(dashboard.TokenRejected))
+		client.SetAuthToken("valid")
+		client.R().Get(server.URL)
+		client.SetAuthToken("forbidden")
+		client.R().Get(server.URL)
+		Ω(stats.Token()).Should(Equal(dashboard.TokenRejected))
+	})
+
+	It("does not judge the token by other errors", func() {
+		client.R().Get(server.URL + "/missing")
+		client.R().Get(server.URL + "/down")
+		Ω(stats.Calls()).Should(Equal(2))
+		Ω(stats.Token()).Should(Equal(dashboard.TokenUnknown))
+		client.SetAuthToken("valid")
+		client.R().Get(server.URL)
+		client.R().Get(server.URL + "/down")
+		Ω(stats.Token()).Should(Equal(dashboard.TokenValid))
+	})
+
+	It("counts calls that get no response without judging the token", func() {
+		server.Close()
+		client.R().Get(server.URL)
+		Ω(stats.Calls()).Should(Equal(1))
+		Ω(stats.Token()).Should(Equal(dashboard.TokenUnknown))
+	})
+})
@@ -0,0 +1,88 @@
+package dashboard
+
+import (
+	"sync"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+// maxCached bounds each of the cache's maps. A full map is emptied, which
+// only costs the API calls of one refresh.
+const maxCached = 5000
+
+// Cache remembers what CircleCI returned about finished pipelines and
+// workflows, which no longer change, so that each refresh only asks about the
+// new ones. A nil Cache remembers nothing. It is safe for concurrent use.
+type Cache struct {
+	mu        sync.Mutex
+	workflows map[string]circleci.Workflows
+	failures  map[string]failedTests
+}
+
+// failedTests are the failing tests of a failed workflow.
+type failedTests struct {
+	count    int
+	failures []TestFailure
+}
+
+func NewCache() *Cache {
+	return &Cache{workflows: map[string]circleci.Workflows{}, failures: map[string]failedTests{}}
+}
+
+// pipelineWorkflows returns the workflows of an earlier pipeline, from the
+// cache once all of them have finished.
+func (c *Cache) pipelineWorkflows(circleCIClient circleci.CircleCI, pipeline circleci.Pipeline) (circleci.Workflows, error) {
+	if c == nil {
+		return circleCIClient.GetWorkflowsForPipeline(pipeline)
+	}
+	c.mu.Lock()
+	workflows, ok := c.workflows[pipeline.ID]
+	c.mu.Unlock()
+	if ok {
+		return workflows, nil
+	}
+	workflows, err := circleCIClient.GetWorkflowsForPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if len(workflows) == 0 {
+		return workflows, nil
+	}
+	for _, workflow := range workflows {
+		if activeWorkflowStatuses[workflow.Status] {
+			return workflows, nil
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.workflows) >= maxCached {
+		c.workflows = map[string]circleci.Workflows{}
+	}
+	c.workflows[pipeline.ID] = workflows
+	return workflows, nil
+}
+
+// failedTests returns the failing tests of a failed workflow, collecting them
+// only the first time.
+func (c *Cache) failedTests(workflowID string, collect func() (failedTests, error)) (failedTests, error) {
+	if c == nil {
+		return collect()
+	}
+	c.mu.Lock()
+	tests, ok := c.failures[workflowID]
+	c.mu.Unlock()
+	if ok {
+		return tests, nil
+	}
+	tests, err := collect()
+	if err != nil {
+		return failedTests{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.failures) >= maxCached {
+		c.failures = map[string]failedTests{}
+	}
+	c.failures[workflowID] = tests
+	return tests, nil
+}
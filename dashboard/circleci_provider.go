@@ -0,0 +1,77 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+// CircleCIProvider is the Provider for a CircleCI organisation or install.
+// It builds its own monitors, with progress, failing tests and flakiness.
+type CircleCIProvider struct {
+	CircleCIClient circleci.CircleCI
+	Filter         *circleci.Filter
+
+	cache *Cache
+}
+
+func NewCircleCIProvider(circleCIClient circleci.CircleCI, filter *circleci.Filter) *CircleCIProvider {
+	return &CircleCIProvider{CircleCIClient: circleCIClient, Filter: filter, cache: NewCache()}
+}
+
+// WithContext passes the context on to the API calls of a CircleCI client,
+// the other clients, such as mocks, being kept as they are.
+func (p *CircleCIProvider) WithContext(ctx context.Context) Provider {
+	return &CircleCIProvider{CircleCIClient: circleCIWithContext(ctx, p.CircleCIClient), Filter: p.Filter, cache: p.cache}
+}
+
+func circleCIWithContext(ctx context.Context, circleCIClient circleci.CircleCI) circleci.CircleCI {
+	if client, ok := circleCIClient.(*circleci.Client); ok {
+		return client.WithContext(ctx)
+	}
+	return circleCIClient
+}
+
+func (p *CircleCIProvider) Projects() ([]Project, error) {
+	projects, err := p.CircleCIClient.GetAllProjects()
+	if err != nil {
+		return nil, err
+	}
+	var neutral []Project
+	for _, project := range projects.Filter(p.Filter) {
+		var branches []string
+		for branch := range project.Branches {
+			branches = append(branches, branch)
+		}
+		sort.Strings(branches)
+		neutral = append(neutral, Project{Name: project.Name(), Slug: project.Slug(), Branches: branches})
+	}
+	return neutral, nil
+}
+
+// BuildProject builds a project's monitors with the details workflow runs do
+// not carry, such as job progress and failing tests.
+func (p *CircleCIProvider) BuildProject(project Project, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
+	circleCIProject, err := circleCIProject(project)
+	if err != nil {
+		return nil, err
+	}
+	return buildProject(p.CircleCIClient, circleCIProject, featureFlags, monitorConfig, p.cache)
+}
+
+// circleCIProject turns a project back into CircleCI's, from its slug of the
+// form vcs-type/username/reponame.
+func circleCIProject(project Project) (circleci.Project, error) {
+	parts := strings.SplitN(project.Slug, "/", 3)
+	if len(parts) != 3 {
+		return circleci.Project{}, fmt.Errorf("Invalid CircleCI project slug %q", project.Slug)
+	}
+	branches := map[string]interface{}{}
+	for _, branch := range project.Branches {
+		branches[branch] = nil
+	}
+	return circleci.Project{VCSType: parts[0], Username: parts[1], Reponame: parts[2], Branches: branches}, nil
+}
@@ -1,11 +1,18 @@
 package dashboard
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"sort"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
 )
 
 type FeatureFlags struct {
@@ -13,27 +20,94 @@ type FeatureFlags struct {
 }
 
 type Monitor struct {
-	Name     string
-	Workflow string
-	Branch   string
-	Status   string
-	Link     string
+	Name     string `json:"name"`
+	Workflow string `json:"workflow"`
+	Branch   string `json:"branch"`
+	Tag      string `json:"tag,omitempty"`
+	// TagPattern is the TAG_PATTERNS pattern a tag tile follows. It stands in
+	// for Tag in the tile's identity, which would otherwise change with every
+	// release, while Tag is only shown.
+	TagPattern string `json:"tag_pattern,omitempty"`
+	Trigger    string `json:"trigger,omitempty"`
+	// Source names the CircleCI source the monitor came from, when there is
+	// more than one.
+	Source   string    `json:"source,omitempty"`
+	Status   string    `json:"status"`
+	Link     string    `json:"link"`
+	Error    string    `json:"error,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+	// FailedTests counts the failing tests of a failed workflow, of which
+	// TestFailures holds the first few.
+	FailedTests  int           `json:"failed_tests,omitempty"`
+	TestFailures []TestFailure `json:"test_failures,omitempty"`
+	// Flakiness is set when the workflow has both passed and failed on the
+	// same revision within its recent history.
+	Flakiness *Flakiness `json:"flakiness,omitempty"`
+	// RedSince is when the monitor went red, if it is red, as recorded by
+	// History.
+	RedSince        *time.Time       `json:"red_since,omitempty"`
+	Acknowledgement *Acknowledgement `json:"acknowledgement,omitempty"`
+	Owner           *Owner           `json:"owner,omitempty"`
 }
 
+// Active reports whether the monitor's workflow is still running or waiting
+// on an approval, in which case it is worth polling more often.
+func (m Monitor) Active() bool {
+	for _, status := range strings.Fields(m.Status) {
+		if status == "running" || status == "failing" || status == "on_hold" {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed reports whether the monitor shows red, i.e. the latest completed
+// workflow failed, even if a newer one is now running.
+func (m Monitor) Failed() bool {
+	for _, status := range strings.Fields(m.Status) {
+		if status == "failed" || status == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigErrorWorkflow names the tile shown for a branch whose config has never
+// compiled, so there is no earlier workflow to show instead.
+const ConfigErrorWorkflow = "Config Error"
+
 type MonitorConfig struct {
 	HideOrganization bool
 	HideBranch       bool
 	BranchFilter     string
+	// TagPatterns adds a monitor per workflow for the latest tag matching
+	// each glob, e.g. "v*" for releases. "*" follows every tag.
+	TagPatterns []string
+	// SplitByTrigger gives scheduled pipelines their own monitors, so a
+	// failing nightly build is not hidden by the next push.
+	SplitByTrigger bool
+	// FlakyHistory is how many recent pipelines are checked for workflows
+	// that both passed and failed on the same revision. 0 turns it off.
+	FlakyHistory int
+	Owners       Owners
 }
 
 type Monitors []Monitor
 
 func NewMonitor(project circleci.Project, pipeline circleci.Pipeline, workflow circleci.Workflow, status, link string, config *MonitorConfig) Monitor {
-	projectName := project.Name()
-	branchName := pipeline.VCS.Branch
+	var trigger string
+	if config.SplitByTrigger && pipeline.VCS.Branch != "" {
+		trigger = pipeline.TriggerSource()
+	}
+	return newMonitor(project.Name(), pipeline.VCS.Branch, pipeline.VCS.Tag, trigger, workflow.Name, status, link, config)
+}
+
+func newMonitor(projectName, branch, tag, trigger, workflow, status, link string, config *MonitorConfig) Monitor {
+	name := projectName
+	branchName := branch
 
 	if config.HideOrganization {
-		projectName = strings.Join(strings.Split(projectName, "/")[1:], "/")
+		name = strings.Join(strings.Split(projectName, "/")[1:], "/")
 	}
 
 	if config.HideBranch {
@@ -41,57 +115,112 @@ func NewMonitor(project circleci.Project, pipeline circleci.Pipeline, workflow c
 	}
 
 	return Monitor{
-		Name:     projectName,
-		Workflow: workflow.Name,
+		Name:     name,
+		Workflow: workflow,
 		Branch:   branchName,
+		Tag:      tag,
+		Trigger:  trigger,
 		Status:   status,
 		Link:     link,
+		Owner:    config.Owners.Find(projectName, branch, workflow),
 	}
 }
 
-func Build(circleCIClient circleci.CircleCI, filter *circleci.Filter, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
-	var dashboardData Monitors
-	projects, err := circleCIClient.GetAllProjects()
+func Build(circleCIClient circleci.CircleCI, filter *circleci.Filter, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (dashboardData Monitors, err error) {
+	ctx, span := telemetry.Tracer().Start(context.Background(), "dashboard.Build")
+	defer func() { telemetry.End(span, err) }()
+	projects, err := circleCIWithContext(ctx, circleCIClient).GetAllProjects()
 	if err != nil {
 		return nil, err
 	}
 	projects = projects.Filter(filter)
 	for _, project := range projects {
-		pipelines, err := circleCIClient.GetAllPipelines(project)
+		projectCtx, projectSpan := telemetry.Tracer().Start(ctx, "collect project", trace.WithAttributes(attribute.String("project", project.Slug())))
+		projectMonitors, err := BuildProject(circleCIWithContext(projectCtx, circleCIClient), project, featureFlags, monitorConfig)
+		telemetry.End(projectSpan, err)
 		if err != nil {
 			return nil, err
 		}
-		filteredPipelines := pipelines.FilteredPerBranch(monitorConfig.BranchFilter)
-		for branch, pipeline := range pipelines.LatestPerBranch() {
-			workflows, err := circleCIClient.GetWorkflowsForPipeline(pipeline)
-			if err != nil {
-				return nil, err
-			}
-			workflowInfo := WorkflowDetails{
-				Project:           project,
-				CircleCIClient:    circleCIClient,
-				Workflows:         workflows,
-				FilteredPipelines: filteredPipelines[branch],
-			}
-			err = workflowInfo.GetLatestWorkflowWithoutBuildError()
-			if err != nil {
-				return nil, err
-			}
-			dashboardData, err = dashboardData.AddWorkflows(workflowInfo, featureFlags, monitorConfig)
-			if err != nil {
-				return nil, err
+		dashboardData = dashboardData.Merge(projectMonitors)
+	}
+	dashboardData.Sort()
+	return dashboardData, nil
+}
+
+func BuildProject(circleCIClient circleci.CircleCI, project circleci.Project, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
+	return buildProject(circleCIClient, project, featureFlags, monitorConfig, nil)
+}
+
+func buildProject(circleCIClient circleci.CircleCI, project circleci.Project, featureFlags *FeatureFlags, monitorConfig *MonitorConfig, cache *Cache) (Monitors, error) {
+	var dashboardData Monitors
+	pipelines, err := circleCIClient.GetAllPipelines(project)
+	if err != nil {
+		return nil, err
+	}
+	filteredPipelines := pipelines.FilteredPerBranch(monitorConfig.BranchFilter)
+	for branch, pipeline := range pipelines.LatestPerBranch() {
+		if monitorConfig.SplitByTrigger {
+			for _, sourcePipelines := range filteredPipelines[branch].PerTriggerSource() {
+				dashboardData, err = dashboardData.addPipeline(circleCIClient, project, sourcePipelines[0], sourcePipelines, "", cache, featureFlags, monitorConfig)
+				if err != nil {
+					return nil, err
+				}
 			}
+			continue
+		}
+		dashboardData, err = dashboardData.addPipeline(circleCIClient, project, pipeline, filteredPipelines[branch], "", cache, featureFlags, monitorConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(monitorConfig.TagPatterns) == 0 {
+		return dashboardData, nil
+	}
+	tagPipelines, err := circleCIClient.GetTagPipelines(project)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range monitorConfig.TagPatterns {
+		matching := tagPipelines.MatchingTag(pattern)
+		if len(matching) == 0 {
+			continue
+		}
+		dashboardData, err = dashboardData.addPipeline(circleCIClient, project, matching[0], matching, pattern, cache, featureFlags, monitorConfig)
+		if err != nil {
+			return nil, err
 		}
 	}
-	dashboardData.Sort()
 	return dashboardData, nil
 }
 
+// addPipeline adds the workflows of the latest pipeline, falling back through
+// the earlier pipelines when it has a build error. tagPattern is set for the
+// pipelines of a tag pattern.
+func (d Monitors) addPipeline(circleCIClient circleci.CircleCI, project circleci.Project, latest circleci.Pipeline, pipelines circleci.Pipelines, tagPattern string, cache *Cache, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
+	workflows, err := circleCIClient.GetWorkflowsForPipeline(latest)
+	if err != nil {
+		return nil, err
+	}
+	workflowInfo := WorkflowDetails{
+		Project:           project,
+		CircleCIClient:    circleCIClient,
+		Workflows:         workflows,
+		FilteredPipelines: pipelines,
+		TagPattern:        tagPattern,
+		Cache:             cache,
+	}
+	err = workflowInfo.GetLatestWorkflowWithoutBuildError()
+	if err != nil {
+		return nil, err
+	}
+	return d.AddWorkflows(workflowInfo, featureFlags, monitorConfig)
+}
+
 func (d *Monitors) Sort() {
 	monitors := *d
 	sort.Slice(monitors, func(i, j int) bool {
-		iMonitor := fmt.Sprintf("%s-%s-%s", monitors[i].Name, monitors[i].Workflow, monitors[i].Branch)
-		jMonitor := fmt.Sprintf("%s-%s-%s", monitors[j].Name, monitors[j].Workflow, monitors[j].Branch)
+		iMonitor := fmt.Sprintf("%s-%s-%s-%s-%s-%s", monitors[i].Name, monitors[i].Workflow, monitors[i].Branch, monitors[i].tagKey(), monitors[i].Trigger, monitors[i].Source)
+		jMonitor := fmt.Sprintf("%s-%s-%s-%s-%s-%s", monitors[j].Name, monitors[j].Workflow, monitors[j].Branch, monitors[j].tagKey(), monitors[j].Trigger, monitors[j].Source)
 		return iMonitor < jMonitor
 	})
 	d = &monitors
@@ -101,7 +230,29 @@ func (d *Monitors) AlreadyExists(monitor Monitor) bool {
 	for _, mon := range *d {
 		if mon.Name == monitor.Name &&
 			mon.Workflow == monitor.Workflow &&
-			mon.Branch == monitor.Branch {
+			mon.Branch == monitor.Branch &&
+			mon.tagKey() == monitor.tagKey() &&
+			mon.Trigger == monitor.Trigger &&
+			mon.Source == monitor.Source {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Monitors) Merge(monitors Monitors) Monitors {
+	for _, monitor := range monitors {
+		if d.AlreadyExists(monitor) {
+			continue
+		}
+		d = append(d, monitor)
+	}
+	return d
+}
+
+func (d Monitors) Active() bool {
+	for _, monitor := range d {
+		if monitor.Active() {
 			return true
 		}
 	}
@@ -109,8 +260,15 @@ func (d *Monitors) AlreadyExists(monitor Monitor) bool {
 }
 
 func (d Monitors) AddWorkflows(workflowInfo WorkflowDetails, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
+	// Without the history the tiles are still worth showing, just without
+	// their flaky badges.
+	flakiness, err := workflowInfo.flakiness(monitorConfig.FlakyHistory)
+	if err != nil {
+		slog.Warn("Error scoring flakiness", "project", workflowInfo.Project.Slug(), "err", err)
+	}
 	for _, workflow := range workflowInfo.Workflows {
 		monitor := NewMonitor(workflowInfo.Project, workflowInfo.Pipeline, workflow, "", "", monitorConfig)
+		monitor.TagPattern = workflowInfo.TagPattern
 		if d.AlreadyExists(monitor) {
 			continue
 		}
@@ -123,7 +281,24 @@ func (d Monitors) AddWorkflows(workflowInfo WorkflowDetails, featureFlags *Featu
 			if !featureFlags.AnimatedBuildErrors {
 				errorStatus = "errored-static"
 			}
-			status = fmt.Sprintf("%s %s", status, errorStatus)
+			status = fmt.Sprintf("%s %s config-invalid", status, errorStatus)
+			monitor.Error = workflowInfo.ErrorMessage
+		}
+		if activeWorkflowStatuses[workflow.Status] {
+			if monitor.Progress, err = workflowInfo.progress(workflow); err != nil {
+				return nil, err
+			}
+		}
+		if workflow.Status == "failed" {
+			// The tile is still worth showing without its failing tests.
+			if count, failures, err := workflowInfo.testFailures(workflow); err != nil {
+				slog.Warn("Error collecting failing tests", "project", workflowInfo.Project.Slug(), "workflow", workflow.Name, "err", err)
+			} else {
+				monitor.FailedTests, monitor.TestFailures = count, failures
+			}
+		}
+		if score, ok := flakiness[workflow.Name]; ok && score.Flaky > 0 {
+			monitor.Flakiness = &score
 		}
 		link := workflowInfo.CircleCIClient.WorkflowLink(workflowInfo.Project, workflowInfo.Pipeline, workflow)
 		monitor.Status = status
@@ -140,11 +315,21 @@ type WorkflowDetails struct {
 	Pipeline          circleci.Pipeline
 	FilteredPipelines circleci.Pipelines
 	BuildError        bool
+	ErrorMessage      string
+	// TagPattern is the pattern the pipelines' tag matched, if any.
+	TagPattern string
+	// Cache, if set, saves asking again about earlier pipelines.
+	Cache *Cache
 }
 
+// GetLatestWorkflowWithoutBuildError falls back to the last pipeline that ran
+// workflows when the latest one has an invalid config. CircleCI reports this
+// as an errored pipeline, or on older projects as a "Build Error" workflow.
 func (workflowInfo *WorkflowDetails) GetLatestWorkflowWithoutBuildError() error {
-	if workflowInfo.Workflows.BuildError() {
+	latest := workflowInfo.FilteredPipelines[0]
+	if latest.ConfigError() || workflowInfo.Workflows.BuildError() {
 		workflowInfo.BuildError = true
+		workflowInfo.ErrorMessage = latest.ErrorMessage()
 		previousNonError, err := workflowInfo.getPreviousWorkflowDetails()
 		if err != nil {
 			return err
@@ -152,7 +337,10 @@ func (workflowInfo *WorkflowDetails) GetLatestWorkflowWithoutBuildError() error
 		if previousNonError {
 			return nil
 		} else {
-			workflow := workflowInfo.Workflows[0]
+			workflow := circleci.Workflow{Name: ConfigErrorWorkflow}
+			if len(workflowInfo.Workflows) > 0 {
+				workflow = workflowInfo.Workflows[0]
+			}
 			workflow.Status = "unknown"
 			workflowInfo.Workflows = circleci.Workflows{workflow}
 			workflowInfo.Pipeline = workflowInfo.FilteredPipelines[len(workflowInfo.FilteredPipelines)-1]
@@ -160,17 +348,20 @@ func (workflowInfo *WorkflowDetails) GetLatestWorkflowWithoutBuildError() error
 			return nil
 		}
 	}
-	workflowInfo.Pipeline = workflowInfo.FilteredPipelines[0]
+	workflowInfo.Pipeline = latest
 	return nil
 }
 
 func (workflowInfo *WorkflowDetails) getPreviousWorkflowDetails() (bool, error) {
 	for index, pipeline := range workflowInfo.FilteredPipelines {
+		if pipeline.ConfigError() {
+			continue
+		}
 		workflows, err := workflowInfo.CircleCIClient.GetWorkflowsForPipeline(pipeline)
 		if err != nil {
 			return false, err
 		}
-		if !workflows.BuildError() {
+		if len(workflows) > 0 && !workflows.BuildError() {
 			workflowInfo.Workflows = workflows
 			workflowInfo.Pipeline = pipeline
 			workflowInfo.FilteredPipelines = workflowInfo.FilteredPipelines[index:]
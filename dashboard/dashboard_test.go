@@ -81,6 +81,25 @@ var _ = Describe("#NewMonitor", func() {
 	})
 })
 
+var _ = Describe("Monitor", func() {
+	Describe("#Failed", func() {
+		Context("when the latest completed workflow failed", func() {
+			It("returns true", func() {
+				Ω(dashboard.Monitor{Status: "failed"}.Failed()).Should(BeTrue())
+				Ω(dashboard.Monitor{Status: "running failed"}.Failed()).Should(BeTrue())
+				Ω(dashboard.Monitor{Status: "error errored"}.Failed()).Should(BeTrue())
+			})
+		})
+
+		Context("when the latest completed workflow did not fail", func() {
+			It("returns false", func() {
+				Ω(dashboard.Monitor{Status: "success errored"}.Failed()).Should(BeFalse())
+				Ω(dashboard.Monitor{Status: "running unknown"}.Failed()).Should(BeFalse())
+			})
+		})
+	})
+})
+
 var _ = Describe("Monitors", func() {
 	Describe("#Sort", func() {
 		It("sorts the monitors by name, workflow and branch", func() {
@@ -166,6 +185,37 @@ var _ = Describe("Monitors", func() {
 		})
 	})
 
+	Describe("#Merge", func() {
+		It("adds only the monitors that are not already present", func() {
+			var monitors = dashboard.Monitors{
+				{Name: "foobar/example", Workflow: "test-workflow", Branch: "master", Status: "success"},
+			}
+			merged := monitors.Merge(dashboard.Monitors{
+				{Name: "foobar/example", Workflow: "test-workflow", Branch: "master", Status: "failed"},
+				{Name: "foobar/example", Workflow: "test-workflow", Branch: "develop", Status: "failed"},
+			})
+			Ω(merged).Should(Equal(dashboard.Monitors{
+				{Name: "foobar/example", Workflow: "test-workflow", Branch: "master", Status: "success"},
+				{Name: "foobar/example", Workflow: "test-workflow", Branch: "develop", Status: "failed"},
+			}))
+		})
+	})
+
+	Describe("#Active", func() {
+		Context("when a monitor is running or on hold", func() {
+			It("returns true", func() {
+				Ω(dashboard.Monitors{{Status: "success"}, {Status: "running failed"}}.Active()).Should(BeTrue())
+				Ω(dashboard.Monitors{{Status: "on_hold success"}}.Active()).Should(BeTrue())
+			})
+		})
+
+		Context("when every monitor has settled", func() {
+			It("returns false", func() {
+				Ω(dashboard.Monitors{{Status: "success"}, {Status: "failed errored"}}.Active()).Should(BeFalse())
+			})
+		})
+	})
+
 	Describe("#AddWorkflows", func() {
 		var (
 			monitorConfig  = &dashboard.MonitorConfig{}
@@ -237,6 +287,7 @@ var _ = Describe("Monitors", func() {
 			Context("and there was a build error", func() {
 				BeforeEach(func() {
 					workflowInfo.BuildError = true
+					workflowInfo.ErrorMessage = "Unknown key 'jbos'"
 				})
 
 				Context("and animated build is true", func() {
@@ -247,8 +298,9 @@ var _ = Describe("Monitors", func() {
 							Name:     "foobar/example",
 							Workflow: "test-workflow",
 							Branch:   "master",
-							Status:   "success errored",
+							Status:   "success errored config-invalid",
 							Link:     "https://foobar.com",
+							Error:    "Unknown key 'jbos'",
 						}}))
 					})
 				})
@@ -264,8 +316,9 @@ var _ = Describe("Monitors", func() {
 							Name:     "foobar/example",
 							Workflow: "test-workflow",
 							Branch:   "master",
-							Status:   "success errored-static",
+							Status:   "success errored-static config-invalid",
 							Link:     "https://foobar.com",
+							Error:    "Unknown key 'jbos'",
 						}}))
 					})
 				})
@@ -423,7 +476,7 @@ var _ = Describe("#Build", func() {
 								Name:     "foobar/example",
 								Workflow: "test-workflow",
 								Branch:   "master",
-								Status:   "success errored",
+								Status:   "success errored config-invalid",
 								Link:     "https://foobar.com",
 							}}))
 						})
@@ -547,5 +600,146 @@ var _ = Describe("WorkflowDetails", func() {
 				})
 			})
 		})
+
+		Context("when the latest pipeline errored", func() {
+			var erroredPipeline = circleci.Pipeline{
+				ID:     "0",
+				State:  circleci.PipelineStateErrored,
+				Errors: []circleci.PipelineError{{Type: "config", Message: "Unknown key 'jbos'"}},
+				VCS:    circleci.VCS{Branch: "master"},
+			}
+
+			JustBeforeEach(func() {
+				workflowDetails.Workflows = circleci.Workflows{}
+				workflowDetails.FilteredPipelines = circleci.Pipelines{erroredPipeline, pipeline}
+			})
+
+			Context("when there is a previous working workflow", func() {
+				BeforeEach(func() {
+					circleCIClient.On("GetWorkflowsForPipeline", pipeline).Return(workflows2, nil)
+				})
+
+				It("returns the last good workflow with the error message", func() {
+					err := workflowDetails.GetLatestWorkflowWithoutBuildError()
+					Ω(err).Should(BeNil())
+					Ω(workflowDetails.Workflows).Should(Equal(workflows2))
+					Ω(workflowDetails.Pipeline).Should(Equal(pipeline))
+					Ω(workflowDetails.BuildError).Should(BeTrue())
+					Ω(workflowDetails.ErrorMessage).Should(Equal("Unknown key 'jbos'"))
+					circleCIClient.AssertNotCalled(GinkgoT(), "GetWorkflowsForPipeline", erroredPipeline)
+				})
+			})
+
+			Context("when no pipeline has ever run workflows", func() {
+				BeforeEach(func() {
+					circleCIClient.On("GetWorkflowsForPipeline", pipeline).Return(circleci.Workflows{}, nil)
+				})
+
+				It("returns a config error workflow with an unknown status", func() {
+					err := workflowDetails.GetLatestWorkflowWithoutBuildError()
+					Ω(err).Should(BeNil())
+					Ω(workflowDetails.Workflows).Should(Equal(circleci.Workflows{{Name: dashboard.ConfigErrorWorkflow, Status: "unknown"}}))
+					Ω(workflowDetails.Pipeline).Should(Equal(pipeline))
+					Ω(workflowDetails.ErrorMessage).Should(Equal("Unknown key 'jbos'"))
+				})
+			})
+		})
+	})
+})
+
+var _ = Describe("#BuildProject", func() {
+	var (
+		circleCIClient *mocks.CircleCI
+		featureFlags   = &dashboard.FeatureFlags{}
+		project        = circleci.Project{
+			VCSType:  "github",
+			Username: "foobar",
+			Reponame: "example",
+			Branches: map[string]interface{}{"master": nil},
+		}
+		branchPipeline  = circleci.Pipeline{ID: "1", Number: 1, VCS: circleci.VCS{Branch: "master"}}
+		releasePipeline = circleci.Pipeline{ID: "2", Number: 2, VCS: circleci.VCS{Tag: "v1.1.0"}}
+		oldRelease      = circleci.Pipeline{ID: "3", Number: 3, VCS: circleci.VCS{Tag: "v1.0.0"}}
+		nightlyPipeline = circleci.Pipeline{ID: "4", Number: 4, VCS: circleci.VCS{Tag: "nightly"}}
+	)
+
+	BeforeEach(func() {
+		circleCIClient = &mocks.CircleCI{}
+		circleCIClient.On("GetAllPipelines", project).Return(circleci.Pipelines{branchPipeline}, nil)
+		circleCIClient.On("GetWorkflowsForPipeline", branchPipeline).Return(circleci.Workflows{{ID: "1", Name: "build"}}, nil)
+		circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+		circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+	})
+
+	Context("when no tag patterns are configured", func() {
+		It("does not look for tag pipelines", func() {
+			monitors, err := dashboard.BuildProject(circleCIClient, project, featureFlags, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(1))
+			circleCIClient.AssertNotCalled(GinkgoT(), "GetTagPipelines", project)
+		})
+	})
+
+	Context("when monitors are split by trigger", func() {
+		var nightlyPipeline = circleci.Pipeline{ID: "5", Number: 5, Trigger: circleci.Trigger{Type: "scheduled_pipeline"}, VCS: circleci.VCS{Branch: "master"}}
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("GetAllPipelines", project).Return(circleci.Pipelines{branchPipeline, nightlyPipeline}, nil)
+			circleCIClient.On("GetWorkflowsForPipeline", branchPipeline).Return(circleci.Workflows{{ID: "1", Name: "build", Status: "success"}}, nil)
+			circleCIClient.On("GetWorkflowsForPipeline", nightlyPipeline).Return(circleci.Workflows{{ID: "5", Name: "build", Status: "failed"}}, nil)
+			circleCIClient.On("GetJobsForWorkflow", mock.Anything).Return(circleci.Jobs{}, nil)
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return(func(_ circleci.Pipelines, workflow circleci.Workflow) string {
+				return workflow.Status
+			}, nil)
+			circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+		})
+
+		It("adds a monitor per branch and trigger source", func() {
+			monitors, err := dashboard.BuildProject(circleCIClient, project, featureFlags, &dashboard.MonitorConfig{SplitByTrigger: true})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(ConsistOf(
+				dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Trigger: "push", Status: "success", Link: "https://foobar.com"},
+				dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Trigger: "scheduled", Status: "failed", Link: "https://foobar.com"},
+			))
+			circleCIClient.AssertCalled(GinkgoT(), "WorkflowStatus", circleci.Pipelines{nightlyPipeline}, circleci.Workflow{ID: "5", Name: "build", Status: "failed"})
+		})
+
+		It("shows only the latest pipeline when not split", func() {
+			monitors, err := dashboard.BuildProject(circleCIClient, project, featureFlags, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(1))
+			Ω(monitors[0].Status).Should(Equal("success"))
+		})
+	})
+
+	Context("when tag patterns are configured", func() {
+		BeforeEach(func() {
+			circleCIClient.On("GetTagPipelines", project).Return(circleci.Pipelines{releasePipeline, oldRelease, nightlyPipeline}, nil)
+			circleCIClient.On("GetWorkflowsForPipeline", releasePipeline).Return(circleci.Workflows{{ID: "2", Name: "release"}}, nil)
+		})
+
+		It("adds a monitor for the latest tag matching each pattern", func() {
+			monitors, err := dashboard.BuildProject(circleCIClient, project, featureFlags, &dashboard.MonitorConfig{TagPatterns: []string{"v*", "release-*"}})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(ConsistOf(
+				dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "success", Link: "https://foobar.com"},
+				dashboard.Monitor{Name: "foobar/example", Workflow: "release", Tag: "v1.1.0", TagPattern: "v*", Status: "success", Link: "https://foobar.com"},
+			))
+			circleCIClient.AssertCalled(GinkgoT(), "WorkflowStatus", circleci.Pipelines{releasePipeline, oldRelease}, circleci.Workflow{ID: "2", Name: "release"})
+		})
+
+		Context("and getting tag pipelines errors", func() {
+			BeforeEach(func() {
+				circleCIClient = &mocks.CircleCI{}
+				circleCIClient.On("GetAllPipelines", project).Return(circleci.Pipelines{}, nil)
+				circleCIClient.On("GetTagPipelines", project).Return(nil, fmt.Errorf("Error getting tag pipelines"))
+			})
+
+			It("returns an error", func() {
+				_, err := dashboard.BuildProject(circleCIClient, project, featureFlags, &dashboard.MonitorConfig{TagPatterns: []string{"v*"}})
+				Ω(err).Should(MatchError("Error getting tag pipelines"))
+			})
+		})
 	})
 })
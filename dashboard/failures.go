@@ -0,0 +1,82 @@
+package dashboard
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+// maxTestFailures is how many failing tests are kept for the detail page.
+const maxTestFailures = 10
+
+type TestFailure struct {
+	Job       string `json:"job"`
+	Name      string `json:"name"`
+	Classname string `json:"classname,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ID identifies the monitor in URLs, e.g. its detail page. The source only
+// counts when set, so that IDs from before there were sources still match.
+func (m Monitor) ID() string {
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s", m.Name, m.Workflow, m.Branch, m.tagKey(), m.Trigger)
+	if m.Source != "" {
+		key += "\x00" + m.Source
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// tagKey identifies a tag tile by its pattern rather than by the tag, which
+// changes with every release.
+func (m Monitor) tagKey() string {
+	if m.TagPattern != "" {
+		return m.TagPattern
+	}
+	return m.Tag
+}
+
+// Find returns the monitor with the given ID.
+func (d Monitors) Find(id string) (Monitor, bool) {
+	for _, monitor := range d {
+		if monitor.ID() == id {
+			return monitor, true
+		}
+	}
+	return Monitor{}, false
+}
+
+// testFailures collects the failing tests of a failed workflow's failed jobs.
+// It returns the total number failing and the first few of them.
+func (workflowInfo WorkflowDetails) testFailures(workflow circleci.Workflow) (int, []TestFailure, error) {
+	tests, err := workflowInfo.Cache.failedTests(workflow.ID, func() (failedTests, error) {
+		return workflowInfo.collectTestFailures(workflow)
+	})
+	return tests.count, tests.failures, err
+}
+
+func (workflowInfo WorkflowDetails) collectTestFailures(workflow circleci.Workflow) (failedTests, error) {
+	jobs, err := workflowInfo.CircleCIClient.GetJobsForWorkflow(workflow)
+	if err != nil {
+		return failedTests{}, err
+	}
+	var tests failedTests
+	for _, job := range jobs {
+		if job.Status != "failed" || job.JobNumber == 0 {
+			continue
+		}
+		results, err := workflowInfo.CircleCIClient.GetJobTests(workflowInfo.Project.Slug(), job.JobNumber)
+		if err != nil {
+			return failedTests{}, err
+		}
+		for _, test := range results.Failed() {
+			tests.count++
+			if len(tests.failures) < maxTestFailures {
+				tests.failures = append(tests.failures, TestFailure{Job: job.Name, Name: test.Name, Classname: test.Classname, Message: test.Message})
+			}
+		}
+	}
+	return tests, nil
+}
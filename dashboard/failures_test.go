@@ -0,0 +1,125 @@
+package dashboard_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+var _ = Describe("Test failures", func() {
+	Describe("#ID", func() {
+		It("is stable and tells monitors apart", func() {
+			monitor := dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master"}
+			Ω(monitor.ID()).Should(Equal(monitor.ID()))
+			Ω(monitor.ID()).Should(HaveLen(12))
+			other := monitor
+			other.Branch = "develop"
+			Ω(other.ID()).ShouldNot(Equal(monitor.ID()))
+		})
+
+		It("stays the same for a tag tile when a new tag is released", func() {
+			release := dashboard.Monitor{Name: "foobar/example", Workflow: "release", Tag: "v1.0.0", TagPattern: "v*"}
+			next := release
+			next.Tag = "v1.1.0"
+			Ω(next.ID()).Should(Equal(release.ID()))
+			next.TagPattern = "release-*"
+			Ω(next.ID()).ShouldNot(Equal(release.ID()))
+		})
+	})
+
+	Describe("#Find", func() {
+		var monitors = dashboard.Monitors{
+			{Name: "foobar/example", Workflow: "build", Branch: "master"},
+			{Name: "foobar/example", Workflow: "deploy", Branch: "master"},
+		}
+
+		It("finds a monitor by its ID", func() {
+			monitor, found := monitors.Find(monitors[1].ID())
+			Ω(found).Should(BeTrue())
+			Ω(monitor).Should(Equal(monitors[1]))
+		})
+
+		It("reports unknown IDs", func() {
+			_, found := monitors.Find("unknown")
+			Ω(found).Should(BeFalse())
+		})
+	})
+
+	Describe("failed monitors", func() {
+		var (
+			circleCIClient *mocks.CircleCI
+			project        = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example"}
+			failed         = circleci.Workflow{ID: "1", Name: "build", Status: "failed"}
+			workflowInfo   dashboard.WorkflowDetails
+		)
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("failed", nil)
+			circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+			workflowInfo = dashboard.WorkflowDetails{
+				CircleCIClient:    circleCIClient,
+				Project:           project,
+				Workflows:         circleci.Workflows{failed},
+				Pipeline:          circleci.Pipeline{ID: "1"},
+				FilteredPipelines: circleci.Pipelines{{ID: "1"}},
+			}
+			circleCIClient.On("GetJobsForWorkflow", failed).Return(circleci.Jobs{
+				{Name: "lint", Status: "success", JobNumber: 10},
+				{Name: "unit", Status: "failed", JobNumber: 11},
+				{Name: "approve", Status: "failed"},
+			}, nil)
+		})
+
+		Context("when getting test results errors", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetJobTests", "github/foobar/example", 11).Return(nil, fmt.Errorf("Error getting tests"))
+			})
+
+			It("leaves the failing tests off the tile", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(BeNil())
+				Ω(monitors).Should(HaveLen(1))
+				Ω(monitors[0].FailedTests).Should(BeZero())
+				Ω(monitors[0].TestFailures).Should(BeEmpty())
+			})
+		})
+
+		Context("when the failed jobs have test results", func() {
+			BeforeEach(func() {
+				tests := circleci.TestResults{{Name: "passes", Result: "success"}}
+				for i := 0; i < 12; i++ {
+					tests = append(tests, circleci.TestResult{Name: fmt.Sprintf("test %d", i), Classname: "unit_test", Result: "failure", Message: "boom"})
+				}
+				circleCIClient.On("GetJobTests", "github/foobar/example", 11).Return(tests, nil)
+			})
+
+			It("counts every failing test and keeps the first few", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(BeNil())
+				Ω(monitors).Should(HaveLen(1))
+				Ω(monitors[0].FailedTests).Should(Equal(12))
+				Ω(monitors[0].TestFailures).Should(HaveLen(10))
+				Ω(monitors[0].TestFailures[0]).Should(Equal(dashboard.TestFailure{Job: "unit", Name: "test 0", Classname: "unit_test", Message: "boom"}))
+				circleCIClient.AssertNotCalled(GinkgoT(), "GetJobTests", "github/foobar/example", 10)
+			})
+
+			It("only collects the failing tests of a workflow once", func() {
+				workflowInfo.Cache = dashboard.NewCache()
+				for i := 0; i < 2; i++ {
+					monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+					Ω(err).Should(BeNil())
+					Ω(monitors[0].FailedTests).Should(Equal(12))
+				}
+				circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetJobsForWorkflow", 1)
+				circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetJobTests", 1)
+			})
+		})
+	})
+})
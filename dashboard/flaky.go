@@ -0,0 +1,93 @@
+package dashboard
+
+import "sort"
+
+// Flakiness scores a workflow by how many of its recent revisions it both
+// passed and failed on, e.g. failing and then passing on a rerun.
+type Flakiness struct {
+	Score     float64 `json:"score"`
+	Flaky     int     `json:"flaky"`
+	Revisions int     `json:"revisions"`
+}
+
+// Percent is the score as a whole percentage.
+func (f Flakiness) Percent() int {
+	return int(f.Score*100 + 0.5)
+}
+
+type revisionOutcome struct {
+	passed bool
+	failed bool
+}
+
+// flakiness scores each workflow name over the last history pipelines,
+// counting reruns within a pipeline and pipelines that share a revision.
+func (workflowInfo WorkflowDetails) flakiness(history int) (map[string]Flakiness, error) {
+	if history == 0 {
+		return nil, nil
+	}
+	outcomes := map[string]map[string]*revisionOutcome{}
+	for index, pipeline := range workflowInfo.FilteredPipelines {
+		if index == history {
+			break
+		}
+		if pipeline.ConfigError() || pipeline.VCS.Revision == "" {
+			continue
+		}
+		// Every pipeline in the history is asked about afresh rather than
+		// from the cache, as any of them may still be rerun from failed.
+		workflows, err := workflowInfo.CircleCIClient.GetWorkflowsForPipeline(pipeline)
+		if err != nil {
+			return nil, err
+		}
+		for _, workflow := range workflows {
+			if workflow.Status != "success" && workflow.Status != "failed" {
+				continue
+			}
+			revisions, ok := outcomes[workflow.Name]
+			if !ok {
+				revisions = map[string]*revisionOutcome{}
+				outcomes[workflow.Name] = revisions
+			}
+			outcome, ok := revisions[pipeline.VCS.Revision]
+			if !ok {
+				outcome = &revisionOutcome{}
+				revisions[pipeline.VCS.Revision] = outcome
+			}
+			if workflow.Status == "success" {
+				outcome.passed = true
+			} else {
+				outcome.failed = true
+			}
+		}
+	}
+	scores := map[string]Flakiness{}
+	for name, revisions := range outcomes {
+		score := Flakiness{Revisions: len(revisions)}
+		for _, outcome := range revisions {
+			if outcome.passed && outcome.failed {
+				score.Flaky++
+			}
+		}
+		score.Score = float64(score.Flaky) / float64(score.Revisions)
+		scores[name] = score
+	}
+	return scores, nil
+}
+
+// MostFlaky returns the flaky monitors, worst first.
+func (d Monitors) MostFlaky() Monitors {
+	var flaky Monitors
+	for _, monitor := range d {
+		if monitor.Flakiness != nil {
+			flaky = append(flaky, monitor)
+		}
+	}
+	sort.SliceStable(flaky, func(i, j int) bool {
+		if flaky[i].Flakiness.Score != flaky[j].Flakiness.Score {
+			return flaky[i].Flakiness.Score > flaky[j].Flakiness.Score
+		}
+		return flaky[i].Flakiness.Flaky > flaky[j].Flakiness.Flaky
+	})
+	return flaky
+}
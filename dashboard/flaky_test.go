@@ -0,0 +1,146 @@
+package dashboard_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+var _ = Describe("Flakiness", func() {
+	Describe("#Percent", func() {
+		It("rounds the score to a whole percentage", func() {
+			Ω(dashboard.Flakiness{Score: 1.0 / 3}.Percent()).Should(Equal(33))
+			Ω(dashboard.Flakiness{Score: 1}.Percent()).Should(Equal(100))
+		})
+	})
+
+	Describe("#MostFlaky", func() {
+		It("ranks flaky monitors worst first", func() {
+			monitors := dashboard.Monitors{
+				{Name: "steady"},
+				{Name: "sometimes", Flakiness: &dashboard.Flakiness{Score: 0.2, Flaky: 2, Revisions: 10}},
+				{Name: "often", Flakiness: &dashboard.Flakiness{Score: 0.5, Flaky: 1, Revisions: 2}},
+				{Name: "sometimes more", Flakiness: &dashboard.Flakiness{Score: 0.2, Flaky: 4, Revisions: 20}},
+			}
+			var names []string
+			for _, monitor := range monitors.MostFlaky() {
+				names = append(names, monitor.Name)
+			}
+			Ω(names).Should(Equal([]string{"often", "sometimes more", "sometimes"}))
+		})
+	})
+
+	Describe("scoring monitors", func() {
+		var (
+			circleCIClient *mocks.CircleCI
+			project        = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example"}
+			pipelines      = circleci.Pipelines{
+				{ID: "4", VCS: circleci.VCS{Revision: "ccc"}},
+				{ID: "3", VCS: circleci.VCS{Revision: "bbb"}},
+				{ID: "2", VCS: circleci.VCS{Revision: "aaa"}},
+				{ID: "1", VCS: circleci.VCS{Revision: "aaa"}},
+			}
+			workflowInfo  dashboard.WorkflowDetails
+			monitorConfig *dashboard.MonitorConfig
+		)
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+			circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+			monitorConfig = &dashboard.MonitorConfig{FlakyHistory: 10}
+			workflowInfo = dashboard.WorkflowDetails{
+				CircleCIClient: circleCIClient,
+				Project:        project,
+				Workflows: circleci.Workflows{
+					{ID: "4b", Name: "build", Status: "success"},
+					{ID: "4d", Name: "deploy", Status: "success"},
+				},
+				Pipeline:          pipelines[0],
+				FilteredPipelines: pipelines,
+			}
+		})
+
+		Context("when getting workflows errors", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetWorkflowsForPipeline", mock.Anything).Return(nil, fmt.Errorf("Error getting workflows"))
+			})
+
+			It("leaves the flaky badges off the tiles", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, monitorConfig)
+				Ω(err).Should(BeNil())
+				Ω(monitors).Should(HaveLen(2))
+				Ω(monitors[0].Flakiness).Should(BeNil())
+			})
+		})
+
+		Context("when a workflow passed and failed on the same revision", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetWorkflowsForPipeline", pipelines[0]).Return(workflowInfo.Workflows, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", pipelines[1]).Return(circleci.Workflows{
+					{Name: "build", Status: "success"},
+					{Name: "build", Status: "failed"},
+					{Name: "deploy", Status: "success"},
+				}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", pipelines[2]).Return(circleci.Workflows{
+					{Name: "build", Status: "success"},
+					{Name: "deploy", Status: "success"},
+				}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", pipelines[3]).Return(circleci.Workflows{
+					{Name: "build", Status: "failed"},
+					{Name: "deploy", Status: "running"},
+				}, nil)
+			})
+
+			It("scores it by its flaky revisions", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, monitorConfig)
+				Ω(err).Should(BeNil())
+				Ω(monitors).Should(HaveLen(2))
+				Ω(monitors[0].Flakiness).Should(Equal(&dashboard.Flakiness{Score: 2.0 / 3, Flaky: 2, Revisions: 3}))
+				Ω(monitors[1].Flakiness).Should(BeNil())
+			})
+
+			It("only looks back as far as the history allows", func() {
+				monitorConfig.FlakyHistory = 2
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, monitorConfig)
+				Ω(err).Should(BeNil())
+				Ω(monitors[0].Flakiness).Should(Equal(&dashboard.Flakiness{Score: 0.5, Flaky: 1, Revisions: 2}))
+				circleCIClient.AssertNotCalled(GinkgoT(), "GetWorkflowsForPipeline", pipelines[2])
+			})
+
+			It("asks afresh about earlier pipelines, so that a later rerun counts", func() {
+				workflowInfo.Cache = dashboard.NewCache()
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, monitorConfig)
+				Ω(err).Should(BeNil())
+				Ω(monitors[1].Flakiness).Should(BeNil())
+				for _, call := range circleCIClient.ExpectedCalls {
+					if call.Method == "GetWorkflowsForPipeline" && call.Arguments[0].(circleci.Pipeline).ID == pipelines[2].ID {
+						call.ReturnArguments = mock.Arguments{circleci.Workflows{
+							{Name: "build", Status: "success"},
+							{Name: "deploy", Status: "success"},
+							{Name: "deploy", Status: "failed"},
+						}, nil}
+					}
+				}
+				monitors, err = dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, monitorConfig)
+				Ω(err).Should(BeNil())
+				Ω(monitors[1].Flakiness).Should(Equal(&dashboard.Flakiness{Score: 1.0 / 3, Flaky: 1, Revisions: 3}))
+			})
+		})
+
+		Context("when the history is turned off", func() {
+			It("does not look at earlier pipelines", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(BeNil())
+				Ω(monitors[0].Flakiness).Should(BeNil())
+				circleCIClient.AssertNotCalled(GinkgoT(), "GetWorkflowsForPipeline", mock.Anything)
+			})
+		})
+	})
+})
@@ -0,0 +1,267 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const week = 7 * 24 * time.Hour
+
+// Outage is a period during which a monitor was red. End is nil while it is
+// still red.
+type Outage struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// Duration is how long the outage lasted, or has lasted so far.
+func (o Outage) Duration(now time.Time) time.Duration {
+	if o.End != nil {
+		return o.End.Sub(o.Start)
+	}
+	return now.Sub(o.Start)
+}
+
+type monitorHistory struct {
+	FirstSeen time.Time `json:"first_seen"`
+	Outages   []Outage  `json:"outages,omitempty"`
+}
+
+func (m *monitorHistory) open() *Outage {
+	if len(m.Outages) == 0 || m.Outages[len(m.Outages)-1].End != nil {
+		return nil
+	}
+	return &m.Outages[len(m.Outages)-1]
+}
+
+// History records when each monitor went red and green again, keyed by
+// Monitor.ID. If it was loaded from a file, every change is saved back to it
+// so that streaks survive restarts.
+type History struct {
+	mu       sync.Mutex
+	filename string
+	monitors map[string]*monitorHistory
+}
+
+// NewHistory keeps the history in memory only.
+func NewHistory() *History {
+	return &History{monitors: map[string]*monitorHistory{}}
+}
+
+// LoadHistory reads the history saved in filename, which need not exist yet.
+func LoadHistory(filename string) (*History, error) {
+	history := NewHistory()
+	history.filename = filename
+	if err := history.Reload(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Reload reads the history back from its file, replacing what is held in
+// memory, so that a replica that takes the lead carries on from what the last
+// leader saved.
+func (h *History) Reload() error {
+	if h.filename == "" {
+		return nil
+	}
+	monitors := map[string]*monitorHistory{}
+	if err := loadJSON(h.filename, &monitors); err != nil {
+		return fmt.Errorf("Error loading history from %s: %v", h.filename, err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.monitors = monitors
+	return nil
+}
+
+// Record notes any monitors that have gone red or green since the last call,
+// and returns the monitors with RedSince set on those that are red.
+func (h *History) Record(monitors Monitors, now time.Time) (Monitors, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var changed bool
+	recorded := make(Monitors, len(monitors))
+	for index, monitor := range monitors {
+		id := monitor.ID()
+		history, ok := h.monitors[id]
+		if !ok {
+			history = &monitorHistory{FirstSeen: now}
+			h.monitors[id] = history
+			changed = true
+		}
+		open := history.open()
+		switch {
+		case monitor.Failed() && open == nil:
+			history.Outages = append(history.Outages, Outage{Start: now})
+			open = &history.Outages[len(history.Outages)-1]
+			changed = true
+		case !monitor.Failed() && open != nil:
+			end := now
+			open.End = &end
+			open = nil
+			changed = true
+		}
+		if open != nil {
+			redSince := open.Start
+			monitor.RedSince = &redSince
+		}
+		recorded[index] = monitor
+	}
+	if !changed || h.filename == "" {
+		return recorded, nil
+	}
+	return recorded, saveJSON(h.filename, h.monitors)
+}
+
+// loadJSON reads v from filename, leaving it as it is if the file does not
+// exist yet.
+func loadJSON(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveJSON replaces filename with v as JSON, without leaving a partly written
+// file behind if it fails.
+func saveJSON(filename string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Recovery summarises a monitor's outages.
+type Recovery struct {
+	Since            time.Time `json:"since"`
+	Breakages        int       `json:"breakages"`
+	BreakagesPerWeek float64   `json:"breakages_per_week"`
+	// MTTRSeconds is the mean time to recovery of the outages that have
+	// ended. LongestSeconds includes any outage still going on.
+	MTTRSeconds    int64 `json:"mttr_seconds"`
+	LongestSeconds int64 `json:"longest_seconds"`
+}
+
+// MTTR renders the mean time to recovery, e.g. "2h 5m".
+func (r Recovery) MTTR() string {
+	return formatDuration(time.Duration(r.MTTRSeconds) * time.Second)
+}
+
+// Longest renders the longest outage, e.g. "1d 3h".
+func (r Recovery) Longest() string {
+	return formatDuration(time.Duration(r.LongestSeconds) * time.Second)
+}
+
+// Recovery summarises the outages of the monitor with the given ID that
+// started at or after since, or all of them if since is zero.
+func (h *History) Recovery(id string, since, now time.Time) Recovery {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	history, ok := h.monitors[id]
+	if !ok {
+		return Recovery{Since: now}
+	}
+	if since.Before(history.FirstSeen) {
+		since = history.FirstSeen
+	}
+	recovery := Recovery{Since: since}
+	var (
+		recovered int
+		total     time.Duration
+	)
+	for _, outage := range history.Outages {
+		if outage.Start.Before(since) {
+			continue
+		}
+		recovery.Breakages++
+		duration := outage.Duration(now)
+		if int64(duration.Seconds()) > recovery.LongestSeconds {
+			recovery.LongestSeconds = int64(duration.Seconds())
+		}
+		if outage.End != nil {
+			recovered++
+			total += duration
+		}
+	}
+	if recovered > 0 {
+		recovery.MTTRSeconds = int64((total / time.Duration(recovered)).Seconds())
+	}
+	weeks := now.Sub(since).Hours() / week.Hours()
+	if weeks < 1 {
+		weeks = 1
+	}
+	recovery.BreakagesPerWeek = float64(recovery.Breakages) / weeks
+	return recovery
+}
+
+// WeeklySummary is a monitor's recovery over the last week.
+type WeeklySummary struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Workflow string   `json:"workflow"`
+	Branch   string   `json:"branch"`
+	Tag      string   `json:"tag,omitempty"`
+	Trigger  string   `json:"trigger,omitempty"`
+	Recovery Recovery `json:"recovery"`
+}
+
+// WeeklySummary summarises the last week of each monitor.
+func (h *History) WeeklySummary(monitors Monitors, now time.Time) []WeeklySummary {
+	var summaries []WeeklySummary
+	for _, monitor := range monitors {
+		summaries = append(summaries, WeeklySummary{
+			ID:       monitor.ID(),
+			Name:     monitor.Name,
+			Workflow: monitor.Workflow,
+			Branch:   monitor.Branch,
+			Tag:      monitor.Tag,
+			Trigger:  monitor.Trigger,
+			Recovery: h.Recovery(monitor.ID(), now.Add(-week), now),
+		})
+	}
+	return summaries
+}
+
+// RedFor renders how long the monitor has been red, e.g. "red for 3h 10m",
+// or nothing if it is not red.
+func (m Monitor) RedFor() string {
+	if m.RedSince == nil {
+		return ""
+	}
+	return fmt.Sprintf("red for %s", formatDuration(now().Sub(*m.RedSince)))
+}
+
+func formatDuration(duration time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case duration >= day:
+		return fmt.Sprintf("%dd %dh", duration/day, (duration%day)/time.Hour)
+	case duration >= time.Hour:
+		return fmt.Sprintf("%dh %dm", duration/time.Hour, (duration%time.Hour)/time.Minute)
+	default:
+		return fmt.Sprintf("%dm", duration/time.Minute)
+	}
+}
@@ -0,0 +1,150 @@
+package dashboard_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("History", func() {
+	var (
+		start   = time.Date(2020, 9, 1, 9, 0, 0, 0, time.UTC)
+		green   = dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "success"}
+		red     = dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "running failed"}
+		history *dashboard.History
+	)
+
+	BeforeEach(func() {
+		history = dashboard.NewHistory()
+	})
+
+	record := func(monitor dashboard.Monitor, at time.Time) dashboard.Monitor {
+		monitors, err := history.Record(dashboard.Monitors{monitor}, at)
+		Ω(err).Should(BeNil())
+		return monitors[0]
+	}
+
+	Describe("#Record", func() {
+		It("marks red monitors with when they went red", func() {
+			Ω(record(green, start).RedSince).Should(BeNil())
+			Ω(*record(red, start.Add(time.Hour)).RedSince).Should(Equal(start.Add(time.Hour)))
+			Ω(*record(red, start.Add(2*time.Hour)).RedSince).Should(Equal(start.Add(time.Hour)))
+			Ω(record(green, start.Add(3*time.Hour)).RedSince).Should(BeNil())
+		})
+	})
+
+	Describe("#Recovery", func() {
+		BeforeEach(func() {
+			record(green, start)
+			record(red, start.Add(time.Hour))
+			record(green, start.Add(2*time.Hour))
+			record(red, start.Add(24*time.Hour))
+			record(green, start.Add(28*time.Hour))
+			record(red, start.Add(13*24*time.Hour))
+		})
+
+		It("summarises every outage", func() {
+			recovery := history.Recovery(green.ID(), time.Time{}, start.Add(14*24*time.Hour))
+			Ω(recovery.Since).Should(Equal(start))
+			Ω(recovery.Breakages).Should(Equal(3))
+			Ω(recovery.BreakagesPerWeek).Should(BeNumerically("~", 1.5))
+			Ω(recovery.MTTR()).Should(Equal("2h 30m"))
+			Ω(recovery.Longest()).Should(Equal("1d 0h"))
+		})
+
+		It("only counts outages that started in the period", func() {
+			recovery := history.Recovery(green.ID(), start.Add(12*time.Hour), start.Add(14*24*time.Hour))
+			Ω(recovery.Breakages).Should(Equal(2))
+			Ω(recovery.MTTRSeconds).Should(Equal(int64(4 * 60 * 60)))
+		})
+
+		It("has nothing for unknown monitors", func() {
+			Ω(history.Recovery("unknown", time.Time{}, start).Breakages).Should(BeZero())
+		})
+	})
+
+	Describe("#WeeklySummary", func() {
+		It("summarises the last week of each monitor", func() {
+			record(red, start)
+			record(green, start.Add(time.Hour))
+			record(red, start.Add(8*24*time.Hour))
+			record(green, start.Add(8*24*time.Hour+30*time.Minute))
+			summaries := history.WeeklySummary(dashboard.Monitors{green}, start.Add(9*24*time.Hour))
+			Ω(summaries).Should(HaveLen(1))
+			Ω(summaries[0].ID).Should(Equal(green.ID()))
+			Ω(summaries[0].Workflow).Should(Equal("build"))
+			Ω(summaries[0].Recovery.Breakages).Should(Equal(1))
+			Ω(summaries[0].Recovery.MTTR()).Should(Equal("30m"))
+		})
+	})
+
+	Describe("#LoadHistory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "history")
+			Ω(err).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("starts empty without a file", func() {
+			history, err := dashboard.LoadHistory(filepath.Join(dir, "history.json"))
+			Ω(err).Should(BeNil())
+			Ω(history.Recovery(green.ID(), time.Time{}, start).Breakages).Should(BeZero())
+		})
+
+		It("keeps red streaks across restarts", func() {
+			filename := filepath.Join(dir, "history.json")
+			history, err := dashboard.LoadHistory(filename)
+			Ω(err).Should(BeNil())
+			_, err = history.Record(dashboard.Monitors{red}, start)
+			Ω(err).Should(BeNil())
+
+			restarted, err := dashboard.LoadHistory(filename)
+			Ω(err).Should(BeNil())
+			monitors, err := restarted.Record(dashboard.Monitors{red}, start.Add(time.Hour))
+			Ω(err).Should(BeNil())
+			Ω(*monitors[0].RedSince).Should(Equal(start))
+		})
+
+		It("reloads what another replica saved", func() {
+			filename := filepath.Join(dir, "history.json")
+			follower, err := dashboard.LoadHistory(filename)
+			Ω(err).Should(BeNil())
+			leader, err := dashboard.LoadHistory(filename)
+			Ω(err).Should(BeNil())
+			_, err = leader.Record(dashboard.Monitors{red}, start)
+			Ω(err).Should(BeNil())
+
+			Ω(follower.Reload()).Should(Succeed())
+			monitors, err := follower.Record(dashboard.Monitors{red}, start.Add(time.Hour))
+			Ω(err).Should(BeNil())
+			Ω(*monitors[0].RedSince).Should(Equal(start))
+		})
+
+		It("rejects a corrupt file", func() {
+			filename := filepath.Join(dir, "history.json")
+			Ω(ioutil.WriteFile(filename, []byte("{"), 0600)).Should(Succeed())
+			_, err := dashboard.LoadHistory(filename)
+			Ω(err).Should(MatchError(HavePrefix("Error loading history from " + filename)))
+		})
+	})
+
+	Describe("#RedFor", func() {
+		It("says how long a red monitor has been red", func() {
+			redSince := time.Now().Add(-90 * time.Minute)
+			Ω(dashboard.Monitor{RedSince: &redSince}.RedFor()).Should(Equal("red for 1h 30m"))
+			Ω(green.RedFor()).Should(BeEmpty())
+		})
+	})
+})
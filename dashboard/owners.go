@@ -0,0 +1,118 @@
+package dashboard
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Owner is who to talk to about a red monitor.
+type Owner struct {
+	Team    string `json:"team" yaml:"team"`
+	Contact string `json:"contact,omitempty" yaml:"contact"`
+	Runbook string `json:"runbook,omitempty" yaml:"runbook"`
+	Docs    string `json:"docs,omitempty" yaml:"docs"`
+}
+
+// OwnerRule gives the monitors whose username/reponame, branch and workflow
+// match its globs an owner. An empty glob matches anything.
+type OwnerRule struct {
+	Project  string `yaml:"project"`
+	Branch   string `yaml:"branch"`
+	Workflow string `yaml:"workflow"`
+	Owner    `yaml:",inline"`
+}
+
+// Owners are the rules of an owners file, e.g.
+//
+//	owners:
+//	- project: myorg/*
+//	  team: platform
+//	  contact: "@platform-oncall"
+//	- project: myorg/payments-*
+//	  workflow: deploy*
+//	  team: payments
+//	  runbook: https://wiki.example.com/payments/deploys
+type Owners []OwnerRule
+
+// LoadOwners reads an owners file.
+func LoadOwners(filename string) (Owners, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Owners Owners `yaml:"owners"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("Error loading %s: %v", filename, err)
+	}
+	for index, rule := range file.Owners {
+		if rule.Team == "" {
+			return nil, fmt.Errorf("Error loading %s: owner %d needs a team", filename, index+1)
+		}
+		for _, pattern := range []string{rule.Project, rule.Branch, rule.Workflow} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Error loading %s: invalid pattern %q", filename, pattern)
+			}
+		}
+	}
+	return file.Owners, nil
+}
+
+func ownerMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+// Find returns the owner of the last rule that matches, as later rules are
+// more specific, or nil if none do.
+func (o Owners) Find(project, branch, workflow string) *Owner {
+	for index := len(o) - 1; index >= 0; index-- {
+		rule := o[index]
+		if ownerMatch(rule.Project, project) && ownerMatch(rule.Branch, branch) && ownerMatch(rule.Workflow, workflow) {
+			owner := rule.Owner
+			return &owner
+		}
+	}
+	return nil
+}
+
+// Team is the monitor's owning team, or "" if it has none.
+func (m Monitor) Team() string {
+	if m.Owner == nil {
+		return ""
+	}
+	return m.Owner.Team
+}
+
+// ForTeam returns the monitors owned by the team.
+func (d Monitors) ForTeam(team string) Monitors {
+	var owned Monitors
+	for _, monitor := range d {
+		if monitor.Team() == team {
+			owned = append(owned, monitor)
+		}
+	}
+	return owned
+}
+
+// GroupByTeam puts each team's monitors next to each other, in team order,
+// followed by the monitors without an owner.
+func (d Monitors) GroupByTeam() Monitors {
+	grouped := append(Monitors(nil), d...)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		iTeam, jTeam := grouped[i].Team(), grouped[j].Team()
+		if iTeam == "" || jTeam == "" {
+			return jTeam == "" && iTeam != ""
+		}
+		return iTeam < jTeam
+	})
+	return grouped
+}
@@ -0,0 +1,119 @@
+package dashboard_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("Owners", func() {
+	var owners = dashboard.Owners{
+		{Project: "foobar/*", Owner: dashboard.Owner{Team: "platform", Contact: "@platform"}},
+		{Project: "foobar/payments-*", Workflow: "deploy*", Owner: dashboard.Owner{Team: "payments", Runbook: "https://wiki/payments"}},
+		{Project: "foobar/*", Branch: "release/*", Owner: dashboard.Owner{Team: "release"}},
+	}
+
+	Describe("#Find", func() {
+		It("uses the last matching rule", func() {
+			Ω(owners.Find("foobar/payments-api", "master", "deploy-production").Team).Should(Equal("payments"))
+			Ω(owners.Find("foobar/payments-api", "master", "test").Team).Should(Equal("platform"))
+			Ω(owners.Find("foobar/payments-api", "release/1.0", "deploy-production").Team).Should(Equal("release"))
+		})
+
+		It("returns nil when nothing matches", func() {
+			Ω(owners.Find("another/example", "master", "test")).Should(BeNil())
+			Ω(dashboard.Owners(nil).Find("foobar/example", "master", "test")).Should(BeNil())
+		})
+	})
+
+	Describe("#NewMonitor", func() {
+		It("attaches the owner", func() {
+			project := circleci.Project{VCSType: "github", Username: "foobar", Reponame: "payments-api"}
+			pipeline := circleci.Pipeline{VCS: circleci.VCS{Branch: "master"}}
+			monitor := dashboard.NewMonitor(project, pipeline, circleci.Workflow{Name: "deploy"}, "success", "", &dashboard.MonitorConfig{Owners: owners})
+			Ω(monitor.Owner).Should(Equal(&dashboard.Owner{Team: "payments", Runbook: "https://wiki/payments"}))
+			Ω(monitor.Team()).Should(Equal("payments"))
+		})
+	})
+
+	Describe("teams", func() {
+		var monitors = dashboard.Monitors{
+			{Name: "unowned"},
+			{Name: "web", Owner: &dashboard.Owner{Team: "web"}},
+			{Name: "api", Owner: &dashboard.Owner{Team: "api"}},
+			{Name: "web2", Owner: &dashboard.Owner{Team: "web"}},
+		}
+
+		It("filters by team", func() {
+			Ω(monitors.ForTeam("web")).Should(Equal(dashboard.Monitors{monitors[1], monitors[3]}))
+		})
+
+		It("groups by team with unowned monitors last", func() {
+			Ω(monitors.GroupByTeam()).Should(Equal(dashboard.Monitors{monitors[2], monitors[1], monitors[3], monitors[0]}))
+		})
+	})
+
+	Describe("#LoadOwners", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "owners")
+			Ω(err).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		write := func(contents string) string {
+			filename := filepath.Join(dir, "owners.yaml")
+			Ω(ioutil.WriteFile(filename, []byte(contents), 0600)).Should(Succeed())
+			return filename
+		}
+
+		It("reads the rules", func() {
+			filename := write(`owners:
+- project: foobar/*
+  branch: master
+  workflow: deploy
+  team: platform
+  contact: "@platform"
+  runbook: https://wiki/runbook
+  docs: https://wiki/docs
+`)
+			owners, err := dashboard.LoadOwners(filename)
+			Ω(err).Should(BeNil())
+			Ω(owners).Should(Equal(dashboard.Owners{{
+				Project:  "foobar/*",
+				Branch:   "master",
+				Workflow: "deploy",
+				Owner:    dashboard.Owner{Team: "platform", Contact: "@platform", Runbook: "https://wiki/runbook", Docs: "https://wiki/docs"},
+			}}))
+		})
+
+		It("needs a team for every rule", func() {
+			filename := write("owners:\n- project: foobar/*\n")
+			_, err := dashboard.LoadOwners(filename)
+			Ω(err).Should(MatchError("Error loading " + filename + ": owner 1 needs a team"))
+		})
+
+		It("rejects unknown fields", func() {
+			filename := write("owners:\n- project: foobar/*\n  team: platform\n  slack: '#platform'\n")
+			_, err := dashboard.LoadOwners(filename)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("rejects invalid patterns", func() {
+			filename := write("owners:\n- project: foobar/[\n  team: platform\n")
+			_, err := dashboard.LoadOwners(filename)
+			Ω(err).Should(MatchError("Error loading " + filename + `: invalid pattern "foobar/["`))
+		})
+	})
+})
@@ -0,0 +1,119 @@
+package dashboard
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+// progressHistory is how many earlier successful runs of a workflow are used
+// to estimate how long it takes.
+const progressHistory = 10
+
+// progressLookback is how many earlier pipelines are searched for those runs,
+// so that a workflow that rarely succeeds does not page through them all.
+const progressLookback = 30
+
+var now = time.Now
+
+var activeWorkflowStatuses = map[string]bool{
+	"running": true,
+	"failing": true,
+	"on_hold": true,
+}
+
+// Progress describes how far through its jobs a running workflow is.
+type Progress struct {
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Job       string `json:"job,omitempty"`
+	// ETA is when the workflow should finish, going by the median duration
+	// of its recent successful runs. It is nil without any history.
+	ETA *time.Time `json:"eta,omitempty"`
+}
+
+// Percent is the share of jobs completed, for drawing a progress bar.
+func (p Progress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Completed * 100 / p.Total
+}
+
+// String renders e.g. "3/7 jobs · deploy-staging · ~4m left".
+func (p Progress) String() string {
+	parts := []string{fmt.Sprintf("%d/%d jobs", p.Completed, p.Total)}
+	if p.Job != "" {
+		parts = append(parts, p.Job)
+	}
+	if p.ETA != nil {
+		if left := p.ETA.Sub(now()); left > 0 {
+			parts = append(parts, fmt.Sprintf("~%dm left", int(math.Ceil(left.Minutes()))))
+		} else {
+			parts = append(parts, "overdue")
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (workflowInfo WorkflowDetails) progress(workflow circleci.Workflow) (*Progress, error) {
+	jobs, err := workflowInfo.CircleCIClient.GetJobsForWorkflow(workflow)
+	if err != nil {
+		return nil, err
+	}
+	progress := &Progress{Completed: jobs.Finished(), Total: len(jobs)}
+	if job, ok := jobs.Current(); ok {
+		progress.Job = job.Name
+	}
+	// Without the history the progress is still worth showing, just without
+	// an ETA.
+	median, ok, err := workflowInfo.medianDuration(workflow)
+	if err != nil {
+		slog.Warn("Error estimating a workflow's duration", "project", workflowInfo.Project.Slug(), "workflow", workflow.Name, "err", err)
+	}
+	if err == nil && ok && !workflow.CreatedAt.IsZero() {
+		eta := workflow.CreatedAt.Add(median)
+		progress.ETA = &eta
+	}
+	return progress, nil
+}
+
+// medianDuration looks back through the earlier pipelines for successful runs
+// of the same workflow.
+func (workflowInfo WorkflowDetails) medianDuration(current circleci.Workflow) (time.Duration, bool, error) {
+	var durations []time.Duration
+	for index, pipeline := range workflowInfo.FilteredPipelines {
+		if len(durations) == progressHistory || index == progressLookback {
+			break
+		}
+		if pipeline.ID == workflowInfo.Pipeline.ID || pipeline.ConfigError() {
+			continue
+		}
+		workflows, err := workflowInfo.Cache.pipelineWorkflows(workflowInfo.CircleCIClient, pipeline)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, workflow := range workflows {
+			if workflow.Name != current.Name || workflow.Status != "success" {
+				continue
+			}
+			if duration, ok := workflow.Duration(); ok {
+				durations = append(durations, duration)
+			}
+		}
+	}
+	if len(durations) == 0 {
+		return 0, false, nil
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	middle := len(durations) / 2
+	if len(durations)%2 == 0 {
+		return (durations[middle-1] + durations[middle]) / 2, true, nil
+	}
+	return durations[middle], true, nil
+}
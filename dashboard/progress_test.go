@@ -0,0 +1,142 @@
+package dashboard_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+var _ = Describe("Progress", func() {
+	Describe("#String", func() {
+		It("shows jobs, the current job and the time left", func() {
+			eta := time.Now().Add(4 * time.Minute)
+			progress := dashboard.Progress{Completed: 3, Total: 7, Job: "deploy-staging", ETA: &eta}
+			Ω(progress.String()).Should(Equal("3/7 jobs · deploy-staging · ~4m left"))
+		})
+
+		It("leaves out what it does not know", func() {
+			Ω(dashboard.Progress{Completed: 0, Total: 2}.String()).Should(Equal("0/2 jobs"))
+		})
+
+		It("says when a workflow has run over its usual time", func() {
+			eta := time.Now().Add(-time.Minute)
+			Ω(dashboard.Progress{Completed: 1, Total: 2, ETA: &eta}.String()).Should(Equal("1/2 jobs · overdue"))
+		})
+	})
+
+	Describe("#Percent", func() {
+		It("is the share of jobs completed", func() {
+			Ω(dashboard.Progress{Completed: 3, Total: 4}.Percent()).Should(Equal(75))
+			Ω(dashboard.Progress{}.Percent()).Should(Equal(0))
+		})
+	})
+
+	Describe("running monitors", func() {
+		var (
+			circleCIClient *mocks.CircleCI
+			project        = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example"}
+			started        = time.Now().Add(-2 * time.Minute)
+			running        = circleci.Workflow{ID: "3", Name: "deploy", Status: "running", CreatedAt: started}
+			pipelines      = circleci.Pipelines{{ID: "3"}, {ID: "2"}, {ID: "1"}}
+			finished       = func(id string, minutes int) circleci.Workflows {
+				createdAt := started.Add(-time.Hour)
+				stoppedAt := createdAt.Add(time.Duration(minutes) * time.Minute)
+				return circleci.Workflows{{ID: id, Name: "deploy", Status: "success", CreatedAt: createdAt, StoppedAt: &stoppedAt}}
+			}
+			workflowInfo dashboard.WorkflowDetails
+		)
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("running success", nil)
+			circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+			workflowInfo = dashboard.WorkflowDetails{
+				CircleCIClient:    circleCIClient,
+				Project:           project,
+				Workflows:         circleci.Workflows{running},
+				Pipeline:          pipelines[0],
+				FilteredPipelines: pipelines,
+			}
+		})
+
+		Context("when getting jobs errors", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetJobsForWorkflow", running).Return(nil, fmt.Errorf("Error getting jobs"))
+			})
+
+			It("returns an error", func() {
+				_, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(MatchError("Error getting jobs"))
+			})
+		})
+
+		Context("when getting jobs is successful", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetJobsForWorkflow", running).Return(circleci.Jobs{
+					{Name: "build", Status: "success"},
+					{Name: "approve-staging", Status: "success"},
+					{Name: "deploy-staging", Status: "running"},
+					{Name: "smoke-test", Status: "blocked"},
+				}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", pipelines[1]).Return(finished("2", 5), nil)
+				circleCIClient.On("GetWorkflowsForPipeline", pipelines[2]).Return(finished("1", 7), nil)
+			})
+
+			It("adds progress with an ETA from the median duration", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(BeNil())
+				Ω(monitors).Should(HaveLen(1))
+				progress := monitors[0].Progress
+				Ω(progress).ShouldNot(BeNil())
+				Ω(progress.Completed).Should(Equal(2))
+				Ω(progress.Total).Should(Equal(4))
+				Ω(progress.Job).Should(Equal("deploy-staging"))
+				Ω(*progress.ETA).Should(Equal(started.Add(6 * time.Minute)))
+				Ω(progress.String()).Should(Equal("2/4 jobs · deploy-staging · ~4m left"))
+			})
+
+			It("only asks once about earlier pipelines that have finished", func() {
+				workflowInfo.Cache = dashboard.NewCache()
+				for i := 0; i < 2; i++ {
+					_, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+					Ω(err).Should(BeNil())
+				}
+				circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetWorkflowsForPipeline", 2)
+			})
+		})
+
+		Context("when getting earlier pipelines errors", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetJobsForWorkflow", running).Return(circleci.Jobs{{Name: "build", Status: "running"}}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", mock.Anything).Return(nil, fmt.Errorf("Error getting workflows"))
+			})
+
+			It("adds progress without an ETA", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(BeNil())
+				Ω(monitors[0].Progress).ShouldNot(BeNil())
+				Ω(monitors[0].Progress.ETA).Should(BeNil())
+			})
+		})
+
+		Context("when the workflow has finished", func() {
+			BeforeEach(func() {
+				workflowInfo.Workflows = finished("3", 5)
+			})
+
+			It("does not fetch its jobs", func() {
+				monitors, err := dashboard.Monitors{}.AddWorkflows(workflowInfo, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+				Ω(err).Should(BeNil())
+				Ω(monitors[0].Progress).Should(BeNil())
+				circleCIClient.AssertNotCalled(GinkgoT(), "GetJobsForWorkflow", mock.Anything)
+			})
+		})
+	})
+})
@@ -0,0 +1,193 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
+)
+
+// State is the provider-neutral state of a workflow run. The values are
+// CircleCI's, as they double as the CSS classes of the tiles.
+type State string
+
+const (
+	StateSuccess  State = "success"
+	StateFailed   State = "failed"
+	StateError    State = "error"
+	StateRunning  State = "running"
+	StateOnHold   State = "on_hold"
+	StateCanceled State = "canceled"
+	StateUnknown  State = "unknown"
+)
+
+// Completed reports whether a run in this state has finished for good, so its
+// state is worth showing behind a newer run that is still going.
+func (s State) Completed() bool {
+	return s == StateSuccess || s == StateFailed || s == StateError || s == StateUnknown
+}
+
+// Project is a repository as a Provider knows it.
+type Project struct {
+	// Name is shown on the tiles, e.g. "myorg/example".
+	Name string
+	// Slug identifies the project to its provider, and to webhooks.
+	Slug string
+	// Branches limits the branches shown, when set.
+	Branches []string
+}
+
+// WorkflowRun is one run of a workflow. Runs that were started together, e.g.
+// by the same CircleCI pipeline or the same push to GitHub, share a PipelineID.
+type WorkflowRun struct {
+	ID         string
+	Name       string
+	PipelineID string
+	Branch     string
+	Tag        string
+	// Trigger is circleci.TriggerSourcePush or circleci.TriggerSourceScheduled.
+	Trigger   string
+	Revision  string
+	State     State
+	Link      string
+	CreatedAt time.Time
+	StoppedAt *time.Time
+}
+
+type WorkflowRuns []WorkflowRun
+
+// Provider is a CI system that monitors can be built from. It is also either a
+// RunLister, whose runs the monitors are built from, or a ProjectBuilder.
+type Provider interface {
+	// Projects lists the projects to show.
+	Projects() ([]Project, error)
+}
+
+// RunLister is implemented by providers whose monitors are built from their
+// workflow runs alone.
+type RunLister interface {
+	// WorkflowRuns lists the recent workflow runs of a project, newest first.
+	WorkflowRuns(Project) (WorkflowRuns, error)
+}
+
+// ProjectBuilder is implemented by providers that build a project's monitors
+// themselves, with details workflow runs do not carry, such as CircleCI's job
+// progress and failing tests.
+type ProjectBuilder interface {
+	BuildProject(Project, *FeatureFlags, *MonitorConfig) (Monitors, error)
+}
+
+// ContextProvider is implemented by providers that can pass a context on to
+// their API calls, so that the calls are traced as part of the project they
+// are made for.
+type ContextProvider interface {
+	WithContext(context.Context) Provider
+}
+
+// ProviderWithContext returns the provider with its API calls carrying the
+// context, if it supports that.
+func ProviderWithContext(ctx context.Context, provider Provider) Provider {
+	if contextProvider, ok := provider.(ContextProvider); ok {
+		return contextProvider.WithContext(ctx)
+	}
+	return provider
+}
+
+// BuildProvider builds the monitors of every project of a provider.
+func BuildProvider(ctx context.Context, provider Provider, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
+	listCtx, span := telemetry.Tracer().Start(ctx, "list projects")
+	projects, err := ProviderWithContext(listCtx, provider).Projects()
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	var monitors Monitors
+	for _, project := range projects {
+		projectMonitors, err := BuildProviderProject(ctx, provider, project, featureFlags, monitorConfig)
+		if err != nil {
+			return nil, err
+		}
+		monitors = monitors.Merge(projectMonitors)
+	}
+	monitors.Sort()
+	return monitors, nil
+}
+
+// BuildProviderProject builds the monitors of a project, with a monitor for
+// the latest run of each workflow on each branch unless the provider builds
+// them itself.
+func BuildProviderProject(ctx context.Context, provider Provider, project Project, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (monitors Monitors, err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "collect project", trace.WithAttributes(attribute.String("project", project.Slug)))
+	defer func() { telemetry.End(span, err) }()
+	provider = ProviderWithContext(ctx, provider)
+	if builder, ok := provider.(ProjectBuilder); ok {
+		return builder.BuildProject(project, featureFlags, monitorConfig)
+	}
+	lister, ok := provider.(RunLister)
+	if !ok {
+		return nil, fmt.Errorf("Provider of %s can neither list runs nor build projects", project.Slug)
+	}
+	runs, err := lister.WorkflowRuns(project)
+	if err != nil {
+		return nil, err
+	}
+	return runs.monitors(project, monitorConfig), nil
+}
+
+func (runs WorkflowRuns) monitors(project Project, monitorConfig *MonitorConfig) Monitors {
+	branches := map[string]bool{}
+	for _, branch := range project.Branches {
+		branches[branch] = true
+	}
+	var (
+		monitors Monitors
+		seen     = map[string]bool{}
+	)
+	for i, run := range runs {
+		if run.Tag != "" || run.Branch == "" {
+			continue
+		}
+		if len(branches) > 0 && !branches[run.Branch] {
+			continue
+		}
+		if monitorConfig.BranchFilter != "" && monitorConfig.BranchFilter != run.Branch {
+			continue
+		}
+		trigger := ""
+		if monitorConfig.SplitByTrigger {
+			trigger = run.Trigger
+		}
+		key := run.Branch + "\x00" + trigger + "\x00" + run.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		status := string(run.State)
+		if !run.State.Completed() {
+			status += " " + string(runs[i+1:].previousState(run, trigger))
+		}
+		monitors = append(monitors, newMonitor(project.Name, run.Branch, "", trigger, run.Name, status, run.Link, monitorConfig))
+	}
+	return monitors
+}
+
+// previousState is the state of the latest completed run of the same workflow
+// on the same branch.
+func (runs WorkflowRuns) previousState(current WorkflowRun, trigger string) State {
+	for _, run := range runs {
+		if run.Name != current.Name || run.Branch != current.Branch || run.Tag != "" {
+			continue
+		}
+		if trigger != "" && run.Trigger != trigger {
+			continue
+		}
+		if run.State.Completed() {
+			return run.State
+		}
+	}
+	return StateUnknown
+}
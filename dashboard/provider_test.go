@@ -0,0 +1,110 @@
+package dashboard_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+type fakeProvider struct {
+	projects []dashboard.Project
+	runs     dashboard.WorkflowRuns
+	err      error
+}
+
+func (f fakeProvider) Projects() ([]dashboard.Project, error) {
+	return f.projects, nil
+}
+
+func (f fakeProvider) WorkflowRuns(dashboard.Project) (dashboard.WorkflowRuns, error) {
+	return f.runs, f.err
+}
+
+var _ = Describe("Provider", func() {
+	Describe("#BuildProvider", func() {
+		var provider fakeProvider
+
+		BeforeEach(func() {
+			provider = fakeProvider{
+				projects: []dashboard.Project{{Name: "myorg/example", Slug: "fake/myorg/example"}},
+				runs: dashboard.WorkflowRuns{
+					{Name: "build", Branch: "master", Trigger: "push", State: dashboard.StateOnHold, Link: "https://ci/3"},
+					{Name: "build", Branch: "master", Trigger: "scheduled", State: dashboard.StateFailed, Link: "https://ci/2"},
+					{Name: "build", Branch: "master", Trigger: "push", State: dashboard.StateSuccess, Link: "https://ci/1"},
+					{Name: "build", Branch: "develop", State: dashboard.StateSuccess},
+					{Name: "release", Tag: "v1.0.0", State: dashboard.StateSuccess},
+				},
+			}
+		})
+
+		It("adds the latest run of each workflow on each branch, behind any completed run", func() {
+			monitors, err := dashboard.BuildProvider(context.Background(), provider, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(2))
+			Ω(monitors[0].Branch).Should(Equal("develop"))
+			Ω(monitors[1].Name).Should(Equal("myorg/example"))
+			Ω(monitors[1].Status).Should(Equal("on_hold failed"))
+			Ω(monitors[1].Link).Should(Equal("https://ci/3"))
+		})
+
+		It("splits runs by trigger when configured", func() {
+			monitors, err := dashboard.BuildProvider(context.Background(), provider, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{SplitByTrigger: true, BranchFilter: "master", HideOrganization: true})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(2))
+			Ω(monitors[0].Name).Should(Equal("example"))
+			Ω(monitors[0].Trigger).Should(Equal("push"))
+			Ω(monitors[0].Status).Should(Equal("on_hold success"))
+			Ω(monitors[1].Trigger).Should(Equal("scheduled"))
+		})
+
+		It("only shows the project's branches when it has some", func() {
+			provider.projects[0].Branches = []string{"develop"}
+			monitors, err := dashboard.BuildProvider(context.Background(), provider, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(1))
+			Ω(monitors[0].Branch).Should(Equal("develop"))
+		})
+
+		It("returns errors getting runs", func() {
+			provider.err = fmt.Errorf("Error getting runs")
+			_, err := dashboard.BuildProvider(context.Background(), provider, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(MatchError("Error getting runs"))
+		})
+	})
+
+	Describe("CircleCIProvider", func() {
+		var (
+			circleCIClient *mocks.CircleCI
+			project        = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example", Branches: map[string]interface{}{"master": nil}}
+			pipeline       = circleci.Pipeline{ID: "1", VCS: circleci.VCS{Branch: "master", Revision: "abc"}}
+			provider       *dashboard.CircleCIProvider
+		)
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("GetAllProjects").Return(circleci.Projects{project, {VCSType: "github", Username: "other", Reponame: "repo"}}, nil)
+			circleCIClient.On("GetAllPipelines", project).Return(circleci.Pipelines{pipeline}, nil)
+			circleCIClient.On("GetWorkflowsForPipeline", pipeline).Return(circleci.Workflows{{ID: "w1", Name: "build", Status: "failed"}}, nil)
+			circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+			provider = dashboard.NewCircleCIProvider(circleCIClient, &circleci.Filter{"foobar/*": nil})
+		})
+
+		It("lists the filtered projects with their branches", func() {
+			projects, err := provider.Projects()
+			Ω(err).Should(BeNil())
+			Ω(projects).Should(Equal([]dashboard.Project{{Name: "foobar/example", Slug: "github/foobar/example", Branches: []string{"master"}}}))
+		})
+
+		It("rejects slugs that are not CircleCI's", func() {
+			_, err := provider.BuildProject(dashboard.Project{Slug: "example"}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(MatchError(`Invalid CircleCI project slug "example"`))
+		})
+	})
+})
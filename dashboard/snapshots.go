@@ -0,0 +1,100 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Snapshot is the dashboard as of a refresh.
+type Snapshot struct {
+	Monitors    Monitors  `json:"monitors"`
+	TakenAt     time.Time `json:"taken_at"`
+	NextRefresh time.Time `json:"next_refresh"`
+	// Err is set when no source could be refreshed, SourceErrors when any
+	// could not.
+	Err          error        `json:"-"`
+	SourceErrors SourceErrors `json:"-"`
+	// Stale is set on a snapshot from before the dashboard started, until
+	// the first refresh since succeeds.
+	Stale bool `json:"-"`
+}
+
+// Age is how long ago the snapshot was taken, e.g. "1h 5m".
+func (s Snapshot) Age(now time.Time) string {
+	return formatDuration(now.Sub(s.TakenAt))
+}
+
+// Snapshots holds the latest snapshot. SnapshotStore is the default, kept in
+// memory and optionally a file. A shared implementation lets several replicas
+// of the dashboard serve the snapshots that one of them collects.
+type Snapshots interface {
+	// Get returns the latest snapshot, or false before there is one.
+	Get() (Snapshot, bool)
+	// Set replaces the latest snapshot. A snapshot with Err set keeps the
+	// monitors of the one before it, if any, marked as stale, so a failed
+	// refresh does not blank the dashboard.
+	Set(Snapshot) error
+}
+
+// SnapshotStore holds the latest snapshot. If it was loaded from a file,
+// every snapshot is saved back to it so that a restarted dashboard has
+// something to show straight away.
+type SnapshotStore struct {
+	mu       sync.Mutex
+	filename string
+	snapshot *Snapshot
+}
+
+func NewSnapshotStore() *SnapshotStore {
+	return &SnapshotStore{}
+}
+
+// LoadSnapshotStore reads the snapshot saved in filename, which need not
+// exist yet, as a stale snapshot.
+func LoadSnapshotStore(filename string) (*SnapshotStore, error) {
+	store := &SnapshotStore{filename: filename}
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("Error loading snapshot from %s: %v", filename, err)
+	}
+	snapshot.Stale = true
+	store.snapshot = &snapshot
+	return store, nil
+}
+
+func (s *SnapshotStore) Get() (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshot == nil {
+		return Snapshot{}, false
+	}
+	return *s.snapshot, true
+}
+
+func (s *SnapshotStore) Set(snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snapshot.Err != nil && s.snapshot != nil {
+		snapshot.Monitors = s.snapshot.Monitors
+		snapshot.TakenAt = s.snapshot.TakenAt
+		snapshot.Stale = true
+		s.snapshot = &snapshot
+		return nil
+	}
+	s.snapshot = &snapshot
+	if s.filename == "" || snapshot.Err != nil {
+		return nil
+	}
+	return saveJSON(s.filename, snapshot)
+}
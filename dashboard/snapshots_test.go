@@ -0,0 +1,97 @@
+package dashboard_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+var _ = Describe("SnapshotStore", func() {
+	var (
+		takenAt  = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+		monitors = dashboard.Monitors{{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "success"}}
+		dir      string
+		filename string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "snapshots")
+		Ω(err).Should(BeNil())
+		filename = filepath.Join(dir, "snapshot.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("has nothing before the first snapshot", func() {
+		_, found := dashboard.NewSnapshotStore().Get()
+		Ω(found).Should(BeFalse())
+	})
+
+	It("returns the latest snapshot", func() {
+		store := dashboard.NewSnapshotStore()
+		Ω(store.Set(dashboard.Snapshot{Monitors: monitors, TakenAt: takenAt})).Should(Succeed())
+		snapshot, found := store.Get()
+		Ω(found).Should(BeTrue())
+		Ω(snapshot.Monitors).Should(Equal(monitors))
+		Ω(snapshot.Stale).Should(BeFalse())
+	})
+
+	It("keeps the previous monitors, as stale, when a refresh fails", func() {
+		store := dashboard.NewSnapshotStore()
+		Ω(store.Set(dashboard.Snapshot{Monitors: monitors, TakenAt: takenAt})).Should(Succeed())
+		Ω(store.Set(dashboard.Snapshot{Err: fmt.Errorf("Unauthorized"), TakenAt: takenAt.Add(time.Minute)})).Should(Succeed())
+		snapshot, _ := store.Get()
+		Ω(snapshot.Monitors).Should(Equal(monitors))
+		Ω(snapshot.TakenAt).Should(Equal(takenAt))
+		Ω(snapshot.Stale).Should(BeTrue())
+		Ω(snapshot.Err).Should(MatchError("Unauthorized"))
+	})
+
+	Describe("#LoadSnapshotStore", func() {
+		It("starts empty when the file does not exist yet", func() {
+			store, err := dashboard.LoadSnapshotStore(filename)
+			Ω(err).Should(BeNil())
+			_, found := store.Get()
+			Ω(found).Should(BeFalse())
+		})
+
+		It("loads the saved snapshot as stale until the next one", func() {
+			store, err := dashboard.LoadSnapshotStore(filename)
+			Ω(err).Should(BeNil())
+			Ω(store.Set(dashboard.Snapshot{Monitors: monitors, TakenAt: takenAt, NextRefresh: takenAt.Add(time.Minute)})).Should(Succeed())
+
+			reloaded, err := dashboard.LoadSnapshotStore(filename)
+			Ω(err).Should(BeNil())
+			snapshot, found := reloaded.Get()
+			Ω(found).Should(BeTrue())
+			Ω(snapshot.Stale).Should(BeTrue())
+			Ω(snapshot.Monitors).Should(Equal(monitors))
+			Ω(snapshot.TakenAt.Equal(takenAt)).Should(BeTrue())
+			Ω(snapshot.Age(takenAt.Add(65 * time.Minute))).Should(Equal("1h 5m"))
+		})
+
+		It("does not save failed refreshes", func() {
+			store, err := dashboard.LoadSnapshotStore(filename)
+			Ω(err).Should(BeNil())
+			Ω(store.Set(dashboard.Snapshot{Err: fmt.Errorf("Unauthorized")})).Should(Succeed())
+			_, err = os.Stat(filename)
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		It("errors on a corrupt file", func() {
+			Ω(ioutil.WriteFile(filename, []byte("{"), 0644)).Should(Succeed())
+			_, err := dashboard.LoadSnapshotStore(filename)
+			Ω(err).Should(MatchError(ContainSubstring("Error loading snapshot from " + filename)))
+		})
+	})
+})
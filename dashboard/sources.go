@@ -0,0 +1,140 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
+)
+
+// Source is a CI provider that monitors are built from. Name is recorded on
+// each of its monitors, and is empty when there is only the one source. Stats,
+// if set, counts its API calls.
+type Source struct {
+	Name     string
+	Provider Provider
+	Stats    *APIStats
+}
+
+// SourceError is an error from one of several sources.
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e SourceError) Error() string {
+	if e.Source == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+// SourceErrors collects the errors of the sources that failed, while the
+// others still have their monitors shown.
+type SourceErrors []SourceError
+
+func (e SourceErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// SetSource records the source on each monitor.
+func (d Monitors) SetSource(source string) Monitors {
+	for i := range d {
+		d[i].Source = source
+	}
+	return d
+}
+
+// BuildSources builds the monitors of every source. A failing source does not
+// stop the others, the monitors that could be built are returned along with
+// SourceErrors for the sources that failed.
+func BuildSources(ctx context.Context, sources []Source, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (Monitors, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "build dashboard")
+	defer span.End()
+	var (
+		monitors Monitors
+		errs     SourceErrors
+	)
+	for _, source := range sources {
+		sourceMonitors, err := buildSource(ctx, source, featureFlags, monitorConfig)
+		if err != nil {
+			errs = append(errs, SourceError{Source: source.Name, Err: err})
+			continue
+		}
+		monitors = monitors.Merge(sourceMonitors.SetSource(source.Name))
+	}
+	monitors.Sort()
+	if len(errs) > 0 {
+		span.SetStatus(codes.Error, errs.Error())
+		return monitors, errs
+	}
+	return monitors, nil
+}
+
+func buildSource(ctx context.Context, source Source, featureFlags *FeatureFlags, monitorConfig *MonitorConfig) (monitors Monitors, err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "build source", trace.WithAttributes(attribute.String("source", source.Name)))
+	defer func() { telemetry.End(span, err) }()
+	return BuildProvider(ctx, source.Provider, featureFlags, monitorConfig)
+}
+
+// SelectProjects limits each source to the projects whose username/reponame
+// matches the glob, e.g. "myorg/*", so that the others are never built. A
+// CircleCI source's filter is replaced, like --project replaces
+// DASHBOARD_FILTER for the envvars command. An empty pattern selects every
+// project.
+func SelectProjects(sources []Source, pattern string) []Source {
+	if pattern == "" {
+		return sources
+	}
+	selected := make([]Source, len(sources))
+	for i, source := range sources {
+		if provider, ok := source.Provider.(*CircleCIProvider); ok {
+			narrowed := *provider
+			narrowed.Filter = &circleci.Filter{pattern: nil}
+			source.Provider = &narrowed
+		} else {
+			source.Provider = selectedProvider{Provider: source.Provider, pattern: pattern}
+		}
+		selected[i] = source
+	}
+	return selected
+}
+
+// selectedProvider lists only the projects of a provider that match a glob.
+type selectedProvider struct {
+	Provider
+	pattern string
+}
+
+func (p selectedProvider) Projects() ([]Project, error) {
+	projects, err := p.Provider.Projects()
+	if err != nil {
+		return nil, err
+	}
+	var selected []Project
+	for _, project := range projects {
+		if matched, err := path.Match(p.pattern, project.Name); err == nil && matched {
+			selected = append(selected, project)
+		}
+	}
+	return selected, nil
+}
+
+func (p selectedProvider) WorkflowRuns(project Project) (WorkflowRuns, error) {
+	lister, ok := p.Provider.(RunLister)
+	if !ok {
+		return nil, fmt.Errorf("Provider of %s cannot list runs", project.Slug)
+	}
+	return lister.WorkflowRuns(project)
+}
@@ -0,0 +1,92 @@
+package dashboard_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+var _ = Describe("Sources", func() {
+	var (
+		project  = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example", Branches: map[string]interface{}{"master": nil}}
+		pipeline = circleci.Pipeline{ID: "1", VCS: circleci.VCS{Branch: "master"}}
+		working  *mocks.CircleCI
+		failing  *mocks.CircleCI
+	)
+
+	BeforeEach(func() {
+		working = &mocks.CircleCI{}
+		working.On("GetAllProjects").Return(circleci.Projects{project}, nil)
+		working.On("GetAllPipelines", project).Return(circleci.Pipelines{pipeline}, nil)
+		working.On("GetWorkflowsForPipeline", pipeline).Return(circleci.Workflows{{ID: "1", Name: "build"}}, nil)
+		working.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+		working.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+		failing = &mocks.CircleCI{}
+		failing.On("GetAllProjects").Return(nil, fmt.Errorf("Unauthorized"))
+	})
+
+	Describe("#BuildSources", func() {
+		It("merges the monitors of every source, recording where they came from", func() {
+			monitors, err := dashboard.BuildSources(context.Background(), []dashboard.Source{
+				{Name: "github", Provider: dashboard.NewCircleCIProvider(working, &circleci.Filter{})},
+				{Name: "bitbucket", Provider: dashboard.NewCircleCIProvider(working, &circleci.Filter{})},
+			}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(2))
+			Ω(monitors[0].Source).Should(Equal("bitbucket"))
+			Ω(monitors[1].Source).Should(Equal("github"))
+			Ω(monitors[0].ID()).ShouldNot(Equal(monitors[1].ID()))
+		})
+
+		It("keeps the monitors of working sources when one fails", func() {
+			monitors, err := dashboard.BuildSources(context.Background(), []dashboard.Source{
+				{Name: "github", Provider: dashboard.NewCircleCIProvider(working, &circleci.Filter{})},
+				{Name: "server", Provider: dashboard.NewCircleCIProvider(failing, &circleci.Filter{})},
+			}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(MatchError("server: Unauthorized"))
+			Ω(monitors).Should(HaveLen(1))
+		})
+	})
+
+	Describe("#SelectProjects", func() {
+		var other = circleci.Project{VCSType: "github", Username: "other", Reponame: "example"}
+
+		It("replaces the filter of a CircleCI source, so other projects are never built", func() {
+			working.ExpectedCalls = nil
+			working.On("GetAllProjects").Return(circleci.Projects{project, other}, nil)
+			working.On("GetAllPipelines", project).Return(circleci.Pipelines{pipeline}, nil)
+			working.On("GetWorkflowsForPipeline", pipeline).Return(circleci.Workflows{{ID: "1", Name: "build"}}, nil)
+			working.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+			working.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+			sources := dashboard.SelectProjects([]dashboard.Source{
+				{Provider: dashboard.NewCircleCIProvider(working, &circleci.Filter{"other/*": nil, "foobar/*": nil})},
+			}, "foobar/*")
+			monitors, err := dashboard.BuildSources(context.Background(), sources, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{HideOrganization: true})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(1))
+			Ω(monitors[0].Name).Should(Equal("example"))
+			working.AssertNotCalled(GinkgoT(), "GetAllPipelines", other)
+		})
+
+		It("lists only the matching projects of other providers", func() {
+			sources := dashboard.SelectProjects([]dashboard.Source{
+				{Provider: fakeProvider{projects: []dashboard.Project{{Name: "foobar/example"}, {Name: "other/example"}}}},
+			}, "foobar/*")
+			projects, err := sources[0].Provider.Projects()
+			Ω(err).Should(BeNil())
+			Ω(projects).Should(Equal([]dashboard.Project{{Name: "foobar/example"}}))
+		})
+
+		It("leaves the sources alone without a pattern", func() {
+			sources := []dashboard.Source{{Provider: fakeProvider{}}}
+			Ω(dashboard.SelectProjects(sources, "")).Should(Equal(sources))
+		})
+	})
+})
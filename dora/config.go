@@ -0,0 +1,91 @@
+package dora
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+const day = 24 * time.Hour
+
+// DefaultWindows are the periods reported on when none are configured.
+var DefaultWindows = []time.Duration{7 * day, 30 * day, 90 * day}
+
+// Config says which workflows are deployments and the periods, ending now,
+// that metrics are computed over.
+type Config struct {
+	// Deployments maps project globs, as in DASHBOARD_FILTER, to globs of
+	// the names of the workflows that deploy them, e.g.
+	// {"myorg/*": ["deploy-production"]}.
+	Deployments map[string][]string
+	Windows     []time.Duration
+}
+
+// ParseDeployments reads the JSON form of Config.Deployments. An empty string
+// means no workflows are deployments.
+func ParseDeployments(deploymentsJSON string) (map[string][]string, error) {
+	if deploymentsJSON == "" {
+		return nil, nil
+	}
+	var deployments map[string][]string
+	if err := json.Unmarshal([]byte(deploymentsJSON), &deployments); err != nil {
+		return nil, err
+	}
+	for projectPattern, workflowPatterns := range deployments {
+		for _, pattern := range append([]string{projectPattern}, workflowPatterns...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return deployments, nil
+}
+
+// ParseWindows reads a comma separated list of days, e.g. "7,30,90".
+func ParseWindows(windows string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, window := range strings.Split(windows, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(window))
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("Invalid window %q, windows are a whole number of days", window)
+		}
+		durations = append(durations, time.Duration(days)*day)
+	}
+	return durations, nil
+}
+
+// workflowPatterns returns the deployment workflow globs of every entry that
+// matches the project.
+func (c Config) workflowPatterns(project circleci.Project) []string {
+	var patterns []string
+	for projectPattern, workflowPatterns := range c.Deployments {
+		if (circleci.Filter{projectPattern: nil}).Matches(project) {
+			patterns = append(patterns, workflowPatterns...)
+		}
+	}
+	return patterns
+}
+
+func (c Config) longestWindow() time.Duration {
+	var longest time.Duration
+	for _, window := range c.Windows {
+		if window > longest {
+			longest = window
+		}
+	}
+	return longest
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
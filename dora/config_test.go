@@ -0,0 +1,51 @@
+package dora_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dora"
+)
+
+var _ = Describe("Config", func() {
+	Describe("#ParseDeployments", func() {
+		It("reads project and workflow globs", func() {
+			deployments, err := dora.ParseDeployments(`{"myorg/*": ["deploy-*"]}`)
+			Ω(err).Should(BeNil())
+			Ω(deployments).Should(Equal(map[string][]string{"myorg/*": {"deploy-*"}}))
+		})
+
+		It("is empty when unset", func() {
+			deployments, err := dora.ParseDeployments("")
+			Ω(err).Should(BeNil())
+			Ω(deployments).Should(BeEmpty())
+		})
+
+		It("rejects invalid JSON", func() {
+			_, err := dora.ParseDeployments(`["deploy"]`)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("rejects invalid globs", func() {
+			_, err := dora.ParseDeployments(`{"myorg/*": ["deploy-["]}`)
+			Ω(err).Should(MatchError(`Invalid pattern "deploy-[": syntax error in pattern`))
+		})
+	})
+
+	Describe("#ParseWindows", func() {
+		It("reads days", func() {
+			windows, err := dora.ParseWindows("7, 30")
+			Ω(err).Should(BeNil())
+			Ω(windows).Should(Equal([]time.Duration{7 * 24 * time.Hour, 30 * 24 * time.Hour}))
+		})
+
+		It("rejects anything that is not a whole number of days", func() {
+			_, err := dora.ParseWindows("7,1w")
+			Ω(err).Should(MatchError(`Invalid window "1w", windows are a whole number of days`))
+			_, err = dora.ParseWindows("0")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,362 @@
+package dora
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+// Deployment is a finished run of a deployment workflow.
+type Deployment struct {
+	Project   string `json:"project"`
+	Workflow  string `json:"workflow"`
+	Branch    string `json:"branch,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Revision  string `json:"revision"`
+	Succeeded bool   `json:"succeeded"`
+	// CommittedAt is when the revision was first seen by CircleCI, which is
+	// the closest the API gets to its commit time.
+	CommittedAt time.Time `json:"committed_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// LeadTime is how long the change took to go from commit to deployed.
+func (d Deployment) LeadTime() time.Duration {
+	return d.FinishedAt.Sub(d.CommittedAt)
+}
+
+// stream identifies what a deployment deploys to, so that a failure can be
+// matched with the deployment that restored it.
+func (d Deployment) stream() string {
+	if d.Tag != "" {
+		return fmt.Sprintf("%s\x00%s\x00tag", d.Project, d.Workflow)
+	}
+	return fmt.Sprintf("%s\x00%s\x00%s", d.Project, d.Workflow, d.Branch)
+}
+
+type Deployments []Deployment
+
+// Collect finds the deployments of each project the filter selects that
+// finished after since. It reads every pipeline the projects have, so it is
+// slow on busy projects.
+func Collect(circleCIClient circleci.CircleCI, filter *circleci.Filter, config Config, since time.Time) (Deployments, error) {
+	projects, err := circleCIClient.GetAllProjects()
+	if err != nil {
+		return nil, err
+	}
+	var deployments Deployments
+	for _, project := range projects.Filter(filter) {
+		patterns := config.workflowPatterns(project)
+		if len(patterns) == 0 {
+			continue
+		}
+		projectDeployments, err := collectProject(circleCIClient, project, patterns, since)
+		if err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, projectDeployments...)
+	}
+	sort.SliceStable(deployments, func(i, j int) bool {
+		return deployments[i].FinishedAt.Before(deployments[j].FinishedAt)
+	})
+	return deployments, nil
+}
+
+func collectProject(circleCIClient circleci.CircleCI, project circleci.Project, patterns []string, since time.Time) (Deployments, error) {
+	branchPipelines, err := circleCIClient.GetAllPipelines(project)
+	if err != nil {
+		return nil, err
+	}
+	tagPipelines, err := circleCIClient.GetTagPipelines(project)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pipelines   circleci.Pipelines
+		seen        = map[string]bool{}
+		committedAt = map[string]time.Time{}
+	)
+	for _, pipeline := range append(branchPipelines, tagPipelines...) {
+		if seen[pipeline.ID] {
+			continue
+		}
+		seen[pipeline.ID] = true
+		pipelines = append(pipelines, pipeline)
+		revision := pipeline.VCS.Revision
+		if first, ok := committedAt[revision]; !ok || pipeline.CreatedAt.Before(first) {
+			committedAt[revision] = pipeline.CreatedAt
+		}
+	}
+	var deployments Deployments
+	for _, pipeline := range pipelines {
+		if pipeline.CreatedAt.Before(since) || pipeline.ConfigError() {
+			continue
+		}
+		workflows, err := circleCIClient.GetWorkflowsForPipeline(pipeline)
+		if err != nil {
+			return nil, err
+		}
+		for _, workflow := range workflows {
+			if workflow.StoppedAt == nil || !matchesAny(patterns, workflow.Name) {
+				continue
+			}
+			if workflow.Status != "success" && workflow.Status != "failed" {
+				continue
+			}
+			deployments = append(deployments, Deployment{
+				Project:     project.Name(),
+				Workflow:    workflow.Name,
+				Branch:      pipeline.VCS.Branch,
+				Tag:         pipeline.VCS.Tag,
+				Revision:    pipeline.VCS.Revision,
+				Succeeded:   workflow.Status == "success",
+				CommittedAt: committedAt[pipeline.VCS.Revision],
+				FinishedAt:  *workflow.StoppedAt,
+			})
+		}
+	}
+	return deployments, nil
+}
+
+// Metrics are the four DORA metrics over a window ending at the time the
+// report was made. Lead time and time to restore are medians, and are 0 when
+// there was nothing to measure.
+type Metrics struct {
+	Days                 int     `json:"days"`
+	Successful           int     `json:"successful"`
+	Failed               int     `json:"failed"`
+	DeploysPerDay        float64 `json:"deploys_per_day"`
+	ChangeFailureRate    float64 `json:"change_failure_rate"`
+	LeadTimeSeconds      int64   `json:"lead_time_seconds"`
+	TimeToRestoreSeconds int64   `json:"time_to_restore_seconds"`
+}
+
+// LeadTime renders the median lead time, e.g. "1d 4h".
+func (m Metrics) LeadTime() string {
+	return formatSeconds(m.LeadTimeSeconds)
+}
+
+// TimeToRestore renders the median time to restore, e.g. "45m".
+func (m Metrics) TimeToRestore() string {
+	return formatSeconds(m.TimeToRestoreSeconds)
+}
+
+// ChangeFailurePercent is the change failure rate as a whole percentage.
+func (m Metrics) ChangeFailurePercent() int {
+	return int(m.ChangeFailureRate*100 + 0.5)
+}
+
+// Metrics computes the metrics for deployments that finished in the window
+// ending at now. The deployments must be in the order they finished.
+func (d Deployments) Metrics(window time.Duration, now time.Time) Metrics {
+	start := now.Add(-window)
+	metrics := Metrics{Days: int(window / day)}
+	var leadTimes, restoreTimes []time.Duration
+	brokenAt := map[string]time.Time{}
+	for _, deployment := range d {
+		inWindow := !deployment.FinishedAt.Before(start) && !deployment.FinishedAt.After(now)
+		stream := deployment.stream()
+		if !deployment.Succeeded {
+			if inWindow {
+				metrics.Failed++
+			}
+			if _, broken := brokenAt[stream]; !broken {
+				brokenAt[stream] = deployment.FinishedAt
+			}
+			continue
+		}
+		if broke, broken := brokenAt[stream]; broken {
+			delete(brokenAt, stream)
+			if !broke.Before(start) && inWindow {
+				restoreTimes = append(restoreTimes, deployment.FinishedAt.Sub(broke))
+			}
+		}
+		if inWindow {
+			metrics.Successful++
+			if !deployment.CommittedAt.IsZero() {
+				leadTimes = append(leadTimes, deployment.LeadTime())
+			}
+		}
+	}
+	if days := window.Hours() / 24; days > 0 {
+		metrics.DeploysPerDay = float64(metrics.Successful) / days
+	}
+	if total := metrics.Successful + metrics.Failed; total > 0 {
+		metrics.ChangeFailureRate = float64(metrics.Failed) / float64(total)
+	}
+	metrics.LeadTimeSeconds = int64(median(leadTimes).Seconds())
+	metrics.TimeToRestoreSeconds = int64(median(restoreTimes).Seconds())
+	return metrics
+}
+
+// ProjectMetrics are the metrics of a single project, a window at a time.
+type ProjectMetrics struct {
+	Name    string    `json:"name"`
+	Metrics []Metrics `json:"metrics"`
+}
+
+type Report struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Overall     []Metrics        `json:"overall"`
+	Projects    []ProjectMetrics `json:"projects"`
+	Deployments Deployments      `json:"deployments"`
+}
+
+// NewReport computes the metrics for every window, across all projects and
+// per project.
+func NewReport(deployments Deployments, windows []time.Duration, now time.Time) Report {
+	report := Report{GeneratedAt: now, Deployments: deployments}
+	perProject := map[string]Deployments{}
+	var names []string
+	for _, deployment := range deployments {
+		if _, ok := perProject[deployment.Project]; !ok {
+			names = append(names, deployment.Project)
+		}
+		perProject[deployment.Project] = append(perProject[deployment.Project], deployment)
+	}
+	sort.Strings(names)
+	for _, window := range windows {
+		report.Overall = append(report.Overall, deployments.Metrics(window, now))
+	}
+	for _, name := range names {
+		projectMetrics := ProjectMetrics{Name: name}
+		for _, window := range windows {
+			projectMetrics.Metrics = append(projectMetrics.Metrics, perProject[name].Metrics(window, now))
+		}
+		report.Projects = append(report.Projects, projectMetrics)
+	}
+	return report
+}
+
+// Source is a CircleCI source to collect deployments from.
+type Source struct {
+	CircleCIClient circleci.CircleCI
+	Filter         *circleci.Filter
+}
+
+// Reporter makes reports on demand, reusing the last one until it is MaxAge
+// old, as collecting months of deployments takes a lot of API calls. Once a
+// report is too old it is collected again in the background while the old
+// one is still served, so only the very first report is waited for.
+type Reporter struct {
+	Sources []Source
+	Config  Config
+	MaxAge  time.Duration
+	Now     func() time.Time
+
+	mu         sync.Mutex
+	report     *Report
+	err        error
+	refreshing chan struct{}
+}
+
+func NewReporter(sources []Source, config Config, maxAge time.Duration) *Reporter {
+	return &Reporter{
+		Sources: sources,
+		Config:  config,
+		MaxAge:  maxAge,
+		Now:     time.Now,
+	}
+}
+
+// Configured reports whether any workflows are marked as deployments, and
+// there is a CircleCI source to find them in.
+func (r *Reporter) Configured() bool {
+	return len(r.Config.Deployments) > 0 && len(r.Sources) > 0
+}
+
+// Report returns the last report, starting to collect a new one if it is too
+// old. Until the first report has been collected it waits for it.
+func (r *Reporter) Report() (Report, error) {
+	if len(r.Sources) == 0 {
+		return Report{}, fmt.Errorf("DORA metrics need a CircleCI source")
+	}
+	r.mu.Lock()
+	now := r.Now()
+	if r.report != nil && now.Sub(r.report.GeneratedAt) < r.MaxAge {
+		defer r.mu.Unlock()
+		return *r.report, nil
+	}
+	if r.refreshing == nil {
+		r.refreshing = make(chan struct{})
+		go r.refresh(now, r.refreshing)
+	}
+	if r.report != nil {
+		defer r.mu.Unlock()
+		return *r.report, nil
+	}
+	done := r.refreshing
+	r.mu.Unlock()
+	<-done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.report == nil {
+		return Report{}, r.err
+	}
+	return *r.report, nil
+}
+
+// refresh collects a report from every source, keeping the last good one if
+// any source fails.
+func (r *Reporter) refresh(now time.Time, done chan struct{}) {
+	report, err := r.collect(now)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		slog.Warn("Error collecting DORA metrics", "err", err)
+		r.err = err
+	} else {
+		r.report = &report
+		r.err = nil
+	}
+	r.refreshing = nil
+	close(done)
+}
+
+func (r *Reporter) collect(now time.Time) (Report, error) {
+	var deployments Deployments
+	for _, source := range r.Sources {
+		sourceDeployments, err := Collect(source.CircleCIClient, source.Filter, r.Config, now.Add(-r.Config.longestWindow()))
+		if err != nil {
+			return Report{}, err
+		}
+		deployments = append(deployments, sourceDeployments...)
+	}
+	sort.SliceStable(deployments, func(i, j int) bool {
+		return deployments[i].FinishedAt.Before(deployments[j].FinishedAt)
+	})
+	return NewReport(deployments, r.Config.Windows, now), nil
+}
+
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func formatSeconds(seconds int64) string {
+	duration := time.Duration(seconds) * time.Second
+	switch {
+	case duration == 0:
+		return "n/a"
+	case duration >= day:
+		return fmt.Sprintf("%dd %dh", duration/day, (duration%day)/time.Hour)
+	case duration >= time.Hour:
+		return fmt.Sprintf("%dh %dm", duration/time.Hour, (duration%time.Hour)/time.Minute)
+	case duration >= time.Minute:
+		return fmt.Sprintf("%dm", duration/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", duration/time.Second)
+	}
+}
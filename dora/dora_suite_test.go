@@ -0,0 +1,13 @@
+package dora_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDora(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Dora Suite")
+}
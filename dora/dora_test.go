@@ -0,0 +1,227 @@
+package dora_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dora"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+var _ = Describe("Dora", func() {
+	var (
+		now = time.Date(2020, 9, 30, 12, 0, 0, 0, time.UTC)
+		day = 24 * time.Hour
+		at  = func(ago time.Duration) time.Time { return now.Add(-ago) }
+	)
+
+	Describe("#Metrics", func() {
+		deployment := func(succeeded bool, committed, finished time.Duration) dora.Deployment {
+			return dora.Deployment{Project: "foobar/example", Workflow: "deploy", Branch: "master", Succeeded: succeeded, CommittedAt: at(committed), FinishedAt: at(finished)}
+		}
+		var deployments = dora.Deployments{
+			deployment(true, 40*day, 39*day),
+			deployment(true, 6*day, 6*day-2*time.Hour),
+			deployment(false, 5*day, 5*day-time.Hour),
+			deployment(false, 4*day, 4*day),
+			deployment(true, 4*day, 4*day-30*time.Minute),
+			deployment(true, 2*day, 2*day-4*time.Hour),
+		}
+
+		It("computes the metrics within the window", func() {
+			metrics := deployments.Metrics(7*day, now)
+			Ω(metrics.Days).Should(Equal(7))
+			Ω(metrics.Successful).Should(Equal(3))
+			Ω(metrics.Failed).Should(Equal(2))
+			Ω(metrics.DeploysPerDay).Should(BeNumerically("~", 3.0/7))
+			Ω(metrics.ChangeFailureRate).Should(BeNumerically("~", 0.4))
+			Ω(metrics.ChangeFailurePercent()).Should(Equal(40))
+			Ω(metrics.LeadTime()).Should(Equal("2h 0m"))
+			Ω(metrics.TimeToRestore()).Should(Equal("23h 30m"))
+		})
+
+		It("includes older deployments in longer windows", func() {
+			metrics := deployments.Metrics(90*day, now)
+			Ω(metrics.Successful).Should(Equal(4))
+			Ω(metrics.LeadTime()).Should(Equal("3h 0m"))
+		})
+
+		It("has nothing to measure without deployments", func() {
+			metrics := dora.Deployments{}.Metrics(7*day, now)
+			Ω(metrics.DeploysPerDay).Should(BeZero())
+			Ω(metrics.ChangeFailureRate).Should(BeZero())
+			Ω(metrics.LeadTime()).Should(Equal("n/a"))
+			Ω(metrics.TimeToRestore()).Should(Equal("n/a"))
+		})
+
+		It("matches failures with the restore on the same branch", func() {
+			metrics := dora.Deployments{
+				{Project: "foobar/example", Workflow: "deploy", Branch: "master", FinishedAt: at(3 * day)},
+				{Project: "foobar/example", Workflow: "deploy", Branch: "develop", Succeeded: true, FinishedAt: at(2 * day)},
+				{Project: "foobar/example", Workflow: "deploy", Branch: "master", Succeeded: true, FinishedAt: at(day)},
+			}.Metrics(7*day, now)
+			Ω(metrics.TimeToRestore()).Should(Equal("2d 0h"))
+		})
+	})
+
+	Describe("#NewReport", func() {
+		It("reports overall and per project", func() {
+			deployments := dora.Deployments{
+				{Project: "foobar/second", Workflow: "deploy", Succeeded: true, FinishedAt: at(day)},
+				{Project: "foobar/first", Workflow: "deploy", Succeeded: true, FinishedAt: at(day)},
+			}
+			report := dora.NewReport(deployments, []time.Duration{7 * day, 30 * day}, now)
+			Ω(report.GeneratedAt).Should(Equal(now))
+			Ω(report.Overall).Should(HaveLen(2))
+			Ω(report.Overall[0].Successful).Should(Equal(2))
+			Ω(report.Projects).Should(HaveLen(2))
+			Ω(report.Projects[0].Name).Should(Equal("foobar/first"))
+			Ω(report.Projects[0].Metrics[1].Days).Should(Equal(30))
+			Ω(report.Projects[0].Metrics[1].Successful).Should(Equal(1))
+		})
+	})
+
+	Describe("#Collect", func() {
+		var (
+			circleCIClient *mocks.CircleCI
+			project        = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example"}
+			other          = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "other"}
+			config         = dora.Config{Deployments: map[string][]string{"foobar/example": {"deploy-*"}}}
+			feature        = circleci.Pipeline{ID: "1", CreatedAt: at(3 * day), VCS: circleci.VCS{Branch: "feature", Revision: "aaa"}}
+			master         = circleci.Pipeline{ID: "2", CreatedAt: at(2 * day), VCS: circleci.VCS{Branch: "master", Revision: "aaa"}}
+			old            = circleci.Pipeline{ID: "3", CreatedAt: at(40 * day), VCS: circleci.VCS{Branch: "master", Revision: "zzz"}}
+			release        = circleci.Pipeline{ID: "4", CreatedAt: at(day), VCS: circleci.VCS{Tag: "v1.0.0", Revision: "aaa"}}
+			finished       = at(2*day - time.Hour)
+			released       = at(day - time.Hour)
+		)
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+		})
+
+		Context("when getting projects errors", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetAllProjects").Return(nil, fmt.Errorf("Error getting projects"))
+			})
+
+			It("returns an error", func() {
+				_, err := dora.Collect(circleCIClient, &circleci.Filter{}, config, at(7*day))
+				Ω(err).Should(MatchError("Error getting projects"))
+			})
+		})
+
+		Context("when the projects have deployments", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetAllProjects").Return(circleci.Projects{project, other}, nil)
+				circleCIClient.On("GetAllPipelines", project).Return(circleci.Pipelines{master, feature, old}, nil)
+				circleCIClient.On("GetTagPipelines", project).Return(circleci.Pipelines{release}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", feature).Return(circleci.Workflows{
+					{Name: "build", Status: "success", StoppedAt: &finished},
+				}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", master).Return(circleci.Workflows{
+					{Name: "build", Status: "success", StoppedAt: &finished},
+					{Name: "deploy-staging", Status: "success", StoppedAt: &finished},
+				}, nil)
+				circleCIClient.On("GetWorkflowsForPipeline", release).Return(circleci.Workflows{
+					{Name: "deploy-production", Status: "failed", StoppedAt: &released},
+					{Name: "deploy-production", Status: "running"},
+				}, nil)
+			})
+
+			It("finds the deployment workflows since the given time, oldest first", func() {
+				deployments, err := dora.Collect(circleCIClient, &circleci.Filter{}, config, at(7*day))
+				Ω(err).Should(BeNil())
+				Ω(deployments).Should(Equal(dora.Deployments{
+					{Project: "foobar/example", Workflow: "deploy-staging", Branch: "master", Revision: "aaa", Succeeded: true, CommittedAt: at(3 * day), FinishedAt: finished},
+					{Project: "foobar/example", Workflow: "deploy-production", Tag: "v1.0.0", Revision: "aaa", Succeeded: false, CommittedAt: at(3 * day), FinishedAt: released},
+				}))
+				circleCIClient.AssertNotCalled(GinkgoT(), "GetWorkflowsForPipeline", old)
+				circleCIClient.AssertNotCalled(GinkgoT(), "GetAllPipelines", other)
+			})
+		})
+	})
+
+	Describe("Reporter", func() {
+		var (
+			circleCIClient *mocks.CircleCI
+			otherClient    *mocks.CircleCI
+			reporter       *dora.Reporter
+			clock          time.Time
+		)
+
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("GetAllProjects").Return(circleci.Projects{}, nil)
+			otherClient = &mocks.CircleCI{}
+			otherClient.On("GetAllProjects").Return(circleci.Projects{}, nil)
+			reporter = dora.NewReporter([]dora.Source{
+				{CircleCIClient: circleCIClient, Filter: &circleci.Filter{}},
+				{CircleCIClient: otherClient, Filter: &circleci.Filter{}},
+			}, dora.Config{
+				Deployments: map[string][]string{"*": {"deploy"}},
+				Windows:     dora.DefaultWindows,
+			}, time.Hour)
+			clock = now
+			reporter.Now = func() time.Time { return clock }
+		})
+
+		It("collects from every source", func() {
+			_, err := reporter.Report()
+			Ω(err).Should(BeNil())
+			circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetAllProjects", 1)
+			otherClient.AssertNumberOfCalls(GinkgoT(), "GetAllProjects", 1)
+		})
+
+		It("reuses a report until it is too old, then serves it while collecting another", func() {
+			first, err := reporter.Report()
+			Ω(err).Should(BeNil())
+			Ω(first.Overall).Should(HaveLen(3))
+			clock = clock.Add(30 * time.Minute)
+			second, err := reporter.Report()
+			Ω(err).Should(BeNil())
+			Ω(second.GeneratedAt).Should(Equal(now))
+			circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetAllProjects", 1)
+			clock = clock.Add(time.Hour)
+			refreshedAt := clock
+			third, err := reporter.Report()
+			Ω(err).Should(BeNil())
+			Ω(third.GeneratedAt).Should(Equal(now))
+			Eventually(func() time.Time {
+				report, _ := reporter.Report()
+				return report.GeneratedAt
+			}).Should(Equal(refreshedAt))
+			circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetAllProjects", 2)
+		})
+
+		It("keeps serving the last good report when collecting fails", func() {
+			_, err := reporter.Report()
+			Ω(err).Should(BeNil())
+			var failures atomic.Int32
+			circleCIClient.ExpectedCalls = nil
+			circleCIClient.On("GetAllProjects").Return(nil, fmt.Errorf("Error getting projects")).Run(func(mock.Arguments) {
+				failures.Add(1)
+			})
+			clock = clock.Add(2 * time.Hour)
+			Eventually(func() int32 {
+				reporter.Report()
+				return failures.Load()
+			}).Should(BeNumerically(">=", 1))
+			report, err := reporter.Report()
+			Ω(err).Should(BeNil())
+			Ω(report.GeneratedAt).Should(Equal(now))
+		})
+
+		It("returns the error when there is no report yet", func() {
+			circleCIClient.ExpectedCalls = nil
+			circleCIClient.On("GetAllProjects").Return(nil, fmt.Errorf("Error getting projects"))
+			_, err := reporter.Report()
+			Ω(err).Should(MatchError("Error getting projects"))
+		})
+	})
+})
@@ -0,0 +1,204 @@
+package envvars
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+// ValueSource says where the value of a variable comes from. Exactly one of
+// the fields must be set.
+type ValueSource struct {
+	Env    string `yaml:"env"`
+	File   string `yaml:"file"`
+	Secret string `yaml:"secret"`
+}
+
+// ProjectSpec declares the variables that every project whose
+// username/reponame matches Match must have. Prune deletes any others.
+type ProjectSpec struct {
+	Match string                 `yaml:"match"`
+	Prune bool                   `yaml:"prune"`
+	Vars  map[string]ValueSource `yaml:"vars"`
+}
+
+// DesiredState is the contents of an envvars file, e.g.
+//
+//	secrets_file: secrets.yaml
+//	projects:
+//	- match: myorg/*
+//	  prune: true
+//	  vars:
+//	    DEPLOY_KEY: {env: DEPLOY_KEY}
+//	    TLS_CERT: {file: certs/tls.pem}
+//	    API_TOKEN: {secret: api_token}
+type DesiredState struct {
+	SecretsFile string        `yaml:"secrets_file"`
+	Projects    []ProjectSpec `yaml:"projects"`
+
+	dir     string
+	secrets map[string]string
+}
+
+// LoadDesiredState reads an envvars file. Relative file paths in it are
+// resolved against the directory the file is in.
+func LoadDesiredState(filename string) (*DesiredState, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var desired DesiredState
+	if err := yaml.UnmarshalStrict(data, &desired); err != nil {
+		return nil, fmt.Errorf("Error loading %s: %v", filename, err)
+	}
+	desired.dir = filepath.Dir(filename)
+	for _, spec := range desired.Projects {
+		if spec.Match == "" {
+			return nil, fmt.Errorf("Error loading %s: every project needs a match", filename)
+		}
+		if _, err := path.Match(spec.Match, ""); err != nil {
+			return nil, fmt.Errorf("Error loading %s: invalid match %q", filename, spec.Match)
+		}
+		for name, source := range spec.Vars {
+			if countSet(source.Env, source.File, source.Secret) != 1 {
+				return nil, fmt.Errorf("Error loading %s: %s in %s needs exactly one of env, file or secret", filename, name, spec.Match)
+			}
+		}
+	}
+	if desired.SecretsFile != "" {
+		data, err := ioutil.ReadFile(desired.resolvePath(desired.SecretsFile))
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(data, &desired.secrets); err != nil {
+			return nil, fmt.Errorf("Error loading %s: %v", desired.SecretsFile, err)
+		}
+	}
+	return &desired, nil
+}
+
+func countSet(values ...string) int {
+	var count int
+	for _, value := range values {
+		if value != "" {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *DesiredState) resolvePath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(d.dir, filename)
+}
+
+// Filter selects every project that any spec matches.
+func (d *DesiredState) Filter() *circleci.Filter {
+	filter := circleci.Filter{}
+	for _, spec := range d.Projects {
+		filter[spec.Match] = nil
+	}
+	return &filter
+}
+
+func (d *DesiredState) value(source ValueSource, lookupEnv func(string) (string, bool)) (string, error) {
+	switch {
+	case source.Env != "":
+		value, ok := lookupEnv(source.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", source.Env)
+		}
+		return value, nil
+	case source.File != "":
+		value, err := ioutil.ReadFile(d.resolvePath(source.File))
+		return string(value), err
+	}
+	value, ok := d.secrets[source.Secret]
+	if !ok {
+		return "", fmt.Errorf("secret %s is not in the secrets file", source.Secret)
+	}
+	return value, nil
+}
+
+// Resolve returns the variables a project should have, with their values, and
+// whether others should be pruned. Later specs override earlier ones.
+func (d *DesiredState) Resolve(project circleci.Project, lookupEnv func(string) (string, bool)) (map[string]string, bool, error) {
+	values := map[string]string{}
+	var prune bool
+	for _, spec := range d.Projects {
+		if !(circleci.Filter{spec.Match: nil}).Matches(project) {
+			continue
+		}
+		prune = prune || spec.Prune
+		for name, source := range spec.Vars {
+			value, err := d.value(source, lookupEnv)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s: %s: %v", project.Name(), name, err)
+			}
+			values[name] = value
+		}
+	}
+	return values, prune, nil
+}
+
+// maskedLength is how many of a value's last characters CircleCI's masked
+// copy keeps.
+const maskedLength = 4
+
+// maskedMatches compares a value with CircleCI's masked copy, which only
+// keeps the last four characters. Values that end the same are assumed equal.
+// Shorter values cannot be checked, see ActionUnverified.
+func maskedMatches(masked, value string) bool {
+	return strings.HasSuffix(masked, value[len(value)-maskedLength:])
+}
+
+// PlanApply works out the changes needed to bring each project in line with
+// the desired state: create missing variables, update those whose masked
+// value no longer matches, and delete undeclared ones where pruning. Values
+// too short to compare are set as unverified changes, which do not count as
+// drift.
+func (i Inventory) PlanApply(desired *DesiredState, projects circleci.Projects, lookupEnv func(string) (string, bool)) (Plan, error) {
+	sort.Slice(projects, func(a, b int) bool { return projects[a].Slug() < projects[b].Slug() })
+	var plan Plan
+	for _, project := range projects {
+		values, prune, err := desired.Resolve(project, lookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		slug := project.Slug()
+		var names []string
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			current, ok := i.Get(slug, name)
+			switch {
+			case !ok:
+				plan = append(plan, NewChange(slug, name, ActionCreate, values[name]))
+			case len(values[name]) < maskedLength:
+				plan = append(plan, NewChange(slug, name, ActionUnverified, values[name]))
+			case !maskedMatches(current.Value, values[name]):
+				plan = append(plan, NewChange(slug, name, ActionUpdate, values[name]))
+			}
+		}
+		if !prune {
+			continue
+		}
+		for _, current := range i[slug] {
+			if _, ok := values[current.Name]; !ok {
+				plan = append(plan, NewChange(slug, current.Name, ActionDelete, ""))
+			}
+		}
+	}
+	return plan, nil
+}
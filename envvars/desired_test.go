@@ -0,0 +1,144 @@
+package envvars_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/envvars"
+)
+
+var _ = Describe("DesiredState", func() {
+	var (
+		dir      string
+		projects circleci.Projects
+		env      = map[string]string{"DEPLOY_KEY": "new-key-9999"}
+	)
+
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	writeFile := func(name, contents string) string {
+		filename := filepath.Join(dir, name)
+		Ω(ioutil.WriteFile(filename, []byte(contents), 0600)).Should(Succeed())
+		return filename
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "envvars")
+		Ω(err).Should(BeNil())
+		projects = circleci.Projects{
+			{VCSType: "github", Username: "foobar", Reponame: "example"},
+			{VCSType: "github", Username: "foobar", Reponame: "another"},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("LoadDesiredState", func() {
+		It("errors when a variable has no source", func() {
+			filename := writeFile("envvars.yaml", "projects:\n- match: foobar/*\n  vars:\n    DEPLOY_KEY: {}\n")
+			_, err := envvars.LoadDesiredState(filename)
+			Ω(err).Should(MatchError(ContainSubstring("DEPLOY_KEY in foobar/* needs exactly one of env, file or secret")))
+		})
+
+		It("errors on unknown keys", func() {
+			filename := writeFile("envvars.yaml", "projects:\n- match: foobar/*\n  prnue: true\n")
+			_, err := envvars.LoadDesiredState(filename)
+			Ω(err).Should(MatchError(ContainSubstring("prnue")))
+		})
+	})
+
+	Describe("#PlanApply", func() {
+		var inventory envvars.Inventory
+
+		BeforeEach(func() {
+			writeFile("secrets.yaml", "api_token: token-abcd\n")
+			writeFile("tls.pem", "certificate")
+			inventory = envvars.Inventory{
+				"github/foobar/example": {
+					{Name: "DEPLOY_KEY", Value: "xxxx1234"},
+					{Name: "API_TOKEN", Value: "xxxxabcd"},
+					{Name: "STALE", Value: "xxxxffff"},
+				},
+				"github/foobar/another": {
+					{Name: "STALE", Value: "xxxxffff"},
+				},
+			}
+		})
+
+		plan := func(contents string) (envvars.Plan, error) {
+			desired, err := envvars.LoadDesiredState(writeFile("envvars.yaml", contents))
+			Ω(err).Should(BeNil())
+			return inventory.PlanApply(desired, projects, lookupEnv)
+		}
+
+		It("creates missing variables, updates changed ones and prunes undeclared ones", func() {
+			applyPlan, err := plan(`
+secrets_file: secrets.yaml
+projects:
+- match: foobar/*
+  vars:
+    DEPLOY_KEY: {env: DEPLOY_KEY}
+    API_TOKEN: {secret: api_token}
+- match: foobar/another
+  prune: true
+  vars:
+    TLS_CERT: {file: tls.pem}
+`)
+			Ω(err).Should(BeNil())
+			var changes []string
+			for _, change := range applyPlan {
+				changes = append(changes, change.String())
+			}
+			Ω(changes).Should(Equal([]string{
+				"+ github/foobar/another API_TOKEN",
+				"+ github/foobar/another DEPLOY_KEY",
+				"+ github/foobar/another TLS_CERT",
+				"- github/foobar/another STALE",
+				"~ github/foobar/example DEPLOY_KEY",
+			}))
+		})
+
+		It("plans nothing when every project matches", func() {
+			inventory["github/foobar/another"] = inventory["github/foobar/example"]
+			env["DEPLOY_KEY"] = "old-key-1234"
+			defer func() { env["DEPLOY_KEY"] = "new-key-9999" }()
+			applyPlan, err := plan(`
+secrets_file: secrets.yaml
+projects:
+- match: foobar/*
+  vars:
+    DEPLOY_KEY: {env: DEPLOY_KEY}
+    API_TOKEN: {secret: api_token}
+`)
+			Ω(err).Should(BeNil())
+			Ω(applyPlan).Should(BeEmpty())
+		})
+
+		It("sets values too short to compare with their masked copy as unverified, not drifted", func() {
+			inventory["github/foobar/example"] = append(inventory["github/foobar/example"], circleci.ProjectEnvVar{Name: "REGION", Value: "xxxxeu"})
+			env["REGION"] = "eu"
+			defer delete(env, "REGION")
+			applyPlan, err := plan("projects:\n- match: foobar/example\n  vars:\n    REGION: {env: REGION}\n")
+			Ω(err).Should(BeNil())
+			Ω(applyPlan).Should(HaveLen(1))
+			Ω(applyPlan[0].String()).Should(Equal("? github/foobar/example REGION"))
+			Ω(applyPlan.Drifted()).Should(BeFalse())
+		})
+
+		It("errors when a value cannot be found", func() {
+			_, err := plan("projects:\n- match: foobar/example\n  vars:\n    MISSING: {env: NOT_SET}\n")
+			Ω(err).Should(MatchError("foobar/example: MISSING: environment variable NOT_SET is not set"))
+		})
+	})
+})
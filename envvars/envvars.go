@@ -0,0 +1,272 @@
+// Package envvars manages CircleCI project environment variables across many
+// projects at once. Values are only ever sent to CircleCI, never printed.
+package envvars
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionUnverified sets a value that cannot be compared with CircleCI's
+	// masked copy, so it may or may not have drifted.
+	ActionUnverified = "unverified"
+)
+
+var actionSymbols = map[string]string{
+	ActionCreate:     "+",
+	ActionUpdate:     "~",
+	ActionDelete:     "-",
+	ActionUnverified: "?",
+}
+
+// Inventory holds the environment variables of each project, keyed by
+// project slug. CircleCI only ever returns masked values.
+type Inventory map[string]circleci.ProjectEnvVars
+
+// Load fetches the environment variables of every project.
+func Load(client circleci.CircleCI, projects circleci.Projects) (Inventory, error) {
+	inventory := Inventory{}
+	for _, project := range projects {
+		envVars, err := client.GetProjectEnvVars(project.Slug())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", project.Slug(), err)
+		}
+		inventory[project.Slug()] = envVars
+	}
+	return inventory, nil
+}
+
+func (i Inventory) Slugs() []string {
+	var slugs []string
+	for slug := range i {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+// Names returns every variable name found in any project.
+func (i Inventory) Names() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, envVars := range i {
+		for _, envVar := range envVars {
+			if !seen[envVar.Name] {
+				seen[envVar.Name] = true
+				names = append(names, envVar.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (i Inventory) Get(slug, name string) (circleci.ProjectEnvVar, bool) {
+	for _, envVar := range i[slug] {
+		if envVar.Name == name {
+			return envVar, true
+		}
+	}
+	return circleci.ProjectEnvVar{}, false
+}
+
+// List writes the variable names of every project.
+func (i Inventory) List(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "PROJECT\tNAME")
+	for _, slug := range i.Slugs() {
+		var names []string
+		for _, envVar := range i[slug] {
+			names = append(names, envVar.Name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(table, "%s\t%s\n", slug, name)
+		}
+	}
+	return table.Flush()
+}
+
+// Diff writes, for each variable, which projects are missing it and whether
+// the masked values differ between the projects that have it. It returns
+// true if any variable is missing or differs.
+func (i Inventory) Diff(w io.Writer) (bool, error) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tPRESENT\tVALUES\tMISSING FROM")
+	slugs := i.Slugs()
+	var drifted bool
+	for _, name := range i.Names() {
+		var missing []string
+		maskedValues := map[string]bool{}
+		for _, slug := range slugs {
+			envVar, ok := i.Get(slug, name)
+			if !ok {
+				missing = append(missing, slug)
+				continue
+			}
+			maskedValues[envVar.Value] = true
+		}
+		values := "same"
+		if len(maskedValues) > 1 {
+			values = "differ"
+			drifted = true
+		}
+		if len(missing) > 0 {
+			drifted = true
+		}
+		fmt.Fprintf(table, "%s\t%d/%d\t%s\t%s\n", name, len(slugs)-len(missing), len(slugs), values, strings.Join(missing, ", "))
+	}
+	return drifted, table.Flush()
+}
+
+// Change is a single step of a Plan. The value is unexported so it cannot be
+// printed by accident.
+type Change struct {
+	Project string
+	Name    string
+	Action  string
+	value   string
+}
+
+func NewChange(project, name, action, value string) Change {
+	return Change{Project: project, Name: name, Action: action, value: value}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s %s", actionSymbols[c.Action], c.Project, c.Name)
+}
+
+type Plan []Change
+
+func (p Plan) Count(action string) int {
+	var count int
+	for _, change := range p {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Print writes each change and a summary.
+func (p Plan) Print(w io.Writer) {
+	for _, change := range p {
+		fmt.Fprintln(w, change)
+	}
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete", p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionDelete))
+	if unverified := p.Count(ActionUnverified); unverified > 0 {
+		fmt.Fprintf(w, ", %d to set that cannot be verified", unverified)
+	}
+	fmt.Fprintln(w)
+}
+
+// Drifted reports whether the plan has any change that is known to be
+// needed, leaving out the values that cannot be verified.
+func (p Plan) Drifted() bool {
+	return len(p) > p.Count(ActionUnverified)
+}
+
+// Apply makes each change in order, stopping at the first failure.
+func (p Plan) Apply(client circleci.CircleCI, w io.Writer) error {
+	for _, change := range p {
+		var err error
+		switch change.Action {
+		case ActionCreate, ActionUpdate, ActionUnverified:
+			_, err = client.CreateProjectEnvVar(change.Project, change.Name, change.value)
+		case ActionDelete:
+			err = client.DeleteProjectEnvVar(change.Project, change.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", change, err)
+		}
+		fmt.Fprintf(w, "%s done\n", change)
+	}
+	return nil
+}
+
+// PlanSet creates the variable where it is missing and updates it everywhere
+// else.
+func (i Inventory) PlanSet(name, value string) Plan {
+	var plan Plan
+	for _, slug := range i.Slugs() {
+		action := ActionCreate
+		if _, ok := i.Get(slug, name); ok {
+			action = ActionUpdate
+		}
+		plan = append(plan, NewChange(slug, name, action, value))
+	}
+	return plan
+}
+
+// PlanRotate updates the variable only in the projects that already have it.
+func (i Inventory) PlanRotate(name, value string) (Plan, error) {
+	var plan Plan
+	for _, slug := range i.Slugs() {
+		if _, ok := i.Get(slug, name); ok {
+			plan = append(plan, NewChange(slug, name, ActionUpdate, value))
+		}
+	}
+	if len(plan) == 0 {
+		return nil, fmt.Errorf("No selected project has %s", name)
+	}
+	return plan, nil
+}
+
+// PlanUnset deletes the variable from the projects that have it.
+func (i Inventory) PlanUnset(name string) Plan {
+	var plan Plan
+	for _, slug := range i.Slugs() {
+		if _, ok := i.Get(slug, name); ok {
+			plan = append(plan, NewChange(slug, name, ActionDelete, ""))
+		}
+	}
+	return plan
+}
+
+// PlanCopy sets the source project's variables, or just the given names, in
+// every project in the inventory. CircleCI never returns real values, so
+// each value comes from lookup, which is typically the local environment.
+func (i Inventory) PlanCopy(source circleci.ProjectEnvVars, names []string, lookup func(string) (string, bool)) (Plan, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var (
+		plan    Plan
+		missing []string
+	)
+	for _, envVar := range source {
+		if len(names) > 0 && !wanted[envVar.Name] {
+			continue
+		}
+		delete(wanted, envVar.Name)
+		value, ok := lookup(envVar.Name)
+		if !ok {
+			missing = append(missing, envVar.Name)
+			continue
+		}
+		plan = append(plan, i.PlanSet(envVar.Name, value)...)
+	}
+	if len(wanted) > 0 {
+		var unknown []string
+		for name := range wanted {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("The source project does not have: %s", strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("Values must be provided in the local environment for: %s", strings.Join(missing, ", "))
+	}
+	return plan, nil
+}
@@ -0,0 +1,224 @@
+package envvars_test
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/envvars"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+)
+
+var _ = Describe("EnvVars", func() {
+	var (
+		circleCIClient *mocks.CircleCI
+		inventory      envvars.Inventory
+		out            *bytes.Buffer
+		projects       = circleci.Projects{
+			{VCSType: "github", Username: "foobar", Reponame: "example"},
+			{VCSType: "github", Username: "foobar", Reponame: "another"},
+		}
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		circleCIClient = &mocks.CircleCI{}
+		inventory = envvars.Inventory{
+			"github/foobar/example": {
+				{Name: "DEPLOY_KEY", Value: "xxxx1234"},
+				{Name: "ONLY_HERE", Value: "xxxxabcd"},
+			},
+			"github/foobar/another": {
+				{Name: "DEPLOY_KEY", Value: "xxxx5678"},
+			},
+		}
+	})
+
+	Describe("#Load", func() {
+		Context("when getting env vars errors", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetProjectEnvVars", "github/foobar/example").Return(nil, fmt.Errorf("Error getting env vars"))
+			})
+
+			It("returns an error naming the project", func() {
+				_, err := envvars.Load(circleCIClient, projects)
+				Ω(err).Should(MatchError("github/foobar/example: Error getting env vars"))
+			})
+		})
+
+		Context("when getting env vars is successful", func() {
+			BeforeEach(func() {
+				circleCIClient.On("GetProjectEnvVars", "github/foobar/example").Return(inventory["github/foobar/example"], nil)
+				circleCIClient.On("GetProjectEnvVars", "github/foobar/another").Return(inventory["github/foobar/another"], nil)
+			})
+
+			It("returns the env vars of every project", func() {
+				loaded, err := envvars.Load(circleCIClient, projects)
+				Ω(err).Should(BeNil())
+				Ω(loaded).Should(Equal(inventory))
+			})
+		})
+	})
+
+	Describe("#List", func() {
+		It("lists names without values", func() {
+			Ω(inventory.List(out)).Should(Succeed())
+			Ω(out.String()).Should(Equal(`PROJECT                NAME
+github/foobar/another  DEPLOY_KEY
+github/foobar/example  DEPLOY_KEY
+github/foobar/example  ONLY_HERE
+`))
+		})
+	})
+
+	Describe("#Diff", func() {
+		It("reports missing and differing variables without values", func() {
+			drifted, err := inventory.Diff(out)
+			Ω(err).Should(BeNil())
+			Ω(drifted).Should(BeTrue())
+			Ω(out.String()).Should(Equal(`NAME        PRESENT  VALUES  MISSING FROM
+DEPLOY_KEY  2/2      differ  
+ONLY_HERE   1/2      same    github/foobar/another
+`))
+			Ω(out.String()).ShouldNot(ContainSubstring("1234"))
+		})
+
+		It("reports no drift when projects agree", func() {
+			drifted, err := envvars.Inventory{
+				"github/foobar/example": {{Name: "DEPLOY_KEY", Value: "xxxx1234"}},
+				"github/foobar/another": {{Name: "DEPLOY_KEY", Value: "xxxx1234"}},
+			}.Diff(out)
+			Ω(err).Should(BeNil())
+			Ω(drifted).Should(BeFalse())
+		})
+	})
+
+	Describe("#PlanSet", func() {
+		It("creates missing variables and updates existing ones", func() {
+			Ω(inventory.PlanSet("ONLY_HERE", "secret")).Should(Equal(envvars.Plan{
+				envvars.NewChange("github/foobar/another", "ONLY_HERE", envvars.ActionCreate, "secret"),
+				envvars.NewChange("github/foobar/example", "ONLY_HERE", envvars.ActionUpdate, "secret"),
+			}))
+		})
+	})
+
+	Describe("#PlanRotate", func() {
+		It("only updates projects that have the variable", func() {
+			plan, err := inventory.PlanRotate("ONLY_HERE", "secret")
+			Ω(err).Should(BeNil())
+			Ω(plan).Should(Equal(envvars.Plan{
+				envvars.NewChange("github/foobar/example", "ONLY_HERE", envvars.ActionUpdate, "secret"),
+			}))
+		})
+
+		It("errors when no project has the variable", func() {
+			_, err := inventory.PlanRotate("MISSING", "secret")
+			Ω(err).Should(MatchError("No selected project has MISSING"))
+		})
+	})
+
+	Describe("#PlanUnset", func() {
+		It("deletes the variable where it exists", func() {
+			Ω(inventory.PlanUnset("ONLY_HERE")).Should(Equal(envvars.Plan{
+				envvars.NewChange("github/foobar/example", "ONLY_HERE", envvars.ActionDelete, ""),
+			}))
+		})
+	})
+
+	Describe("#PlanCopy", func() {
+		var (
+			source = circleci.ProjectEnvVars{
+				{Name: "DEPLOY_KEY", Value: "xxxx1234"},
+				{Name: "NEW_KEY", Value: "xxxx9999"},
+			}
+			local = map[string]string{"DEPLOY_KEY": "deploy", "NEW_KEY": "new"}
+		)
+
+		lookup := func(name string) (string, bool) {
+			value, ok := local[name]
+			return value, ok
+		}
+
+		It("sets every source variable from the local values", func() {
+			plan, err := inventory.PlanCopy(source, nil, lookup)
+			Ω(err).Should(BeNil())
+			Ω(plan).Should(HaveLen(4))
+			Ω(plan.Count(envvars.ActionCreate)).Should(Equal(2))
+			Ω(plan.Count(envvars.ActionUpdate)).Should(Equal(2))
+		})
+
+		It("only copies the named variables", func() {
+			plan, err := inventory.PlanCopy(source, []string{"NEW_KEY"}, lookup)
+			Ω(err).Should(BeNil())
+			Ω(plan).Should(Equal(envvars.Plan{
+				envvars.NewChange("github/foobar/another", "NEW_KEY", envvars.ActionCreate, "new"),
+				envvars.NewChange("github/foobar/example", "NEW_KEY", envvars.ActionCreate, "new"),
+			}))
+		})
+
+		It("errors when the source does not have a named variable", func() {
+			_, err := inventory.PlanCopy(source, []string{"NEW_KEY", "NOPE"}, lookup)
+			Ω(err).Should(MatchError("The source project does not have: NOPE"))
+		})
+
+		It("errors when a local value is missing", func() {
+			_, err := inventory.PlanCopy(source, nil, func(string) (string, bool) { return "", false })
+			Ω(err).Should(MatchError("Values must be provided in the local environment for: DEPLOY_KEY, NEW_KEY"))
+		})
+	})
+
+	Describe("Plan", func() {
+		var plan = envvars.Plan{
+			envvars.NewChange("github/foobar/example", "NEW_KEY", envvars.ActionCreate, "top-secret"),
+			envvars.NewChange("github/foobar/example", "OLD_KEY", envvars.ActionDelete, ""),
+		}
+
+		Describe("#Print", func() {
+			It("prints the changes without values", func() {
+				plan.Print(out)
+				Ω(out.String()).Should(Equal(`+ github/foobar/example NEW_KEY
+- github/foobar/example OLD_KEY
+Plan: 1 to create, 0 to update, 1 to delete
+`))
+			})
+
+			It("counts the values that cannot be verified separately", func() {
+				envvars.Plan{
+					envvars.NewChange("github/foobar/example", "REGION", envvars.ActionUnverified, "eu"),
+				}.Print(out)
+				Ω(out.String()).Should(Equal(`? github/foobar/example REGION
+Plan: 0 to create, 0 to update, 0 to delete, 1 to set that cannot be verified
+`))
+			})
+		})
+
+		Describe("#Apply", func() {
+			Context("when every change succeeds", func() {
+				BeforeEach(func() {
+					circleCIClient.On("CreateProjectEnvVar", "github/foobar/example", "NEW_KEY", "top-secret").Return(circleci.ProjectEnvVar{}, nil)
+					circleCIClient.On("DeleteProjectEnvVar", "github/foobar/example", "OLD_KEY").Return(nil)
+				})
+
+				It("makes every change", func() {
+					Ω(plan.Apply(circleCIClient, out)).Should(Succeed())
+					circleCIClient.AssertExpectations(GinkgoT())
+					Ω(out.String()).ShouldNot(ContainSubstring("top-secret"))
+				})
+			})
+
+			Context("when a change fails", func() {
+				BeforeEach(func() {
+					circleCIClient.On("CreateProjectEnvVar", "github/foobar/example", "NEW_KEY", "top-secret").Return(circleci.ProjectEnvVar{}, fmt.Errorf("Permission denied"))
+				})
+
+				It("stops and returns the error", func() {
+					Ω(plan.Apply(circleCIClient, out)).Should(MatchError("+ github/foobar/example NEW_KEY: Permission denied"))
+					circleCIClient.AssertNotCalled(GinkgoT(), "DeleteProjectEnvVar", "github/foobar/example", "OLD_KEY")
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/envvars"
+)
+
+const envVarsUsage = `Usage: circleci-workflow-dashboard envvars <command> [flags]
+
+Commands:
+  list                 List variable names per project
+  diff                 Show variables missing from, or differing between, projects
+  set NAME             Create or update a variable in every selected project
+  rotate NAME          Update a variable in the selected projects that already have it
+  unset NAME           Delete a variable from every selected project
+  copy --from PROJECT  Set the variables of one project in every selected project
+  apply -f FILE        Make projects match a desired-state file
+
+Values for set and rotate are read from --value-env, --value-file or stdin.
+Values for copy are read from local environment variables of the same name.
+apply --dry-run exits 1 if any project has drifted from the file.
+`
+
+type envVarsOptions struct {
+	flags      *flag.FlagSet
+	project    string
+	filterJSON string
+	dryRun     bool
+	demo       bool
+	valueEnv   string
+	valueFile  string
+	from       string
+	names      string
+	file       string
+}
+
+func newEnvVarsOptions(command string) *envVarsOptions {
+	options := &envVarsOptions{flags: flag.NewFlagSet("envvars "+command, flag.ExitOnError)}
+	options.flags.StringVar(&options.project, "project", "", "Select projects matching this glob, e.g. 'myorg/*'")
+	options.flags.StringVar(&options.filterJSON, "filter", "", "Select projects with a DASHBOARD_FILTER style JSON filter")
+	options.flags.BoolVar(&options.dryRun, "dry-run", false, "Show the plan without changing anything")
+	options.flags.BoolVar(&options.demo, "demo", false, "Use a built-in fake CircleCI instead of the real API")
+	switch command {
+	case "set", "rotate":
+		options.flags.StringVar(&options.valueEnv, "value-env", "", "Read the value from this local environment variable")
+		options.flags.StringVar(&options.valueFile, "value-file", "", "Read the value from this file")
+	case "copy":
+		options.flags.StringVar(&options.from, "from", "", "The username/reponame of the project to copy from")
+		options.flags.StringVar(&options.names, "names", "", "Only copy these comma separated variables")
+	case "apply":
+		options.flags.StringVar(&options.file, "f", "envvars.yaml", "The desired-state file")
+	}
+	return options
+}
+
+// parse allows flags both before and after the variable name.
+func (o *envVarsOptions) parse(args []string) string {
+	o.flags.Parse(args)
+	if o.flags.NArg() == 0 {
+		return ""
+	}
+	name := o.flags.Arg(0)
+	o.flags.Parse(o.flags.Args()[1:])
+	return name
+}
+
+func (o *envVarsOptions) filter(defaultFilter *circleci.Filter) (*circleci.Filter, error) {
+	if o.project != "" {
+		return &circleci.Filter{o.project: nil}, nil
+	}
+	if o.filterJSON != "" {
+		var filter circleci.Filter
+		if err := json.Unmarshal([]byte(o.filterJSON), &filter); err != nil {
+			return nil, fmt.Errorf("Error loading filter: %v", err.Error())
+		}
+		return &filter, nil
+	}
+	return defaultFilter, nil
+}
+
+func (o *envVarsOptions) value() (string, error) {
+	switch {
+	case o.valueEnv != "":
+		value, ok := os.LookupEnv(o.valueEnv)
+		if !ok {
+			return "", fmt.Errorf("%s is not set", o.valueEnv)
+		}
+		return value, nil
+	case o.valueFile != "":
+		value, err := ioutil.ReadFile(o.valueFile)
+		return trimValue(value), err
+	}
+	value, err := ioutil.ReadAll(os.Stdin)
+	return trimValue(value), err
+}
+
+// trimValue drops the line ending that files and piped input usually end
+// with, which is never part of the value.
+func trimValue(value []byte) string {
+	return strings.TrimRight(string(value), "\r\n")
+}
+
+func envVarsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, envVarsUsage)
+		return exitError
+	}
+	command := args[0]
+	options := newEnvVarsOptions(command)
+	switch command {
+	case "list", "diff", "set", "rotate", "unset", "copy", "apply":
+	default:
+		fmt.Fprint(os.Stderr, envVarsUsage)
+		return exitError
+	}
+	name := options.parse(args[1:])
+	if err := runEnvVars(command, name, options); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if err == errDrift || err == errDesiredDrift {
+			return exitFailing
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+var (
+	errDrift        = fmt.Errorf("Environment variables differ between projects")
+	errDesiredDrift = fmt.Errorf("Environment variables differ from the desired state")
+)
+
+func runEnvVars(command, name string, options *envVarsOptions) error {
+	if (command == "set" || command == "rotate" || command == "unset") && name == "" {
+		return fmt.Errorf("envvars %s needs a variable name", command)
+	}
+	circleCIClient, defaultFilter, closer, err := newCircleCIClient(options.demo)
+	defer closer()
+	if err != nil {
+		return err
+	}
+	var desired *envvars.DesiredState
+	if command == "apply" {
+		if desired, err = envvars.LoadDesiredState(options.file); err != nil {
+			return err
+		}
+		defaultFilter = desired.Filter()
+	}
+	filter, err := options.filter(defaultFilter)
+	if err != nil {
+		return err
+	}
+	projects, err := circleCIClient.GetAllProjects()
+	if err != nil {
+		return err
+	}
+	var source circleci.Project
+	if command == "copy" {
+		if options.from == "" {
+			return fmt.Errorf("envvars copy needs --from")
+		}
+		var found bool
+		var targets circleci.Projects
+		for _, project := range projects.Filter(filter) {
+			if project.Name() == options.from {
+				continue
+			}
+			targets = append(targets, project)
+		}
+		for _, project := range projects {
+			if project.Name() == options.from {
+				source, found = project, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Could not find project %s", options.from)
+		}
+		projects = targets
+	} else {
+		projects = projects.Filter(filter)
+	}
+	inventory, err := envvars.Load(circleCIClient, projects)
+	if err != nil {
+		return err
+	}
+
+	var plan envvars.Plan
+	switch command {
+	case "list":
+		return inventory.List(os.Stdout)
+	case "diff":
+		drifted, err := inventory.Diff(os.Stdout)
+		if err == nil && drifted {
+			return errDrift
+		}
+		return err
+	case "set", "rotate":
+		value, err := options.value()
+		if err != nil {
+			return err
+		}
+		if command == "set" {
+			plan = inventory.PlanSet(name, value)
+		} else if plan, err = inventory.PlanRotate(name, value); err != nil {
+			return err
+		}
+	case "unset":
+		plan = inventory.PlanUnset(name)
+	case "copy":
+		sourceVars, err := circleCIClient.GetProjectEnvVars(source.Slug())
+		if err != nil {
+			return err
+		}
+		var names []string
+		if options.names != "" {
+			names = strings.Split(options.names, ",")
+		}
+		if plan, err = inventory.PlanCopy(sourceVars, names, os.LookupEnv); err != nil {
+			return err
+		}
+	case "apply":
+		if plan, err = inventory.PlanApply(desired, projects, os.LookupEnv); err != nil {
+			return err
+		}
+	}
+	plan.Print(os.Stdout)
+	if options.dryRun {
+		if command == "apply" && plan.Drifted() {
+			return errDesiredDrift
+		}
+		return nil
+	}
+	return plan.Apply(circleCIClient, os.Stdout)
+}
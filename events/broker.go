@@ -0,0 +1,40 @@
+package events
+
+import "sync"
+
+// Broker fans out dashboard change notifications to every subscribed client.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan struct{}]struct{}{}}
+}
+
+// Subscribe returns a channel that receives a value after each Publish, and a
+// function to stop receiving them.
+func (b *Broker) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Publish notifies every subscriber without blocking; a subscriber that has
+// not yet handled the previous notification will only see one.
+func (b *Broker) Publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package events_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/events"
+)
+
+var _ = Describe("Broker", func() {
+	var broker *events.Broker
+
+	BeforeEach(func() {
+		broker = events.NewBroker()
+	})
+
+	It("notifies every subscriber", func() {
+		first, _ := broker.Subscribe()
+		second, _ := broker.Subscribe()
+		broker.Publish()
+		Eventually(first).Should(Receive())
+		Eventually(second).Should(Receive())
+	})
+
+	It("collapses notifications a subscriber has not handled yet", func() {
+		ch, _ := broker.Subscribe()
+		broker.Publish()
+		broker.Publish()
+		Eventually(ch).Should(Receive())
+		Consistently(ch).ShouldNot(Receive())
+	})
+
+	It("stops notifying after unsubscribing", func() {
+		ch, unsubscribe := broker.Subscribe()
+		unsubscribe()
+		broker.Publish()
+		Consistently(ch).ShouldNot(Receive())
+	})
+})
@@ -0,0 +1,230 @@
+// Package gitlab shows GitLab CI pipelines on the dashboard.
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+const (
+	defaultAPIURL = "https://gitlab.com"
+	// pageSize is GitLab's largest page. Only the first page of pipelines is
+	// read, the most recent pipelines being all the dashboard shows.
+	pageSize   = 100
+	slugPrefix = "gitlab/"
+	// pipelineName names the tiles of pipelines without a name of their
+	// own, GitLab having one pipeline per ref rather than named workflows.
+	pipelineName = "pipeline"
+)
+
+type Config struct {
+	APIURL string
+	Token  string
+	// Projects lists projects by their path, e.g. group/project, or
+	// group/* for all of a group's projects, including its subgroups'.
+	Projects []string
+}
+
+// Client is a Provider of the pipelines of GitLab projects.
+type Client struct {
+	Config *Config
+	Client *resty.Client
+}
+
+func NewClient(config *Config) (*Client, error) {
+	if config.APIURL == "" {
+		config.APIURL = defaultAPIURL
+	}
+	if len(config.Projects) == 0 {
+		return nil, fmt.Errorf("Must list at least one project")
+	}
+	client := resty.New()
+	if config.Token != "" {
+		client.SetHeader("PRIVATE-TOKEN", config.Token)
+	}
+	return &Client{Client: client, Config: config}, nil
+}
+
+func (c *Client) get(urlPath string, result interface{}) error {
+	resp, err := c.Client.R().
+		SetHeader("Accept", "application/json").
+		Get(fmt.Sprintf("%s/api/v4/%s", strings.TrimSuffix(c.Config.APIURL, "/"), urlPath))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() > 299 {
+		return fmt.Errorf("GitLab returned %s for %s", resp.Status(), urlPath)
+	}
+	return json.Unmarshal(resp.Body(), result)
+}
+
+type project struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+func (c *Client) groupProjects(group string) ([]string, error) {
+	var paths []string
+	for page := 1; ; page++ {
+		var projects []project
+		err := c.get(fmt.Sprintf("groups/%s/projects?include_subgroups=true&archived=false&per_page=%d&page=%d", url.PathEscape(group), pageSize, page), &projects)
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			paths = append(paths, project.PathWithNamespace)
+		}
+		if len(projects) < pageSize {
+			return paths, nil
+		}
+	}
+}
+
+func (c *Client) Projects() ([]dashboard.Project, error) {
+	var (
+		projects []dashboard.Project
+		seen     = map[string]bool{}
+	)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			projects = append(projects, dashboard.Project{Name: name, Slug: slugPrefix + name})
+		}
+	}
+	for _, pattern := range c.Config.Projects {
+		if !strings.ContainsAny(pattern, "*?[") {
+			add(pattern)
+			continue
+		}
+		group := path.Dir(pattern)
+		if group == "." || strings.ContainsAny(group, "*?[") {
+			return nil, fmt.Errorf("Invalid project %q, only the last part of the path can be a glob", pattern)
+		}
+		paths, err := c.groupProjects(group)
+		if err != nil {
+			return nil, err
+		}
+		for _, projectPath := range paths {
+			if matched, _ := path.Match(pattern, projectPath); matched {
+				add(projectPath)
+			}
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+type pipeline struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Ref       string    `json:"ref"`
+	SHA       string    `json:"sha"`
+	Status    string    `json:"status"`
+	Source    string    `json:"source"`
+	WebURL    string    `json:"web_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// tag is set on the pipelines listed for tags rather than branches.
+	tag bool
+}
+
+// state maps GitLab's pipeline status onto the dashboard's states. Skipped
+// pipelines have no state, and are left off.
+func (p pipeline) state() dashboard.State {
+	switch p.Status {
+	case "success":
+		return dashboard.StateSuccess
+	case "failed":
+		return dashboard.StateFailed
+	case "canceled":
+		return dashboard.StateCanceled
+	case "manual", "scheduled":
+		return dashboard.StateOnHold
+	case "created", "waiting_for_resource", "preparing", "pending", "running":
+		return dashboard.StateRunning
+	case "skipped":
+		return ""
+	}
+	return dashboard.StateUnknown
+}
+
+func (p pipeline) completed() bool {
+	return p.Status == "success" || p.Status == "failed" || p.Status == "canceled"
+}
+
+func (p pipeline) trigger() string {
+	if p.Source == "schedule" {
+		return circleci.TriggerSourceScheduled
+	}
+	return circleci.TriggerSourcePush
+}
+
+// projectPipelines lists the latest pipelines of a project for either
+// branches or tags, newest first.
+func (c *Client) projectPipelines(project dashboard.Project, scope string) ([]pipeline, error) {
+	var pipelines []pipeline
+	err := c.get(fmt.Sprintf("projects/%s/pipelines?scope=%s&order_by=id&sort=desc&per_page=%d", url.PathEscape(project.Name), scope, pageSize), &pipelines)
+	return pipelines, err
+}
+
+// WorkflowRuns lists the latest branch and tag pipelines of a project, which
+// are fetched separately so that a burst of pushes to branches does not push
+// the tag pipelines out of the page.
+func (c *Client) WorkflowRuns(project dashboard.Project) (dashboard.WorkflowRuns, error) {
+	pipelines, err := c.projectPipelines(project, "branches")
+	if err != nil {
+		return nil, err
+	}
+	tagPipelines, err := c.projectPipelines(project, "tags")
+	if err != nil {
+		return nil, err
+	}
+	for i := range tagPipelines {
+		tagPipelines[i].tag = true
+	}
+	pipelines = append(pipelines, tagPipelines...)
+	sort.SliceStable(pipelines, func(i, j int) bool { return pipelines[i].ID > pipelines[j].ID })
+	var runs dashboard.WorkflowRuns
+	for _, pipeline := range pipelines {
+		state := pipeline.state()
+		// Merge request pipelines run on refs of their own rather than a
+		// branch, so they are left off with skipped ones.
+		if state == "" || pipeline.Source == "merge_request_event" {
+			continue
+		}
+		name := pipeline.Name
+		if name == "" {
+			name = pipelineName
+		}
+		run := dashboard.WorkflowRun{
+			ID:         fmt.Sprint(pipeline.ID),
+			Name:       name,
+			PipelineID: fmt.Sprint(pipeline.ID),
+			Trigger:    pipeline.trigger(),
+			Revision:   pipeline.SHA,
+			State:      state,
+			Link:       pipeline.WebURL,
+			CreatedAt:  pipeline.CreatedAt,
+		}
+		if pipeline.tag {
+			run.Tag = pipeline.Ref
+		} else {
+			run.Branch = pipeline.Ref
+		}
+		if pipeline.completed() {
+			stoppedAt := pipeline.UpdatedAt
+			run.StoppedAt = &stoppedAt
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
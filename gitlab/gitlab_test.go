@@ -0,0 +1,167 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/gitlab"
+)
+
+const examplePipelines = `[
+	{"id": 7, "ref": "main", "sha": "ccc", "status": "running", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/7", "created_at": "2021-03-01T12:00:00Z", "updated_at": "2021-03-01T12:01:00Z"},
+	{"id": 6, "ref": "refs/merge-requests/3/head", "sha": "eee", "status": "failed", "source": "merge_request_event", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/6", "created_at": "2021-03-01T11:50:00Z", "updated_at": "2021-03-01T11:55:00Z"},
+	{"id": 5, "ref": "main", "sha": "bbb", "status": "skipped", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/5", "created_at": "2021-03-01T11:30:00Z", "updated_at": "2021-03-01T11:30:00Z"},
+	{"id": 4, "ref": "main", "sha": "aaa", "status": "failed", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/4", "created_at": "2021-03-01T11:00:00Z", "updated_at": "2021-03-01T11:10:00Z"},
+	{"id": 3, "ref": "main", "sha": "999", "status": "success", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/3", "created_at": "2021-03-01T10:00:00Z", "updated_at": "2021-03-01T10:10:00Z"},
+	{"id": 2, "ref": "release", "sha": "888", "status": "manual", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/2", "created_at": "2021-03-01T09:00:00Z", "updated_at": "2021-03-01T09:05:00Z"},
+	{"id": 1, "ref": "main", "sha": "777", "status": "success", "source": "schedule", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/1", "created_at": "2021-03-01T02:00:00Z", "updated_at": "2021-03-01T02:10:00Z"}
+]`
+
+var _ = Describe("GitLab", func() {
+	var (
+		server       *httptest.Server
+		requests     []*http.Request
+		client       *gitlab.Client
+		projects     []string
+		tagPipelines string
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		projects = []string{"mygroup/example"}
+		tagPipelines = "[]"
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			switch r.URL.EscapedPath() {
+			case "/api/v4/projects/mygroup%2Fexample/pipelines":
+				if r.URL.Query().Get("scope") == "tags" {
+					fmt.Fprint(w, tagPipelines)
+					return
+				}
+				fmt.Fprint(w, examplePipelines)
+			case "/api/v4/groups/mygroup/projects":
+				fmt.Fprint(w, `[{"path_with_namespace": "mygroup/example"}, {"path_with_namespace": "mygroup/sub/nested"}]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		client, err = gitlab.NewClient(&gitlab.Config{APIURL: server.URL, Token: "secret", Projects: projects})
+		Ω(err).Should(BeNil())
+	})
+
+	Describe("#NewClient", func() {
+		It("needs projects", func() {
+			_, err := gitlab.NewClient(&gitlab.Config{})
+			Ω(err).Should(MatchError("Must list at least one project"))
+		})
+	})
+
+	Describe("#Projects", func() {
+		It("lists the configured projects", func() {
+			found, err := client.Projects()
+			Ω(err).Should(BeNil())
+			Ω(found).Should(Equal([]dashboard.Project{{Name: "mygroup/example", Slug: "gitlab/mygroup/example"}}))
+			Ω(requests).Should(BeEmpty())
+		})
+
+		Context("with a group's projects", func() {
+			BeforeEach(func() {
+				projects = []string{"mygroup/*"}
+			})
+
+			It("lists the projects matching the glob", func() {
+				found, err := client.Projects()
+				Ω(err).Should(BeNil())
+				Ω(found).Should(HaveLen(1))
+				Ω(found[0].Name).Should(Equal("mygroup/example"))
+				Ω(requests[0].Header.Get("PRIVATE-TOKEN")).Should(Equal("secret"))
+				Ω(requests[0].URL.Query().Get("include_subgroups")).Should(Equal("true"))
+			})
+		})
+
+		Context("with a glob in the group", func() {
+			BeforeEach(func() {
+				projects = []string{"my*/example"}
+			})
+
+			It("returns an error", func() {
+				_, err := client.Projects()
+				Ω(err).Should(MatchError(`Invalid project "my*/example", only the last part of the path can be a glob`))
+			})
+		})
+	})
+
+	Describe("#WorkflowRuns", func() {
+		It("maps pipelines onto runs, leaving out skipped and merge request ones", func() {
+			runs, err := client.WorkflowRuns(dashboard.Project{Name: "mygroup/example"})
+			Ω(err).Should(BeNil())
+			Ω(runs).Should(HaveLen(5))
+			Ω(runs[0].Name).Should(Equal("pipeline"))
+			Ω(runs[0].State).Should(Equal(dashboard.StateRunning))
+			Ω(runs[0].StoppedAt).Should(BeNil())
+			Ω(runs[1].State).Should(Equal(dashboard.StateFailed))
+			Ω(runs[1].StoppedAt).ShouldNot(BeNil())
+			Ω(runs[3].State).Should(Equal(dashboard.StateOnHold))
+			Ω(runs[4].Trigger).Should(Equal("scheduled"))
+		})
+
+		Context("with tag pipelines", func() {
+			BeforeEach(func() {
+				tagPipelines = `[
+					{"id": 9, "ref": "v1.1.0", "sha": "ddd", "status": "running", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/9", "created_at": "2021-03-01T13:00:00Z", "updated_at": "2021-03-01T13:01:00Z"},
+					{"id": 8, "ref": "v1.0.0", "sha": "ccc", "status": "success", "source": "push", "web_url": "https://gitlab.example.com/mygroup/example/-/pipelines/8", "created_at": "2021-03-01T12:30:00Z", "updated_at": "2021-03-01T12:40:00Z"}
+				]`
+			})
+
+			It("fetches them separately and sets their tag instead of a branch", func() {
+				runs, err := client.WorkflowRuns(dashboard.Project{Name: "mygroup/example"})
+				Ω(err).Should(BeNil())
+				Ω(requests).Should(HaveLen(2))
+				Ω(requests[0].URL.Query().Get("scope")).Should(Equal("branches"))
+				Ω(requests[1].URL.Query().Get("scope")).Should(Equal("tags"))
+				Ω(runs).Should(HaveLen(7))
+				Ω(runs[0].ID).Should(Equal("9"))
+				Ω(runs[0].Tag).Should(Equal("v1.1.0"))
+				Ω(runs[0].Branch).Should(BeEmpty())
+				Ω(runs[1].Tag).Should(Equal("v1.0.0"))
+				Ω(runs[1].StoppedAt).ShouldNot(BeNil())
+				Ω(runs[2].Branch).Should(Equal("main"))
+				Ω(runs[2].Tag).Should(BeEmpty())
+			})
+		})
+
+		It("reports errors from GitLab", func() {
+			_, err := client.WorkflowRuns(dashboard.Project{Name: "mygroup/missing"})
+			Ω(err).Should(MatchError("GitLab returned 404 Not Found for projects/mygroup%2Fmissing/pipelines?scope=branches&order_by=id&sort=desc&per_page=100"))
+		})
+	})
+
+	Describe("as a provider", func() {
+		It("builds a tile per ref behind the previous completed pipeline", func() {
+			monitors, err := dashboard.BuildProvider(context.Background(), client, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{})
+			Ω(err).Should(BeNil())
+			Ω(monitors).Should(HaveLen(2))
+			Ω(monitors[0].Name).Should(Equal("mygroup/example"))
+			Ω(monitors[0].Branch).Should(Equal("main"))
+			Ω(monitors[0].Workflow).Should(Equal("pipeline"))
+			Ω(monitors[0].Status).Should(Equal("running failed"))
+			Ω(monitors[0].Link).Should(Equal("https://gitlab.example.com/mygroup/example/-/pipelines/7"))
+			Ω(monitors[1].Branch).Should(Equal("release"))
+			Ω(monitors[1].Status).Should(Equal("on_hold unknown"))
+		})
+	})
+})
@@ -0,0 +1,178 @@
+// Package health answers the liveness and readiness probes, and reports how
+// collection is going for whoever is on call.
+package health
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/armakuni/circleci-workflow-dashboard/cluster"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/scheduler"
+)
+
+// Checker knows where to look for the dashboard's health.
+type Checker struct {
+	Snapshots dashboard.Snapshots
+	Scheduler *scheduler.Scheduler
+	Cluster   *cluster.State
+	// ReadyIntervals is how many refresh intervals old the latest snapshot
+	// may be before the dashboard is no longer ready.
+	ReadyIntervals int
+	ConfigHash     string
+	Now            func() time.Time
+}
+
+func NewChecker(snapshots dashboard.Snapshots, refreshScheduler *scheduler.Scheduler, clusterState *cluster.State, readyIntervals int, configHash string) *Checker {
+	return &Checker{
+		Snapshots:      snapshots,
+		Scheduler:      refreshScheduler,
+		Cluster:        clusterState,
+		ReadyIntervals: readyIntervals,
+		ConfigHash:     configHash,
+		Now:            time.Now,
+	}
+}
+
+// Ready returns why the dashboard is not ready to serve, if it is not: it has
+// no snapshot, or the latest is older than ReadyIntervals refresh intervals.
+func (h *Checker) Ready() error {
+	snapshot, found := h.Snapshots.Get()
+	if !found {
+		return fmt.Errorf("No snapshot has been loaded yet")
+	}
+	if snapshot.Err != nil && !snapshot.Stale {
+		return fmt.Errorf("No snapshot has been collected yet: %v", snapshot.Err)
+	}
+	now := h.Now()
+	// A snapshot taken during quiet hours is only due a refresh after the
+	// quiet interval, even once they are over.
+	interval := h.Scheduler.SettledInterval(now)
+	if takenInterval := h.Scheduler.SettledInterval(snapshot.TakenAt); takenInterval > interval {
+		interval = takenInterval
+	}
+	if now.Sub(snapshot.TakenAt) > time.Duration(h.ReadyIntervals)*interval {
+		return fmt.Errorf("The latest snapshot is %s old, more than %d refresh intervals", snapshot.Age(now), h.ReadyIntervals)
+	}
+	return nil
+}
+
+// Healthz answers as long as the process is up.
+func Healthz(c *gin.Context) {
+	c.String(http.StatusOK, "ok")
+}
+
+// Readyz answers 503 with the reason while the dashboard is not Ready.
+func (h *Checker) Readyz(c *gin.Context) {
+	if err := h.Ready(); err != nil {
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "ok")
+}
+
+type SourceStatus struct {
+	Name     string `json:"name,omitempty"`
+	APICalls int    `json:"api_calls"`
+	Token    string `json:"token"`
+	Err      string `json:"error,omitempty"`
+}
+
+type ProjectStatus struct {
+	Source      string    `json:"source,omitempty"`
+	Slug        string    `json:"slug"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+	Duration    string    `json:"duration"`
+	NextRefresh time.Time `json:"next_refresh"`
+	Err         string    `json:"error,omitempty"`
+}
+
+// Status is the body of /debug/status. Only the leader collects, so the
+// refresh, source and project details of a follower are empty.
+type Status struct {
+	ConfigHash          string          `json:"config_hash"`
+	Ready               bool            `json:"ready"`
+	NotReadyReason      string          `json:"not_ready_reason,omitempty"`
+	Leading             bool            `json:"leading"`
+	Leader              string          `json:"leader,omitempty"`
+	SnapshotTakenAt     *time.Time      `json:"snapshot_taken_at,omitempty"`
+	LastRefresh         *time.Time      `json:"last_refresh,omitempty"`
+	LastRefreshDuration string          `json:"last_refresh_duration,omitempty"`
+	Sources             []SourceStatus  `json:"sources"`
+	Projects            []ProjectStatus `json:"projects"`
+}
+
+func (h *Checker) Status() Status {
+	status := Status{
+		ConfigHash: h.ConfigHash,
+		Leading:    h.Cluster.Leading(),
+		Leader:     h.Cluster.Leader(),
+		Sources:    []SourceStatus{},
+		Projects:   []ProjectStatus{},
+	}
+	if err := h.Ready(); err != nil {
+		status.NotReadyReason = err.Error()
+	} else {
+		status.Ready = true
+	}
+	if snapshot, found := h.Snapshots.Get(); found {
+		status.SnapshotTakenAt = &snapshot.TakenAt
+	}
+	collection := h.Scheduler.Status()
+	if !collection.LastRefresh.IsZero() {
+		status.LastRefresh = &collection.LastRefresh
+		status.LastRefreshDuration = collection.LastRefreshDuration.String()
+	}
+	for _, source := range collection.Sources {
+		sourceStatus := SourceStatus{Name: source.Source.Name, Token: dashboard.TokenUnknown}
+		if stats := source.Source.Stats; stats != nil {
+			sourceStatus.APICalls = stats.Calls()
+			sourceStatus.Token = stats.Token()
+		}
+		if source.Err != nil {
+			sourceStatus.Err = source.Err.Error()
+		}
+		status.Sources = append(status.Sources, sourceStatus)
+	}
+	for _, project := range collection.Projects {
+		projectStatus := ProjectStatus{
+			Source:      project.Source,
+			Slug:        project.Slug,
+			RefreshedAt: project.RefreshedAt,
+			Duration:    project.Duration.String(),
+			NextRefresh: project.NextRefresh,
+		}
+		if project.Err != nil {
+			projectStatus.Err = project.Err.Error()
+		}
+		status.Projects = append(status.Projects, projectStatus)
+	}
+	return status
+}
+
+// DebugStatus reports the Status as JSON.
+func (h *Checker) DebugStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Status())
+}
+
+// ConfigHash hashes the configuration, given as the values of environment
+// variables, so that replicas running with different configuration stand out.
+// The values themselves are not shown, as some of them are secrets.
+func ConfigHash(env map[string]string) string {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s=%q\n", name, env[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
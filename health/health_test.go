@@ -0,0 +1,125 @@
+package health_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/cluster"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/health"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+	"github.com/armakuni/circleci-workflow-dashboard/scheduler"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		now       = time.Date(2020, 9, 4, 12, 0, 0, 0, time.UTC)
+		snapshots *dashboard.SnapshotStore
+		refresher *scheduler.Scheduler
+		checker   *health.Checker
+		stats     *dashboard.APIStats
+		project   = circleci.Project{VCSType: "github", Username: "foobar", Reponame: "example", Branches: map[string]interface{}{"master": nil}}
+	)
+
+	BeforeEach(func() {
+		circleCIClient := &mocks.CircleCI{}
+		circleCIClient.On("GetAllProjects").Return(circleci.Projects{project}, nil)
+		circleCIClient.On("GetAllPipelines", project).Return(nil, fmt.Errorf("Error getting pipelines"))
+		circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+		stats = dashboard.NewAPIStats()
+		refresher = scheduler.NewForSources([]dashboard.Source{
+			{Name: "github", Provider: dashboard.NewCircleCIProvider(circleCIClient, &circleci.Filter{}), Stats: stats},
+		}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{}, scheduler.Config{IdleInterval: 30 * time.Second})
+		refresher.Now = func() time.Time { return now }
+		snapshots = dashboard.NewSnapshotStore()
+		clusterState := cluster.NewState(cluster.Single{})
+		clusterState.Campaign()
+		checker = health.NewChecker(snapshots, refresher, clusterState, 3, "abc123")
+		checker.Now = func() time.Time { return now }
+	})
+
+	Describe("#Ready", func() {
+		It("is not ready before there is a snapshot", func() {
+			Ω(checker.Ready()).Should(MatchError("No snapshot has been loaded yet"))
+		})
+
+		It("is not ready when the first refresh failed", func() {
+			snapshots.Set(dashboard.Snapshot{TakenAt: now, Err: fmt.Errorf("Error getting projects")})
+			Ω(checker.Ready()).Should(MatchError("No snapshot has been collected yet: Error getting projects"))
+		})
+
+		It("is ready with a recent snapshot", func() {
+			snapshots.Set(dashboard.Snapshot{TakenAt: now.Add(-time.Minute)})
+			Ω(checker.Ready()).Should(Succeed())
+		})
+
+		It("is not ready once the snapshot is older than the refresh intervals allowed", func() {
+			snapshots.Set(dashboard.Snapshot{TakenAt: now.Add(-2 * time.Minute)})
+			Ω(checker.Ready()).Should(MatchError("The latest snapshot is 2m old, more than 3 refresh intervals"))
+		})
+	})
+
+	Describe("the endpoints", func() {
+		var router *gin.Engine
+
+		BeforeEach(func() {
+			gin.SetMode(gin.TestMode)
+			router = gin.New()
+			router.GET("/healthz", health.Healthz)
+			router.GET("/readyz", checker.Readyz)
+			router.GET("/debug/status", checker.DebugStatus)
+		})
+
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			return recorder
+		}
+
+		It("is always healthy", func() {
+			Ω(get("/healthz").Code).Should(Equal(http.StatusOK))
+		})
+
+		It("answers 503 while not ready", func() {
+			response := get("/readyz")
+			Ω(response.Code).Should(Equal(http.StatusServiceUnavailable))
+			Ω(response.Body.String()).Should(Equal("No snapshot has been loaded yet"))
+			snapshots.Set(dashboard.Snapshot{TakenAt: now})
+			Ω(get("/readyz").Code).Should(Equal(http.StatusOK))
+		})
+
+		It("reports how collection is going", func() {
+			stats.Record(http.StatusUnauthorized)
+			refresher.Refresh()
+			var status health.Status
+			Ω(json.Unmarshal(get("/debug/status").Body.Bytes(), &status)).Should(Succeed())
+			Ω(status.ConfigHash).Should(Equal("abc123"))
+			Ω(status.Ready).Should(BeFalse())
+			Ω(status.Leading).Should(BeTrue())
+			Ω(status.LastRefresh.Equal(now)).Should(BeTrue())
+			Ω(status.Sources).Should(Equal([]health.SourceStatus{{Name: "github", APICalls: 1, Token: dashboard.TokenRejected}}))
+			Ω(status.Projects).Should(HaveLen(1))
+			Ω(status.Projects[0].Source).Should(Equal("github"))
+			Ω(status.Projects[0].Slug).Should(Equal("github/foobar/example"))
+			Ω(status.Projects[0].Err).Should(Equal("Error getting pipelines"))
+		})
+	})
+
+	Describe("#ConfigHash", func() {
+		It("depends on every value, whatever the order", func() {
+			hash := health.ConfigHash(map[string]string{"REFRESH_INTERVAL": "30", "HIDE_BRANCH": "true"})
+			Ω(hash).Should(HaveLen(12))
+			Ω(health.ConfigHash(map[string]string{"HIDE_BRANCH": "true", "REFRESH_INTERVAL": "30"})).Should(Equal(hash))
+			Ω(health.ConfigHash(map[string]string{"REFRESH_INTERVAL": "60", "HIDE_BRANCH": "true"})).ShouldNot(Equal(hash))
+		})
+	})
+})
@@ -1,56 +1,241 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/armakuni/circleci-workflow-dashboard/auth"
 	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/circleci/fake"
+	"github.com/armakuni/circleci-workflow-dashboard/cluster"
 	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/dora"
+	"github.com/armakuni/circleci-workflow-dashboard/events"
+	"github.com/armakuni/circleci-workflow-dashboard/health"
+	"github.com/armakuni/circleci-workflow-dashboard/notify"
+	"github.com/armakuni/circleci-workflow-dashboard/scheduler"
+	"github.com/armakuni/circleci-workflow-dashboard/sources"
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
+	"github.com/armakuni/circleci-workflow-dashboard/webhook"
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
 )
 
 type Dashboard struct {
 	RefreshInterval   int
 	Now               string
 	DashboardMonitors dashboard.Monitors
+	// SourceErrors are the errors of the sources that are failing while
+	// others still work.
+	SourceErrors dashboard.SourceErrors
+	// Stale is set while showing a snapshot from before the last failed
+	// refresh or restart, taken Age ago.
+	Stale bool
+	Age   string
 }
 
-func updateDashboard(circleCIClient *circleci.Client, filter *circleci.Filter, ticker *time.Ticker, c *cache.Cache, dashboardFeatureFlags *dashboard.FeatureFlags, monitorConfig *dashboard.MonitorConfig) {
-	for ; true; <-ticker.C {
-		dashboardMonitors, err := dashboard.Build(circleCIClient, filter, dashboardFeatureFlags, monitorConfig)
-		c.Set("dashErr", err, cache.NoExpiration)
-		c.Set("dashboardMonitors", dashboardMonitors, cache.NoExpiration)
-		c.Set("now", time.Now().Format("2006-01-02 15:04:05 -0700"), cache.NoExpiration)
+// staleRefreshInterval is how often a page showing a stale snapshot checks
+// for fresh data, in case it misses the event announcing it.
+const staleRefreshInterval = 10
+
+func updateDashboard(snapshots dashboard.Snapshots, broker *events.Broker, history *dashboard.History, acknowledgements *dashboard.Acknowledgements, notifier *notify.Notifier) func(*scheduler.Scheduler) {
+	return func(s *scheduler.Scheduler) {
+		// A refresh from a webhook can come before the first full refresh,
+		// when the scheduler only has some of the projects. Keep showing the
+		// snapshot from before the restart until it has them all.
+		if !s.Refreshed() {
+			return
+		}
+		now := time.Now()
+		monitors, err := history.Record(s.Monitors(), now)
+		if err != nil {
+			slog.Error("Error saving history", "err", err)
+		}
+		if monitors, err = acknowledgements.Apply(monitors, now); err != nil {
+			slog.Error("Error saving acknowledgements", "err", err)
+		}
+		if notifier != nil {
+			if err := notifier.Notify(monitors, now); err != nil {
+				slog.Error("Error sending notifications", "err", err)
+			}
+		}
+		previous, found := snapshots.Get()
+		err = snapshots.Set(dashboard.Snapshot{
+			Monitors:     monitors,
+			TakenAt:      time.Now(),
+			NextRefresh:  s.NextRefresh(),
+			Err:          s.Err(),
+			SourceErrors: s.SourceErrors(),
+		})
+		if err != nil {
+			slog.Error("Error saving snapshot", "err", err)
+		}
+		current, _ := snapshots.Get()
+		if !found || previous.Stale != current.Stale || !reflect.DeepEqual(previous.Monitors, current.Monitors) {
+			broker.Publish()
+		}
 	}
 }
 
-func getCachedDashboard(c *cache.Cache, refreshInterval int) (Dashboard, error) {
-	if err, found := c.Get("dashErr"); err != nil && found {
-		return Dashboard{}, err.(error)
-	}
-	now, found := c.Get("now")
+// applyAcknowledgements shows a change of acknowledgements straight away by
+// applying them to the latest snapshot, rather than by refreshing. A snapshot
+// with an error keeps its monitors as they are until the next refresh.
+func applyAcknowledgements(snapshots dashboard.Snapshots, broker *events.Broker, acknowledgements *dashboard.Acknowledgements) error {
+	snapshot, found := snapshots.Get()
 	if !found {
-		return Dashboard{}, fmt.Errorf("Could not find cached dashboard data")
+		return nil
+	}
+	monitors, err := acknowledgements.Apply(snapshot.Monitors, time.Now())
+	if err != nil {
+		return err
 	}
-	dashboardMonitors, found := c.Get("dashboardMonitors")
+	snapshot.Monitors = monitors
+	if err := snapshots.Set(snapshot); err != nil {
+		return err
+	}
+	broker.Publish()
+	return nil
+}
+
+type acknowledgeForm struct {
+	Note    string `form:"note" json:"note"`
+	Owner   string `form:"owner" json:"owner"`
+	Expires string `form:"expires" json:"expires"`
+}
+
+func streamEvents(broker *events.Broker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		updates, unsubscribe := broker.Subscribe()
+		defer unsubscribe()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-updates:
+				c.SSEvent("refresh", time.Now().Unix())
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+func getCachedDashboard(snapshots dashboard.Snapshots) (Dashboard, error) {
+	snapshot, found := snapshots.Get()
 	if !found {
 		return Dashboard{}, fmt.Errorf("Could not find cached dashboard data")
 	}
+	if snapshot.Err != nil && !snapshot.Stale {
+		return Dashboard{}, snapshot.Err
+	}
+	refreshInterval := secondsUntil(snapshot.NextRefresh)
+	if snapshot.Stale {
+		refreshInterval = staleRefreshInterval
+	}
 	return Dashboard{
-		DashboardMonitors: dashboardMonitors.(dashboard.Monitors),
-		Now:               now.(string),
+		SourceErrors:      snapshot.SourceErrors,
+		DashboardMonitors: snapshot.Monitors,
+		Now:               snapshot.TakenAt.Format("2006-01-02 15:04:05 -0700"),
 		RefreshInterval:   refreshInterval,
+		Stale:             snapshot.Stale,
+		Age:               snapshot.Age(time.Now()),
 	}, nil
 }
 
-func setup(refreshInterval int) (*time.Ticker, *cache.Cache) {
-	ticker := time.NewTicker(time.Duration(refreshInterval) * time.Second)
-	cacher := cache.New(5*time.Minute, 5*time.Minute)
-	return ticker, cacher
+// snapshotWatchInterval is how often a follower checks for a new snapshot
+// from the leader, to tell its pages to reload.
+const snapshotWatchInterval = 5 * time.Second
+
+// watchSnapshots reloads the pages of a follower when the leader saves a new
+// snapshot.
+func watchSnapshots(snapshots dashboard.Snapshots, broker *events.Broker, clusterState *cluster.State, stop <-chan struct{}) {
+	ticker := time.NewTicker(snapshotWatchInterval)
+	defer ticker.Stop()
+	var last dashboard.Snapshot
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if clusterState.Leading() {
+			continue
+		}
+		snapshot, found := snapshots.Get()
+		if found && (!snapshot.TakenAt.Equal(last.TakenAt) || snapshot.Stale != last.Stale) {
+			last = snapshot
+			broker.Publish()
+		}
+	}
+}
+
+// forwardToLeader sends requests that change the dashboard on to the leader,
+// the only replica that collects, signed with the user they were signed in as.
+func forwardToLeader(clusterState *cluster.State, authConfig auth.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if clusterState.Leading() {
+			return
+		}
+		leader, err := url.Parse(clusterState.Leader())
+		if err != nil || leader.Host == "" {
+			c.String(503, "No replica is leading yet, try again shortly")
+			c.Abort()
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(leader)
+		director := proxy.Director
+		proxy.Director = func(request *http.Request) {
+			director(request)
+			authConfig.Forward(c, request)
+		}
+		proxy.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+// secondsUntil rounds up so the page never asks for a refresh before the
+// data it is waiting on has been collected.
+func secondsUntil(t time.Time) int {
+	seconds := int(math.Ceil(time.Until(t).Seconds())) + 1
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// recordDir is where this run saves its fixtures: a subdirectory of
+// CIRCLECI_RECORD_DIR named after the time the run started, so that each
+// recording is a bundle of its own that can be replayed on its own.
+var recordDir = sync.OnceValue(func() string {
+	dir := os.Getenv("CIRCLECI_RECORD_DIR")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, time.Now().UTC().Format("20060102T150405Z"))
+})
+
+// stopRecording stops the CircleCI sources saving fixtures.
+func stopRecording(sources []dashboard.Source) {
+	for _, source := range sources {
+		if provider, ok := source.Provider.(*dashboard.CircleCIProvider); ok {
+			if client, ok := provider.CircleCIClient.(*circleci.Client); ok {
+				client.StopRecording()
+			}
+		}
+	}
 }
 
 func getConfig() (*circleci.Config, *circleci.Filter, error) {
@@ -58,9 +243,11 @@ func getConfig() (*circleci.Config, *circleci.Filter, error) {
 	apiURL := os.Getenv("CIRCLECI_API_URL")
 	jobsURL := os.Getenv("CIRCLECI_JOBS_URL")
 	config := &circleci.Config{
-		APIToken: apiToken,
-		APIURL:   apiURL,
-		JobsURL:  jobsURL,
+		APIToken:  apiToken,
+		APIURL:    apiURL,
+		JobsURL:   jobsURL,
+		RecordDir: recordDir(),
+		ReplayDir: os.Getenv("CIRCLECI_REPLAY_DIR"),
 	}
 	filterJson := os.Getenv("DASHBOARD_FILTER")
 	if filterJson == "" {
@@ -74,16 +261,33 @@ func getConfig() (*circleci.Config, *circleci.Filter, error) {
 }
 
 func getRefershInterval() int {
-	refreshInterval := os.Getenv("REFRESH_INTERVAL")
-	if refreshInterval == "" {
-		return 30
+	return getIntervalEnv("REFRESH_INTERVAL", 30)
+}
+
+func getIntervalEnv(name string, defaultInterval int) int {
+	interval := os.Getenv(name)
+	if interval == "" {
+		return defaultInterval
 	}
-	refreshInt, err := strconv.Atoi(refreshInterval)
+	intervalInt, err := strconv.Atoi(interval)
 	if err != nil {
-		fmt.Println("REFRESH_INTERVAL must be an int")
+		fmt.Printf("%s must be an int\n", name)
 		os.Exit(1)
 	}
-	return refreshInt
+	return intervalInt
+}
+
+func getSchedulerConfig() (scheduler.Config, error) {
+	quietHours, err := scheduler.ParseQuietHours(os.Getenv("QUIET_HOURS"), os.Getenv("QUIET_DAYS"))
+	if err != nil {
+		return scheduler.Config{}, fmt.Errorf("Error loading quiet hours: %v", err.Error())
+	}
+	return scheduler.Config{
+		ActiveInterval: time.Duration(getIntervalEnv("ACTIVE_REFRESH_INTERVAL", 5)) * time.Second,
+		IdleInterval:   time.Duration(getRefershInterval()) * time.Second,
+		QuietInterval:  time.Duration(getIntervalEnv("QUIET_REFRESH_INTERVAL", 600)) * time.Second,
+		QuietHours:     quietHours,
+	}, nil
 }
 
 func getDashboardFeatureFlags() *dashboard.FeatureFlags {
@@ -94,7 +298,7 @@ func getDashboardFeatureFlags() *dashboard.FeatureFlags {
 	return &dashboard.FeatureFlags{AnimatedBuildErrors: animateBuildError}
 }
 
-func getMonitorConfig() *dashboard.MonitorConfig {
+func getMonitorConfig() (*dashboard.MonitorConfig, error) {
 	var hideOrg, hideBranch bool
 	if os.Getenv("HIDE_ORGANIZATION") != "" {
 		hideOrg = true
@@ -103,35 +307,466 @@ func getMonitorConfig() *dashboard.MonitorConfig {
 		hideBranch = true
 	}
 	branchFilter := os.Getenv("BRANCH_FILTER")
-	return &dashboard.MonitorConfig{HideOrganization: hideOrg, HideBranch: hideBranch, BranchFilter: branchFilter}
+	var tagPatterns []string
+	for _, pattern := range strings.Split(os.Getenv("TAG_PATTERNS"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			tagPatterns = append(tagPatterns, pattern)
+		}
+	}
+	var owners dashboard.Owners
+	if ownersFile := os.Getenv("OWNERS_FILE"); ownersFile != "" {
+		var err error
+		if owners, err = dashboard.LoadOwners(ownersFile); err != nil {
+			return nil, err
+		}
+	}
+	return &dashboard.MonitorConfig{
+		HideOrganization: hideOrg,
+		HideBranch:       hideBranch,
+		BranchFilter:     branchFilter,
+		TagPatterns:      tagPatterns,
+		SplitByTrigger:   os.Getenv("SPLIT_BY_TRIGGER") == "true",
+		FlakyHistory:     getIntervalEnv("FLAKY_HISTORY", 10),
+		Owners:           owners,
+	}, nil
 }
 
-func main() {
-	refreshInterval := getRefershInterval()
-	dashboardFeatureFlags := getDashboardFeatureFlags()
+func getDoraConfig() (dora.Config, error) {
+	deployments, err := dora.ParseDeployments(os.Getenv("DORA_DEPLOYMENTS"))
+	if err != nil {
+		return dora.Config{}, fmt.Errorf("Error loading DORA deployments: %v", err.Error())
+	}
+	windows := dora.DefaultWindows
+	if doraWindows := os.Getenv("DORA_WINDOWS"); doraWindows != "" {
+		if windows, err = dora.ParseWindows(doraWindows); err != nil {
+			return dora.Config{}, fmt.Errorf("Error loading DORA windows: %v", err.Error())
+		}
+	}
+	return dora.Config{Deployments: deployments, Windows: windows}, nil
+}
+
+func getAuthConfig() (auth.Config, error) {
+	tokens, err := auth.ParseTokens(os.Getenv("AUTH_TOKENS"))
+	if err != nil {
+		return auth.Config{}, fmt.Errorf("Error loading AUTH_TOKENS: %v", err.Error())
+	}
+	users, err := auth.ParseUsers(os.Getenv("AUTH_USERS"))
+	if err != nil {
+		return auth.Config{}, fmt.Errorf("Error loading AUTH_USERS: %v", err.Error())
+	}
+	trustedProxies, err := auth.ParseNetworks(os.Getenv("AUTH_TRUSTED_PROXIES"))
+	if err != nil {
+		return auth.Config{}, fmt.Errorf("Error loading AUTH_TRUSTED_PROXIES: %v", err.Error())
+	}
+	config := auth.Config{
+		Tokens:         tokens,
+		Users:          users,
+		KioskTokens:    auth.ParseList(os.Getenv("AUTH_KIOSK_TOKENS")),
+		TrustedHeader:  os.Getenv("AUTH_TRUSTED_HEADER"),
+		Operators:      auth.ParseList(os.Getenv("AUTH_OPERATORS")),
+		TrustedProxies: trustedProxies,
+	}
+	if err := config.Validate(); err != nil {
+		return auth.Config{}, fmt.Errorf("Error loading AUTH_TRUSTED_HEADER: %v", err.Error())
+	}
+	return config, nil
+}
+
+// newCircleCIClient builds the client from the environment, or from a fake
+// CircleCI in demo mode. The returned function releases the fake.
+func newCircleCIClient(demo bool) (*circleci.Client, *circleci.Filter, func(), error) {
+	closer := func() {}
 	config, filter, err := getConfig()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, nil, closer, err
+	}
+	if demo {
+		demoServer := fake.NewServer(fake.Options{Seed: time.Now().UnixNano(), StepInterval: 5 * time.Second})
+		closer = demoServer.Close
+		config = demoServer.Config()
+		slog.Info("Running in demo mode against a fake CircleCI", "url", demoServer.URL)
 	}
 	circleCIClient, err := circleci.NewClient(config)
+	if err != nil {
+		return nil, nil, closer, err
+	}
+	circleCIClient.Client.SetDisableWarn(demo)
+	return circleCIClient, filter, closer, nil
+}
+
+// configVars are the environment variables hashed into the config hash on
+// /debug/status. The address of each replica is left out, as it differs.
+var configVars = []string{
+	"ACKNOWLEDGEMENTS_FILE", "ACTIVE_REFRESH_INTERVAL", "ANIMATED_BUILD_ERROR",
+	"AUTH_KIOSK_TOKENS", "AUTH_OPERATORS", "AUTH_TOKENS", "AUTH_TRUSTED_HEADER", "AUTH_TRUSTED_PROXIES", "AUTH_USERS",
+	"BRANCH_FILTER", "CIRCLECI_API_URL", "CIRCLECI_JOBS_URL", "CIRCLECI_RECORD_DIR", "CIRCLECI_REPLAY_DIR",
+	"CIRCLECI_SOURCES", "CIRCLECI_TOKEN", "CIRCLECI_WEBHOOK_SECRET", "DASHBOARD_FILTER",
+	"DORA_DEPLOYMENTS", "DORA_REFRESH_INTERVAL", "DORA_WINDOWS", "FLAKY_HISTORY",
+	"HIDE_BRANCH", "HIDE_ORGANIZATION", "HISTORY_FILE", "LEADER_LEASE", "LOG_LEVEL",
+	"NOTIFY_REMIND_INTERVAL", "NOTIFY_WEBHOOK_URL", "OTEL_TRACES_EXPORTER", "OWNERS_FILE", "QUIET_DAYS", "QUIET_HOURS",
+	"QUIET_REFRESH_INTERVAL", "READY_INTERVALS", "REDIS_KEY_PREFIX", "REDIS_URL", "REFRESH_INTERVAL",
+	"SNAPSHOT_FILE", "SPLIT_BY_TRIGGER", "TAG_PATTERNS",
+}
+
+func getConfigHash() string {
+	env := map[string]string{}
+	for _, name := range configVars {
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+	return health.ConfigHash(env)
+}
+
+// getCluster returns where snapshots are kept, how the replica collecting
+// them is elected and the secret the replicas share. Without REDIS_URL the
+// dashboard runs alone, keeping its snapshot in memory and, if set,
+// SNAPSHOT_FILE.
+func getCluster() (dashboard.Snapshots, cluster.Election, []byte, error) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		if snapshotFile := os.Getenv("SNAPSHOT_FILE"); snapshotFile != "" {
+			snapshots, err := dashboard.LoadSnapshotStore(snapshotFile)
+			return snapshots, cluster.Single{}, nil, err
+		}
+		return dashboard.NewSnapshotStore(), cluster.Single{}, nil, nil
+	}
+	client, err := cluster.NewRedisClient(redisURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	prefix := os.Getenv("REDIS_KEY_PREFIX")
+	if prefix == "" {
+		prefix = "circleci-workflow-dashboard"
+	}
+	replicaURL := os.Getenv("REPLICA_URL")
+	if replicaURL == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Error finding the replica's address, set REPLICA_URL: %v", err.Error())
+		}
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		replicaURL = fmt.Sprintf("http://%s:%s", hostname, port)
+	}
+	secret, err := cluster.SharedSecret(client, prefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lease := time.Duration(getIntervalEnv("LEADER_LEASE", 15)) * time.Second
+	return cluster.NewRedisSnapshots(client, prefix), cluster.NewRedisElection(client, prefix, replicaURL, lease), secret, nil
+}
+
+// newSources builds a source for each entry of CIRCLECI_SOURCES, or the single
+// source configured by CIRCLECI_TOKEN and friends when it is not set.
+func newSources(demo bool) ([]dashboard.Source, func(), error) {
+	sourcesJSON := os.Getenv("CIRCLECI_SOURCES")
+	if sourcesJSON == "" || demo {
+		circleCIClient, filter, closer, err := newCircleCIClient(demo)
+		if err != nil {
+			return nil, closer, err
+		}
+		return []dashboard.Source{{
+			Provider: dashboard.NewCircleCIProvider(circleCIClient, filter),
+			Stats:    dashboard.NewAPIStats().Watch(circleCIClient.Client),
+		}}, closer, nil
+	}
+	closer := func() {}
+	sourceConfigs, err := sources.Parse(sourcesJSON)
+	if err != nil {
+		return nil, closer, fmt.Errorf("Error loading CircleCI sources: %v", err.Error())
+	}
+	var dashboardSources []dashboard.Source
+	for _, sourceConfig := range sourceConfigs {
+		source, err := sourceConfig.Source(recordDir(), os.Getenv("CIRCLECI_REPLAY_DIR"))
+		if err != nil {
+			return nil, closer, fmt.Errorf("Error loading source %s: %v", sourceConfig.Name, err.Error())
+		}
+		dashboardSources = append(dashboardSources, source)
+	}
+	return dashboardSources, closer, nil
+}
+
+// doraSources returns the client and filter of each CircleCI source, which
+// DORA metrics are collected from.
+func doraSources(sources []dashboard.Source) []dora.Source {
+	var doraSources []dora.Source
+	for _, source := range sources {
+		if provider, ok := source.Provider.(*dashboard.CircleCIProvider); ok {
+			doraSources = append(doraSources, dora.Source{CircleCIClient: provider.CircleCIClient, Filter: provider.Filter})
+		}
+	}
+	return doraSources
+}
+
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	demo := flags.Bool("demo", false, "Serve the dashboard from a built-in fake CircleCI instead of the real API")
+	flags.Parse(args)
+	logger, err := telemetry.NewLogger(os.Stderr, os.Getenv("LOG_LEVEL"))
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	ticker, cacher := setup(refreshInterval)
-	defer ticker.Stop()
-	go updateDashboard(circleCIClient, filter, ticker, cacher, dashboardFeatureFlags, getMonitorConfig())
-	r := gin.Default()
-	r.LoadHTMLGlob("templates/*.tmpl")
+	slog.SetDefault(logger)
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	dashboardFeatureFlags := getDashboardFeatureFlags()
+	dashboardSources, closer, err := newSources(*demo)
+	defer closer()
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	schedulerConfig, err := getSchedulerConfig()
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	doraConfig, err := getDoraConfig()
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	authConfig, err := getAuthConfig()
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	doraReporter := dora.NewReporter(doraSources(dashboardSources), doraConfig, time.Duration(getIntervalEnv("DORA_REFRESH_INTERVAL", 3600))*time.Second)
+	history := dashboard.NewHistory()
+	if historyFile := os.Getenv("HISTORY_FILE"); historyFile != "" {
+		if history, err = dashboard.LoadHistory(historyFile); err != nil {
+			slog.Error("Error starting the dashboard", "err", err)
+			os.Exit(1)
+		}
+	}
+	acknowledgements := dashboard.NewAcknowledgements()
+	if acknowledgementsFile := os.Getenv("ACKNOWLEDGEMENTS_FILE"); acknowledgementsFile != "" {
+		if acknowledgements, err = dashboard.LoadAcknowledgements(acknowledgementsFile); err != nil {
+			slog.Error("Error starting the dashboard", "err", err)
+			os.Exit(1)
+		}
+	}
+	var notifier *notify.Notifier
+	if webhookURL := os.Getenv("NOTIFY_WEBHOOK_URL"); webhookURL != "" {
+		notifier = notify.New(webhookURL, time.Duration(getIntervalEnv("NOTIFY_REMIND_INTERVAL", 86400))*time.Second)
+	}
+	snapshots, election, forwardSecret, err := getCluster()
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	authConfig.ForwardSecret = forwardSecret
+	monitorConfig, err := getMonitorConfig()
+	if err != nil {
+		slog.Error("Error starting the dashboard", "err", err)
+		os.Exit(1)
+	}
+	refreshScheduler := scheduler.NewForSources(dashboardSources, dashboardFeatureFlags, monitorConfig, schedulerConfig)
+	broker := events.NewBroker()
+	onRefresh := updateDashboard(snapshots, broker, history, acknowledgements, notifier)
+	var recorded sync.Once
+	refreshScheduler.OnRefresh = func(s *scheduler.Scheduler) {
+		onRefresh(s)
+		// A recording holds the first full refresh only, not the branches a
+		// webhook refreshes before it.
+		if s.Refreshed() {
+			recorded.Do(func() { stopRecording(dashboardSources) })
+		}
+	}
+	clusterState := cluster.NewState(election)
+	clusterState.Campaign()
+	refreshScheduler.IsLeader = clusterState.Leading
+	stop := make(chan struct{})
+	defer close(stop)
+	go clusterState.Run(stop, func(leading bool) {
+		if leading {
+			slog.Info("Took the lead, collecting")
+			// The last leader may have saved changes since these were loaded.
+			if err := history.Reload(); err != nil {
+				slog.Error("Error reloading history", "err", err)
+			}
+			if err := acknowledgements.Reload(); err != nil {
+				slog.Error("Error reloading acknowledgements", "err", err)
+			}
+			refreshScheduler.Wake()
+			return
+		}
+		slog.Info("Lost the lead, following", "leader", clusterState.Leader())
+	})
+	go watchSnapshots(snapshots, broker, clusterState, stop)
+	go refreshScheduler.Run(stop)
+	router := gin.New()
+	router.Use(telemetry.GinLogger(logger), gin.Recovery())
+	router.LoadHTMLGlob("templates/*.tmpl")
+	// The webhook is checked against its own secret, and the assets are public.
+	if secret := os.Getenv("CIRCLECI_WEBHOOK_SECRET"); secret != "" {
+		router.POST("/webhooks/circleci", forwardToLeader(clusterState, authConfig), webhook.Handler(secret, refreshScheduler))
+	}
+	router.Static("/assets", "./assets")
+	// The probes come from the orchestrator, which does not sign in.
+	checker := health.NewChecker(snapshots, refreshScheduler, clusterState, getIntervalEnv("READY_INTERVALS", 3), getConfigHash())
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", checker.Readyz)
+	r := router.Group("/", auth.Middleware(authConfig))
 	r.GET("/", func(c *gin.Context) {
-		dashboard, err := getCachedDashboard(cacher, refreshInterval)
+		dashboard, err := getCachedDashboard(snapshots)
 		if err != nil {
 			c.AbortWithError(500, err)
 			return
 		}
+		if team := c.Query("team"); team != "" {
+			dashboard.DashboardMonitors = dashboard.DashboardMonitors.ForTeam(team)
+		}
+		if c.Query("group") == "team" {
+			dashboard.DashboardMonitors = dashboard.DashboardMonitors.GroupByTeam()
+		}
 		c.HTML(200, "dashboard.tmpl", dashboard)
 	})
-	r.Static("/assets", "./assets")
-	r.Run() // listen and serve on 0.0.0.0:8080
+	// History, acknowledgements and DORA reports are kept by the leader, so
+	// the pages that show them are served by it too.
+	r.GET("/monitors/:id", forwardToLeader(clusterState, authConfig), func(c *gin.Context) {
+		dashboard, err := getCachedDashboard(snapshots)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		monitor, found := dashboard.DashboardMonitors.Find(c.Param("id"))
+		if !found {
+			c.String(404, "Monitor not found, it may have been renamed or removed")
+			return
+		}
+		c.HTML(200, "monitor.tmpl", gin.H{
+			"Now":        dashboard.Now,
+			"Monitor":    monitor,
+			"Recovery":   history.Recovery(monitor.ID(), time.Time{}, time.Now()),
+			"CanOperate": auth.CanOperate(c),
+			"User":       auth.UserOf(c),
+		})
+	})
+	r.POST("/monitors/:id/acknowledge", auth.RequireOperator(), auth.SameOrigin(), forwardToLeader(clusterState, authConfig), func(c *gin.Context) {
+		cached, err := getCachedDashboard(snapshots)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		monitor, found := cached.DashboardMonitors.Find(c.Param("id"))
+		if !found {
+			c.String(404, "Monitor not found, it may have been renamed or removed")
+			return
+		}
+		var form acknowledgeForm
+		if err := c.ShouldBind(&form); err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		expires, err := time.ParseDuration(form.Expires)
+		if err != nil {
+			c.String(400, "Expires must be a duration such as 72h")
+			return
+		}
+		// The owner can only be chosen when the request does not name a user.
+		owner := auth.UserOf(c)
+		if owner == "" {
+			owner = form.Owner
+		}
+		now := time.Now()
+		ack := dashboard.Acknowledgement{Note: form.Note, Owner: owner, CreatedAt: now, ExpiresAt: now.Add(expires)}
+		if err := acknowledgements.Acknowledge(monitor, ack); err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		if err := applyAcknowledgements(snapshots, broker, acknowledgements); err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.Redirect(303, "/monitors/"+monitor.ID())
+	})
+	r.POST("/monitors/:id/unacknowledge", auth.RequireOperator(), auth.SameOrigin(), forwardToLeader(clusterState, authConfig), func(c *gin.Context) {
+		cached, err := getCachedDashboard(snapshots)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		monitor, found := cached.DashboardMonitors.Find(c.Param("id"))
+		if !found {
+			c.String(404, "Monitor not found, it may have been renamed or removed")
+			return
+		}
+		if err := acknowledgements.Clear(monitor.ID()); err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		if err := applyAcknowledgements(snapshots, broker, acknowledgements); err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.Redirect(303, "/monitors/"+monitor.ID())
+	})
+	r.GET("/api/history/weekly", forwardToLeader(clusterState, authConfig), func(c *gin.Context) {
+		dashboard, err := getCachedDashboard(snapshots)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, history.WeeklySummary(dashboard.DashboardMonitors, time.Now()))
+	})
+	r.GET("/flaky", func(c *gin.Context) {
+		dashboard, err := getCachedDashboard(snapshots)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.HTML(200, "flaky.tmpl", gin.H{"Now": dashboard.Now, "Monitors": dashboard.DashboardMonitors.MostFlaky()})
+	})
+	r.GET("/dora", forwardToLeader(clusterState, authConfig), func(c *gin.Context) {
+		if !doraReporter.Configured() {
+			c.HTML(200, "dora.tmpl", gin.H{})
+			return
+		}
+		report, err := doraReporter.Report()
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.HTML(200, "dora.tmpl", gin.H{"Configured": true, "Report": report})
+	})
+	r.GET("/api/dora", forwardToLeader(clusterState, authConfig), func(c *gin.Context) {
+		report, err := doraReporter.Report()
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, report)
+	})
+	r.GET("/events", streamEvents(broker))
+	r.GET("/debug/status", checker.DebugStatus)
+	if err := router.Run(); err != nil { // listen and serve on 0.0.0.0:8080
+		slog.Error("Error serving the dashboard", "err", err)
+		os.Exit(1)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "status":
+			os.Exit(status(os.Args[2:]))
+		case "watch":
+			os.Exit(watch(os.Args[2:]))
+		case "envvars":
+			os.Exit(envVarsCommand(os.Args[2:]))
+		}
+	}
+	serve(os.Args[1:])
 }
@@ -91,6 +91,29 @@ func (_m *CircleCI) GetAllProjects() (circleci.Projects, error) {
 	return r0, r1
 }
 
+// GetJobTests provides a mock function with given fields: _a0, _a1
+func (_m *CircleCI) GetJobTests(_a0 string, _a1 int) (circleci.TestResults, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 circleci.TestResults
+	if rf, ok := ret.Get(0).(func(string, int) circleci.TestResults); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(circleci.TestResults)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetJobsForWorkflow provides a mock function with given fields: _a0
 func (_m *CircleCI) GetJobsForWorkflow(_a0 circleci.Workflow) (circleci.Jobs, error) {
 	ret := _m.Called(_a0)
@@ -137,6 +160,29 @@ func (_m *CircleCI) GetProjectEnvVars(_a0 string) (circleci.ProjectEnvVars, erro
 	return r0, r1
 }
 
+// GetTagPipelines provides a mock function with given fields: _a0
+func (_m *CircleCI) GetTagPipelines(_a0 circleci.Project) (circleci.Pipelines, error) {
+	ret := _m.Called(_a0)
+
+	var r0 circleci.Pipelines
+	if rf, ok := ret.Get(0).(func(circleci.Project) circleci.Pipelines); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(circleci.Pipelines)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(circleci.Project) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetWorkflowsForPipeline provides a mock function with given fields: _a0
 func (_m *CircleCI) GetWorkflowsForPipeline(_a0 circleci.Pipeline) (circleci.Workflows, error) {
 	ret := _m.Called(_a0)
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+// Notifier posts to a Slack compatible incoming webhook when a monitor goes
+// red, and reminds every RemindInterval while it stays red. Acknowledged
+// monitors are left alone.
+type Notifier struct {
+	URL            string
+	RemindInterval time.Duration
+	Client         *http.Client
+
+	mu       sync.Mutex
+	seeded   bool
+	notified map[string]time.Time
+}
+
+func New(url string, remindInterval time.Duration) *Notifier {
+	return &Notifier{
+		URL:            url,
+		RemindInterval: remindInterval,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		notified:       map[string]time.Time{},
+	}
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+// notification is a message due about a monitor.
+type notification struct {
+	id   string
+	text string
+}
+
+// Notify sends whatever notifications are due for the monitors. The first
+// call only notes which monitors are already red, so that a restart does not
+// repeat every notification. A monitor only counts as notified once the
+// webhook has accepted its message, so that a failed one is tried again on the
+// next call.
+func (n *Notifier) Notify(monitors dashboard.Monitors, now time.Time) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	seen := map[string]bool{}
+	var due []notification
+	for _, monitor := range monitors {
+		id := monitor.ID()
+		if !monitor.Failed() {
+			continue
+		}
+		seen[id] = true
+		if monitor.Acknowledgement != nil {
+			continue
+		}
+		last, notified := n.notified[id]
+		switch {
+		case !n.seeded:
+			n.notified[id] = now
+		case !notified:
+			due = append(due, notification{id, fmt.Sprintf("%s went red: %s%s", describe(monitor), monitor.Link, mention(monitor))})
+		case n.RemindInterval > 0 && now.Sub(last) >= n.RemindInterval:
+			due = append(due, notification{id, fmt.Sprintf("%s is still red, %s: %s%s", describe(monitor), monitor.RedFor(), monitor.Link, mention(monitor))})
+		}
+	}
+	for id := range n.notified {
+		if !seen[id] {
+			delete(n.notified, id)
+		}
+	}
+	n.seeded = true
+	var errs []error
+	for _, notification := range due {
+		if err := n.post(notification.text); err != nil {
+			slog.Warn("Error sending notification", "monitor", notification.id, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		n.notified[notification.id] = now
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) post(text string) error {
+	body, err := json.Marshal(message{Text: text})
+	if err != nil {
+		return err
+	}
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("Notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mention calls on the owning team by its contact handle, or by name if it
+// has none.
+func mention(monitor dashboard.Monitor) string {
+	switch {
+	case monitor.Owner == nil:
+		return ""
+	case monitor.Owner.Contact != "":
+		return fmt.Sprintf(" cc %s", monitor.Owner.Contact)
+	default:
+		return fmt.Sprintf(" cc %s", monitor.Owner.Team)
+	}
+}
+
+func describe(monitor dashboard.Monitor) string {
+	ref := monitor.Branch
+	if monitor.Tag != "" {
+		ref = monitor.Tag
+	}
+	if ref == "" {
+		return fmt.Sprintf("%s %s", monitor.Name, monitor.Workflow)
+	}
+	return fmt.Sprintf("%s %s (%s)", monitor.Name, monitor.Workflow, ref)
+}
@@ -0,0 +1,107 @@
+package notify_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/notify"
+)
+
+var _ = Describe("Notifier", func() {
+	var (
+		now      = time.Date(2020, 9, 1, 9, 0, 0, 0, time.UTC)
+		green    = dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "success", Link: "https://foobar.com"}
+		red      = dashboard.Monitor{Name: "foobar/example", Workflow: "build", Branch: "master", Status: "failed", Link: "https://foobar.com"}
+		server   *httptest.Server
+		status   int
+		mu       sync.Mutex
+		messages []string
+		notifier *notify.Notifier
+	)
+
+	BeforeEach(func() {
+		messages = nil
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Text string `json:"text"`
+			}
+			Ω(json.NewDecoder(r.Body).Decode(&body)).Should(Succeed())
+			mu.Lock()
+			messages = append(messages, body.Text)
+			mu.Unlock()
+			w.WriteHeader(status)
+		}))
+		notifier = notify.New(server.URL, 24*time.Hour)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("does not repeat notifications for monitors already red on startup", func() {
+		Ω(notifier.Notify(dashboard.Monitors{red}, now)).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(time.Hour))).Should(Succeed())
+		Ω(messages).Should(BeEmpty())
+	})
+
+	It("notifies when a monitor goes red and reminds while it stays red", func() {
+		Ω(notifier.Notify(dashboard.Monitors{green}, now)).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(time.Hour))).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(2*time.Hour))).Should(Succeed())
+		Ω(messages).Should(Equal([]string{"foobar/example build (master) went red: https://foobar.com"}))
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(25*time.Hour))).Should(Succeed())
+		Ω(messages).Should(HaveLen(2))
+		Ω(messages[1]).Should(HavePrefix("foobar/example build (master) is still red"))
+	})
+
+	It("mentions the owning team", func() {
+		owned := red
+		owned.Owner = &dashboard.Owner{Team: "payments", Contact: "@payments-oncall"}
+		Ω(notifier.Notify(dashboard.Monitors{green}, now)).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{owned}, now.Add(time.Hour))).Should(Succeed())
+		Ω(messages).Should(Equal([]string{"foobar/example build (master) went red: https://foobar.com cc @payments-oncall"}))
+	})
+
+	It("notifies again after a monitor recovers and breaks again", func() {
+		Ω(notifier.Notify(dashboard.Monitors{green}, now)).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(time.Hour))).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{green}, now.Add(2*time.Hour))).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(3*time.Hour))).Should(Succeed())
+		Ω(messages).Should(HaveLen(2))
+	})
+
+	It("does not notify about acknowledged monitors", func() {
+		acknowledged := red
+		acknowledged.Acknowledgement = &dashboard.Acknowledgement{Note: "On it", Owner: "platform"}
+		Ω(notifier.Notify(dashboard.Monitors{green}, now)).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{acknowledged}, now.Add(time.Hour))).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{acknowledged}, now.Add(48*time.Hour))).Should(Succeed())
+		Ω(messages).Should(BeEmpty())
+	})
+
+	It("returns an error when the webhook fails", func() {
+		status = http.StatusInternalServerError
+		Ω(notifier.Notify(dashboard.Monitors{green}, now)).Should(Succeed())
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(time.Hour))).Should(MatchError("Notification webhook returned 500 Internal Server Error"))
+	})
+
+	It("tries again after the webhook fails, rather than counting the monitor as notified", func() {
+		Ω(notifier.Notify(dashboard.Monitors{green}, now)).Should(Succeed())
+		status = http.StatusInternalServerError
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(time.Hour))).ShouldNot(Succeed())
+		status = http.StatusOK
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(2*time.Hour))).Should(Succeed())
+		Ω(messages).Should(HaveLen(2))
+		Ω(messages[1]).Should(HavePrefix("foobar/example build (master) went red"))
+		Ω(notifier.Notify(dashboard.Monitors{red}, now.Add(3*time.Hour))).Should(Succeed())
+		Ω(messages).Should(HaveLen(2))
+	})
+})
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// QuietHours describes when nobody is watching the dashboard, e.g. nights
+// between 19:00 and 07:00 and all day on weekends.
+type QuietHours struct {
+	Start int
+	End   int
+	Days  map[time.Weekday]bool
+}
+
+// ParseQuietHours parses a "HH:MM-HH:MM" range and a comma separated list of
+// days such as "Sat,Sun". Either may be empty.
+func ParseQuietHours(hours, days string) (QuietHours, error) {
+	quietHours := QuietHours{Start: -1, End: -1, Days: map[time.Weekday]bool{}}
+	if hours != "" {
+		parts := strings.Split(hours, "-")
+		if len(parts) != 2 {
+			return QuietHours{}, fmt.Errorf("Quiet hours must be in the format HH:MM-HH:MM")
+		}
+		start, err := parseClock(parts[0])
+		if err != nil {
+			return QuietHours{}, err
+		}
+		end, err := parseClock(parts[1])
+		if err != nil {
+			return QuietHours{}, err
+		}
+		quietHours.Start = start
+		quietHours.End = end
+	}
+	for _, day := range strings.Split(days, ",") {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if day == "" {
+			continue
+		}
+		if len(day) > 3 {
+			day = day[:3]
+		}
+		weekday, ok := weekdays[day]
+		if !ok {
+			return QuietHours{}, fmt.Errorf("Unknown quiet day: %s", day)
+		}
+		quietHours.Days[weekday] = true
+	}
+	return quietHours, nil
+}
+
+func parseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid quiet hours time %q, expected HH:MM", clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// Contains reports whether t falls within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	if q.Days[t.Weekday()] {
+		return true
+	}
+	if q.Start < 0 || q.Start == q.End {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if q.Start < q.End {
+		return minute >= q.Start && minute < q.End
+	}
+	return minute >= q.Start || minute < q.End
+}
@@ -0,0 +1,94 @@
+package scheduler_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/scheduler"
+)
+
+var _ = Describe("QuietHours", func() {
+	Describe("#ParseQuietHours", func() {
+		Context("when the hours are malformed", func() {
+			It("returns an error", func() {
+				_, err := scheduler.ParseQuietHours("19:00", "")
+				Ω(err).Should(MatchError("Quiet hours must be in the format HH:MM-HH:MM"))
+			})
+		})
+
+		Context("when a time is invalid", func() {
+			It("returns an error", func() {
+				_, err := scheduler.ParseQuietHours("25:00-07:00", "")
+				Ω(err).Should(MatchError(`Invalid quiet hours time "25:00", expected HH:MM`))
+			})
+		})
+
+		Context("when a day is unknown", func() {
+			It("returns an error", func() {
+				_, err := scheduler.ParseQuietHours("", "Sat,Funday")
+				Ω(err).Should(MatchError("Unknown quiet day: fun"))
+			})
+		})
+	})
+
+	Describe("#Contains", func() {
+		var (
+			quietHours scheduler.QuietHours
+			err        error
+			// 2020-09-04 was a Friday
+			friday = func(hour, minute int) time.Time {
+				return time.Date(2020, 9, 4, hour, minute, 0, 0, time.UTC)
+			}
+		)
+
+		Context("when nothing is configured", func() {
+			BeforeEach(func() {
+				quietHours, err = scheduler.ParseQuietHours("", "")
+				Ω(err).Should(BeNil())
+			})
+
+			It("is never quiet", func() {
+				Ω(quietHours.Contains(friday(3, 0))).Should(BeFalse())
+				Ω(quietHours.Contains(friday(12, 0))).Should(BeFalse())
+			})
+		})
+
+		Context("when the hours wrap past midnight", func() {
+			BeforeEach(func() {
+				quietHours, err = scheduler.ParseQuietHours("19:00-07:00", "Sat,Sunday")
+				Ω(err).Should(BeNil())
+			})
+
+			It("is quiet overnight", func() {
+				Ω(quietHours.Contains(friday(19, 0))).Should(BeTrue())
+				Ω(quietHours.Contains(friday(6, 59))).Should(BeTrue())
+			})
+
+			It("is not quiet during the day", func() {
+				Ω(quietHours.Contains(friday(7, 0))).Should(BeFalse())
+				Ω(quietHours.Contains(friday(18, 59))).Should(BeFalse())
+			})
+
+			It("is quiet all day on quiet days", func() {
+				Ω(quietHours.Contains(friday(12, 0).AddDate(0, 0, 1))).Should(BeTrue())
+				Ω(quietHours.Contains(friday(12, 0).AddDate(0, 0, 2))).Should(BeTrue())
+			})
+		})
+
+		Context("when the hours are within a day", func() {
+			BeforeEach(func() {
+				quietHours, err = scheduler.ParseQuietHours("12:00-13:30", "")
+				Ω(err).Should(BeNil())
+			})
+
+			It("is only quiet between them", func() {
+				Ω(quietHours.Contains(friday(11, 59))).Should(BeFalse())
+				Ω(quietHours.Contains(friday(12, 0))).Should(BeTrue())
+				Ω(quietHours.Contains(friday(13, 29))).Should(BeTrue())
+				Ω(quietHours.Contains(friday(13, 30))).Should(BeFalse())
+			})
+		})
+	})
+})
@@ -0,0 +1,479 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
+)
+
+const minimumWait = time.Second
+
+// followerWait is how often a scheduler that is not leading checks whether it
+// has taken the lead.
+const followerWait = 5 * time.Second
+
+type Config struct {
+	ActiveInterval time.Duration
+	IdleInterval   time.Duration
+	QuietInterval  time.Duration
+	QuietHours     QuietHours
+}
+
+type projectState struct {
+	source      int
+	project     dashboard.Project
+	monitors    dashboard.Monitors
+	err         error
+	nextRefresh time.Time
+	refreshedAt time.Time
+	duration    time.Duration
+	// refreshing serialises the refreshes of the project, so that a
+	// webhook's refresh of one branch and a scheduled refresh of the whole
+	// project do not overwrite each other's monitors.
+	refreshing sync.Mutex
+}
+
+// Scheduler refreshes each project on its own timer. Projects with running or
+// on hold workflows are polled on the active interval, settled projects on the
+// idle interval, and settled projects during quiet hours on the quiet interval.
+//
+// Projects can come from several sources, whose errors are kept apart so that
+// one failing source does not hide the monitors of the others.
+type Scheduler struct {
+	Sources       []dashboard.Source
+	FeatureFlags  *dashboard.FeatureFlags
+	MonitorConfig *dashboard.MonitorConfig
+	Config        Config
+	OnRefresh     func(*Scheduler)
+	Now           func() time.Time
+	// IsLeader, if set, says whether this replica of the dashboard is the
+	// one that collects. Run does not refresh anything while it is not.
+	IsLeader func() bool
+
+	mu                  sync.Mutex
+	projects            map[string]*projectState
+	projectsErrs        []error
+	nextProjectsRefresh time.Time
+	lastRefresh         time.Time
+	lastRefreshDuration time.Duration
+	refreshed           bool
+	wake                chan struct{}
+}
+
+// New schedules the projects of a single source.
+func New(circleCIClient circleci.CircleCI, filter *circleci.Filter, featureFlags *dashboard.FeatureFlags, monitorConfig *dashboard.MonitorConfig, config Config) *Scheduler {
+	return NewForSources([]dashboard.Source{{Provider: dashboard.NewCircleCIProvider(circleCIClient, filter)}}, featureFlags, monitorConfig, config)
+}
+
+func NewForSources(sources []dashboard.Source, featureFlags *dashboard.FeatureFlags, monitorConfig *dashboard.MonitorConfig, config Config) *Scheduler {
+	return &Scheduler{
+		Sources:       sources,
+		FeatureFlags:  featureFlags,
+		MonitorConfig: monitorConfig,
+		Config:        config,
+		Now:           time.Now,
+		projects:      map[string]*projectState{},
+		projectsErrs:  make([]error, len(sources)),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// projectKey tells apart projects with the same slug in different sources.
+func projectKey(source int, slug string) string {
+	return fmt.Sprintf("%d/%s", source, slug)
+}
+
+// Run refreshes whatever is due, then sleeps until the next refresh is due or
+// Wake is called, until stop is closed.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	for {
+		wait := followerWait
+		if s.IsLeader == nil || s.IsLeader() {
+			s.Refresh()
+			wait = s.NextRefresh().Sub(s.Now())
+		}
+		if wait < minimumWait {
+			wait = minimumWait
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Refreshed reports whether a full Refresh has finished, whether or not it
+// succeeded. Until then Monitors only holds the projects refreshed so far.
+func (s *Scheduler) Refreshed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshed
+}
+
+// Wake interrupts Run so that anything newly due is refreshed straight away.
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Refresh reloads the project list and every project whose refresh is due.
+func (s *Scheduler) Refresh() {
+	ctx, span := telemetry.Tracer().Start(context.Background(), "refresh")
+	now := s.Now()
+	started := time.Now()
+	if !now.Before(s.nextProjectsRefresh) {
+		s.refreshProjects(ctx, now)
+	}
+	due := s.dueProjects(now)
+	for _, slug := range due {
+		s.refreshProject(ctx, slug, now)
+	}
+	duration := time.Since(started)
+	s.mu.Lock()
+	s.lastRefresh = now
+	s.lastRefreshDuration = duration
+	s.refreshed = true
+	s.mu.Unlock()
+	span.SetAttributes(attribute.Int("projects", len(due)))
+	span.End()
+	slog.Debug("Refreshed", "projects", len(due), "duration", duration)
+	if s.OnRefresh != nil {
+		s.OnRefresh(s)
+	}
+}
+
+func (s *Scheduler) refreshProjects(ctx context.Context, now time.Time) {
+	for i := range s.Sources {
+		s.refreshSourceProjects(ctx, i, now)
+	}
+	s.mu.Lock()
+	s.nextProjectsRefresh = now.Add(s.settledInterval(now))
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) refreshSourceProjects(ctx context.Context, source int, now time.Time) {
+	ctx, span := telemetry.Tracer().Start(ctx, "list projects", trace.WithAttributes(attribute.String("source", s.Sources[source].Name)))
+	projects, err := dashboard.ProviderWithContext(ctx, s.Sources[source].Provider).Projects()
+	telemetry.End(span, err)
+	if err != nil {
+		slog.Warn("Error listing projects", "source", s.Sources[source].Name, "err", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.projectsErrs[source] = err
+	if err != nil {
+		return
+	}
+	current := map[string]*projectState{}
+	for key, state := range s.projects {
+		if state.source != source {
+			current[key] = state
+		}
+	}
+	for _, project := range projects {
+		key := projectKey(source, project.Slug)
+		state, ok := s.projects[key]
+		if !ok {
+			state = &projectState{source: source, nextRefresh: now}
+		}
+		state.project = project
+		current[key] = state
+	}
+	s.projects = current
+}
+
+func (s *Scheduler) dueProjects(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []string
+	for slug, state := range s.projects {
+		if !now.Before(state.nextRefresh) {
+			due = append(due, slug)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+func (s *Scheduler) refreshProject(ctx context.Context, key string, now time.Time) {
+	s.mu.Lock()
+	state, ok := s.projects[key]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	state.refreshing.Lock()
+	defer state.refreshing.Unlock()
+	started := time.Now()
+	monitors, err := s.buildProject(ctx, state.source, state.project)
+	duration := time.Since(started)
+	logger := slog.With("source", s.Sources[state.source].Name, "project", state.project.Slug, "duration", duration)
+	if err != nil {
+		logger.Warn("Error collecting project", "err", err)
+	} else {
+		logger.Debug("Collected project")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state.refreshedAt = now
+	state.duration = duration
+	state.err = err
+	if err == nil {
+		state.monitors = monitors
+	}
+	state.nextRefresh = now.Add(s.intervalFor(state.monitors, now))
+}
+
+func (s *Scheduler) buildProject(ctx context.Context, source int, project dashboard.Project) (dashboard.Monitors, error) {
+	monitors, err := dashboard.BuildProviderProject(ctx, s.Sources[source].Provider, project, s.FeatureFlags, s.MonitorConfig)
+	return monitors.SetSource(s.Sources[source].Name), err
+}
+
+// keysFor returns the keys of the projects with the slug, in any source.
+func (s *Scheduler) keysFor(slug string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for i := range s.Sources {
+		if _, ok := s.projects[projectKey(i, slug)]; ok {
+			keys = append(keys, projectKey(i, slug))
+		}
+	}
+	return keys
+}
+
+// RefreshProject marks a project as due so the next Refresh picks it up, and
+// wakes Run. It returns false if the project is not on the dashboard.
+func (s *Scheduler) RefreshProject(slug string) bool {
+	keys := s.keysFor(slug)
+	s.mu.Lock()
+	for _, key := range keys {
+		s.projects[key].nextRefresh = time.Time{}
+	}
+	s.mu.Unlock()
+	if len(keys) > 0 {
+		s.Wake()
+	}
+	return len(keys) > 0
+}
+
+// RefreshBranch immediately rebuilds the monitors for a single branch of a
+// project, e.g. when a webhook reports that one of its workflows finished. It
+// returns false if the project is not on the dashboard. A webhook does not say
+// which source it came from, so a project with the slug in each is refreshed.
+func (s *Scheduler) RefreshBranch(slug, branch string) (bool, error) {
+	keys := s.keysFor(slug)
+	if len(keys) == 0 {
+		return false, nil
+	}
+	if branch == "" || s.MonitorConfig.HideBranch {
+		return s.RefreshProject(slug), nil
+	}
+	if s.MonitorConfig.BranchFilter != "" && s.MonitorConfig.BranchFilter != branch {
+		return true, nil
+	}
+	ctx, span := telemetry.Tracer().Start(context.Background(), "refresh branch", trace.WithAttributes(
+		attribute.String("project", slug),
+		attribute.String("branch", branch),
+	))
+	var err error
+	for _, key := range keys {
+		if err = s.refreshBranch(ctx, key, branch); err != nil {
+			break
+		}
+	}
+	telemetry.End(span, err)
+	// An error is shown on the dashboard like that of a scheduled refresh.
+	if s.OnRefresh != nil {
+		s.OnRefresh(s)
+	}
+	s.Wake()
+	return true, err
+}
+
+func (s *Scheduler) refreshBranch(ctx context.Context, key, branch string) error {
+	s.mu.Lock()
+	state := s.projects[key]
+	s.mu.Unlock()
+	state.refreshing.Lock()
+	defer state.refreshing.Unlock()
+	project := state.project
+	project.Branches = []string{branch}
+	monitors, err := s.buildProject(ctx, state.source, project)
+	now := s.Now()
+	if err != nil {
+		// The error stands until a refresh of the whole project, which is
+		// due straight away.
+		s.mu.Lock()
+		state.err = err
+		state.nextRefresh = now
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Lock()
+	var kept dashboard.Monitors
+	for _, monitor := range state.monitors {
+		// Tag monitors are rebuilt along with the branch.
+		if monitor.Branch != branch && monitor.Tag == "" {
+			kept = append(kept, monitor)
+		}
+	}
+	state.monitors = kept.Merge(monitors)
+	if next := now.Add(s.intervalFor(state.monitors, now)); next.Before(state.nextRefresh) {
+		state.nextRefresh = next
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// SettledInterval is the interval at which settled projects are refreshed at
+// the time.
+func (s *Scheduler) SettledInterval(at time.Time) time.Duration {
+	return s.settledInterval(at)
+}
+
+func (s *Scheduler) settledInterval(now time.Time) time.Duration {
+	if s.Config.QuietInterval > 0 && s.Config.QuietHours.Contains(now) {
+		return s.Config.QuietInterval
+	}
+	return s.Config.IdleInterval
+}
+
+func (s *Scheduler) intervalFor(monitors dashboard.Monitors, now time.Time) time.Duration {
+	if monitors.Active() && s.Config.ActiveInterval > 0 {
+		return s.Config.ActiveInterval
+	}
+	return s.settledInterval(now)
+}
+
+// NextRefresh is the earliest time at which any project is due a refresh.
+func (s *Scheduler) NextRefresh() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.nextProjectsRefresh
+	for _, state := range s.projects {
+		if state.nextRefresh.Before(next) {
+			next = state.nextRefresh
+		}
+	}
+	return next
+}
+
+// Monitors returns the latest monitors of every project, sorted.
+func (s *Scheduler) Monitors() dashboard.Monitors {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var slugs []string
+	for slug := range s.projects {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	var monitors dashboard.Monitors
+	for _, slug := range slugs {
+		monitors = monitors.Merge(s.projects[slug].monitors)
+	}
+	monitors.Sort()
+	return monitors
+}
+
+// Err returns the error of the first source when every source is failing, in
+// which case there is nothing to show. A source is failing when loading its
+// project list failed, or the last refresh of any of its projects did.
+func (s *Scheduler) Err() error {
+	errs := s.SourceErrors()
+	if len(errs) == 0 || len(errs) < len(s.Sources) {
+		return nil
+	}
+	return errs[0].Err
+}
+
+// SourceErrors returns the errors of the failing sources.
+func (s *Scheduler) SourceErrors() dashboard.SourceErrors {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.projects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs dashboard.SourceErrors
+	for i, source := range s.Sources {
+		err := s.projectsErrs[i]
+		for _, key := range keys {
+			if state := s.projects[key]; err == nil && state.source == i {
+				err = state.err
+			}
+		}
+		if err != nil {
+			errs = append(errs, dashboard.SourceError{Source: source.Name, Err: err})
+		}
+	}
+	return errs
+}
+
+// ProjectStatus is how the last refresh of a project went.
+type ProjectStatus struct {
+	Source      string
+	Slug        string
+	RefreshedAt time.Time
+	Duration    time.Duration
+	NextRefresh time.Time
+	Err         error
+}
+
+// SourceStatus is how the last load of a source's project list went.
+type SourceStatus struct {
+	Source dashboard.Source
+	Err    error
+}
+
+// Status reports how collection is going, for diagnostics.
+type Status struct {
+	LastRefresh         time.Time
+	LastRefreshDuration time.Duration
+	Sources             []SourceStatus
+	Projects            []ProjectStatus
+}
+
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{LastRefresh: s.lastRefresh, LastRefreshDuration: s.lastRefreshDuration}
+	for i, source := range s.Sources {
+		status.Sources = append(status.Sources, SourceStatus{Source: source, Err: s.projectsErrs[i]})
+	}
+	var keys []string
+	for key := range s.projects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		state := s.projects[key]
+		status.Projects = append(status.Projects, ProjectStatus{
+			Source:      s.Sources[state.source].Name,
+			Slug:        state.project.Slug,
+			RefreshedAt: state.refreshedAt,
+			Duration:    state.duration,
+			NextRefresh: state.nextRefresh,
+			Err:         state.err,
+		})
+	}
+	return status
+}
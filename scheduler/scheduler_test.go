@@ -0,0 +1,278 @@
+package scheduler_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/mocks"
+	"github.com/armakuni/circleci-workflow-dashboard/scheduler"
+)
+
+var _ = Describe("Scheduler", func() {
+	var (
+		circleCIClient *mocks.CircleCI
+		refresher      *scheduler.Scheduler
+		now            time.Time
+		quietHours     scheduler.QuietHours
+		project        = circleci.Project{
+			VCSType:  "github",
+			Username: "foobar",
+			Reponame: "example",
+			Branches: map[string]interface{}{
+				"master": nil,
+			},
+		}
+		pipeline = circleci.Pipeline{
+			ID:  "1",
+			VCS: circleci.VCS{Branch: "master"},
+		}
+		workflows = circleci.Workflows{
+			{
+				ID:   "1",
+				Name: "test-workflow",
+			},
+		}
+		filter = circleci.Filter{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		// 2020-09-04 was a Friday
+		now = time.Date(2020, 9, 4, 12, 0, 0, 0, time.UTC)
+		quietHours, err = scheduler.ParseQuietHours("19:00-07:00", "")
+		Ω(err).Should(BeNil())
+		circleCIClient = &mocks.CircleCI{}
+		circleCIClient.On("GetAllProjects").Return(circleci.Projects{project}, nil)
+		circleCIClient.On("GetAllPipelines", project).Return(circleci.Pipelines{pipeline}, nil)
+		circleCIClient.On("GetWorkflowsForPipeline", pipeline).Return(workflows, nil)
+		circleCIClient.On("WorkflowLink", mock.Anything, mock.Anything, mock.Anything).Return("https://foobar.com")
+	})
+
+	JustBeforeEach(func() {
+		refresher = scheduler.New(circleCIClient, &filter, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{}, scheduler.Config{
+			ActiveInterval: 5 * time.Second,
+			IdleInterval:   30 * time.Second,
+			QuietInterval:  10 * time.Minute,
+			QuietHours:     quietHours,
+		})
+		refresher.Now = func() time.Time { return now }
+	})
+
+	Context("when a workflow is running", func() {
+		BeforeEach(func() {
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("running success", nil)
+		})
+
+		It("polls the project on the active interval", func() {
+			refresher.Refresh()
+			Ω(refresher.Err()).Should(BeNil())
+			Ω(refresher.Monitors()).Should(HaveLen(1))
+			Ω(refresher.NextRefresh()).Should(Equal(now.Add(5 * time.Second)))
+		})
+
+		It("keeps polling on the active interval during quiet hours", func() {
+			now = now.Add(8 * time.Hour)
+			refresher.Refresh()
+			Ω(refresher.NextRefresh()).Should(Equal(now.Add(5 * time.Second)))
+		})
+	})
+
+	Context("when every workflow has settled", func() {
+		BeforeEach(func() {
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+		})
+
+		It("polls the project on the idle interval", func() {
+			refresher.Refresh()
+			Ω(refresher.NextRefresh()).Should(Equal(now.Add(30 * time.Second)))
+		})
+
+		It("polls the project on the quiet interval during quiet hours", func() {
+			now = now.Add(8 * time.Hour)
+			refresher.Refresh()
+			Ω(refresher.NextRefresh()).Should(Equal(now.Add(10 * time.Minute)))
+		})
+
+		It("only refreshes projects that are due", func() {
+			refresher.Refresh()
+			now = now.Add(10 * time.Second)
+			refresher.Refresh()
+			circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetAllPipelines", 1)
+			now = now.Add(20 * time.Second)
+			refresher.Refresh()
+			circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetAllPipelines", 2)
+		})
+
+		It("refreshes a project early when asked to", func() {
+			refresher.Refresh()
+			Ω(refresher.RefreshProject(project.Slug())).Should(BeTrue())
+			Ω(refresher.RefreshProject("github/foobar/missing")).Should(BeFalse())
+			refresher.Refresh()
+			circleCIClient.AssertNumberOfCalls(GinkgoT(), "GetAllPipelines", 2)
+		})
+	})
+
+	Describe("#RefreshBranch", func() {
+		var developPipeline = circleci.Pipeline{
+			ID:  "2",
+			VCS: circleci.VCS{Branch: "develop"},
+		}
+
+		BeforeEach(func() {
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+			developProject := project
+			developProject.Branches = map[string]interface{}{"develop": nil}
+			circleCIClient.On("GetAllPipelines", developProject).Return(circleci.Pipelines{developPipeline}, nil)
+			circleCIClient.On("GetWorkflowsForPipeline", developPipeline).Return(workflows, nil)
+		})
+
+		It("adds the monitors for the branch and keeps the others", func() {
+			refresher.Refresh()
+			var refreshed int
+			refresher.OnRefresh = func(*scheduler.Scheduler) { refreshed++ }
+			found, err := refresher.RefreshBranch(project.Slug(), "develop")
+			Ω(err).Should(BeNil())
+			Ω(found).Should(BeTrue())
+			Ω(refreshed).Should(Equal(1))
+			monitors := refresher.Monitors()
+			Ω(monitors).Should(HaveLen(2))
+			Ω(monitors[0].Branch).Should(Equal("develop"))
+			Ω(monitors[1].Branch).Should(Equal("master"))
+		})
+
+		It("records a failed refresh as the project's error", func() {
+			refresher.Refresh()
+			circleCIClient.ExpectedCalls = nil
+			developProject := project
+			developProject.Branches = map[string]interface{}{"develop": nil}
+			circleCIClient.On("GetAllPipelines", developProject).Return(nil, fmt.Errorf("Error getting pipelines"))
+			var refreshed int
+			refresher.OnRefresh = func(*scheduler.Scheduler) { refreshed++ }
+			_, err := refresher.RefreshBranch(project.Slug(), "develop")
+			Ω(err).Should(MatchError("Error getting pipelines"))
+			Ω(refreshed).Should(Equal(1))
+			Ω(refresher.Err()).Should(MatchError("Error getting pipelines"))
+			Ω(refresher.NextRefresh()).Should(Equal(now))
+		})
+
+		It("ignores projects that are not on the dashboard", func() {
+			refresher.Refresh()
+			found, err := refresher.RefreshBranch("github/foobar/missing", "develop")
+			Ω(err).Should(BeNil())
+			Ω(found).Should(BeFalse())
+		})
+	})
+
+	Context("when refreshing a project errors", func() {
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("GetAllProjects").Return(circleci.Projects{project}, nil)
+			circleCIClient.On("GetAllPipelines", project).Return(nil, fmt.Errorf("Error getting pipelines"))
+		})
+
+		It("reports the error", func() {
+			refresher.Refresh()
+			Ω(refresher.Err()).Should(MatchError("Error getting pipelines"))
+			Ω(refresher.Monitors()).Should(BeEmpty())
+		})
+	})
+
+	Context("when getting projects errors", func() {
+		BeforeEach(func() {
+			circleCIClient = &mocks.CircleCI{}
+			circleCIClient.On("GetAllProjects").Return(nil, fmt.Errorf("Error getting projects"))
+		})
+
+		It("reports the error", func() {
+			refresher.Refresh()
+			Ω(refresher.Err()).Should(MatchError("Error getting projects"))
+		})
+	})
+
+	It("calls OnRefresh after every refresh", func() {
+		circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+		var refreshed int
+		refresher.OnRefresh = func(*scheduler.Scheduler) { refreshed++ }
+		refresher.Refresh()
+		refresher.Refresh()
+		Ω(refreshed).Should(Equal(2))
+	})
+
+	It("is refreshed once a full refresh has finished", func() {
+		circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+		Ω(refresher.Refreshed()).Should(BeFalse())
+		refresher.Refresh()
+		Ω(refresher.Refreshed()).Should(BeTrue())
+	})
+
+	It("reports the status of each project", func() {
+		circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+		refresher.Refresh()
+		status := refresher.Status()
+		Ω(status.LastRefresh).Should(Equal(now))
+		Ω(status.Sources).Should(HaveLen(1))
+		Ω(status.Sources[0].Err).Should(BeNil())
+		Ω(status.Projects).Should(HaveLen(1))
+		Ω(status.Projects[0].Slug).Should(Equal("github/foobar/example"))
+		Ω(status.Projects[0].RefreshedAt).Should(Equal(now))
+		Ω(status.Projects[0].NextRefresh).Should(Equal(now.Add(30 * time.Second)))
+		Ω(status.Projects[0].Err).Should(BeNil())
+	})
+
+	It("leaves refreshing to the leader", func() {
+		var refreshed int
+		refresher.OnRefresh = func(*scheduler.Scheduler) { refreshed++ }
+		refresher.IsLeader = func() bool { return false }
+		stop := make(chan struct{})
+		go refresher.Run(stop)
+		Consistently(func() int { return refreshed }, 200*time.Millisecond).Should(Equal(0))
+		close(stop)
+		circleCIClient.AssertNotCalled(GinkgoT(), "GetAllProjects")
+	})
+
+	Context("with several sources", func() {
+		var failing *mocks.CircleCI
+
+		BeforeEach(func() {
+			circleCIClient.On("WorkflowStatus", mock.Anything, mock.Anything).Return("success", nil)
+			failing = &mocks.CircleCI{}
+			failing.On("GetAllProjects").Return(nil, fmt.Errorf("Error getting projects"))
+		})
+
+		JustBeforeEach(func() {
+			refresher = scheduler.NewForSources([]dashboard.Source{
+				{Name: "github", Provider: dashboard.NewCircleCIProvider(circleCIClient, &filter)},
+				{Name: "server", Provider: dashboard.NewCircleCIProvider(failing, &filter)},
+			}, &dashboard.FeatureFlags{}, &dashboard.MonitorConfig{}, scheduler.Config{IdleInterval: 30 * time.Second})
+			refresher.Now = func() time.Time { return now }
+		})
+
+		It("records the source on each monitor", func() {
+			refresher.Refresh()
+			monitors := refresher.Monitors()
+			Ω(monitors).Should(HaveLen(1))
+			Ω(monitors[0].Source).Should(Equal("github"))
+		})
+
+		It("keeps showing the other sources when one fails", func() {
+			refresher.Refresh()
+			Ω(refresher.Err()).Should(BeNil())
+			Ω(refresher.SourceErrors()).Should(HaveLen(1))
+			Ω(refresher.SourceErrors()[0].Error()).Should(Equal("server: Error getting projects"))
+		})
+
+		It("reports an error when every source fails", func() {
+			circleCIClient.ExpectedCalls = nil
+			circleCIClient.On("GetAllProjects").Return(nil, fmt.Errorf("Error getting projects"))
+			refresher.Refresh()
+			Ω(refresher.Err()).Should(MatchError("Error getting projects"))
+			Ω(refresher.Monitors()).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,124 @@
+// Package sources reads the list of CI systems to show on the dashboard.
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/armakuni/circleci-workflow-dashboard/actions"
+	"github.com/armakuni/circleci-workflow-dashboard/circleci"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/gitlab"
+)
+
+const (
+	TypeCircleCI      = "circleci"
+	TypeGitHubActions = "github-actions"
+	TypeGitLab        = "gitlab"
+)
+
+// Config describes one CircleCI organisation or install, a set of GitHub
+// repositories using GitHub Actions, or a set of GitLab projects, with its own
+// token.
+type Config struct {
+	Name string `json:"name"`
+	// Type is TypeCircleCI, the default, TypeGitHubActions or TypeGitLab.
+	Type    string `json:"type"`
+	APIURL  string `json:"api_url"`
+	JobsURL string `json:"jobs_url"`
+	Token   string `json:"token"`
+	// TokenEnv names an environment variable to read the token from, to keep
+	// it out of the sources themselves.
+	TokenEnv string `json:"token_env"`
+	// Filter selects CircleCI projects.
+	Filter circleci.Filter `json:"filter"`
+	// Repos lists GitHub repositories as owner/repo, or owner/* for all of
+	// an owner's repositories.
+	Repos []string `json:"repos"`
+	// Projects lists GitLab projects by path as group/project, or group/*
+	// for all of a group's projects.
+	Projects []string `json:"projects"`
+}
+
+func (c Config) token() string {
+	if c.TokenEnv != "" {
+		return os.Getenv(c.TokenEnv)
+	}
+	return c.Token
+}
+
+// CircleCIConfig returns the client config of a CircleCI source.
+func (c Config) CircleCIConfig() *circleci.Config {
+	return &circleci.Config{APIURL: c.APIURL, JobsURL: c.JobsURL, APIToken: c.token()}
+}
+
+// Source builds the source's provider. CircleCI API calls are recorded to,
+// or replayed from, a subdirectory of recordDir or replayDir named after the
+// source, when set.
+func (c Config) Source(recordDir, replayDir string) (dashboard.Source, error) {
+	if c.Type == TypeGitLab {
+		client, err := gitlab.NewClient(&gitlab.Config{APIURL: c.APIURL, Token: c.token(), Projects: c.Projects})
+		if err != nil {
+			return dashboard.Source{}, err
+		}
+		return dashboard.Source{Name: c.Name, Provider: client, Stats: dashboard.NewAPIStats().Watch(client.Client)}, nil
+	}
+	if c.Type == TypeGitHubActions {
+		client, err := actions.NewClient(&actions.Config{APIURL: c.APIURL, Token: c.token(), Repos: c.Repos})
+		if err != nil {
+			return dashboard.Source{}, err
+		}
+		return dashboard.Source{Name: c.Name, Provider: client, Stats: dashboard.NewAPIStats().Watch(client.Client)}, nil
+	}
+	config := c.CircleCIConfig()
+	if recordDir != "" {
+		config.RecordDir = filepath.Join(recordDir, c.Name)
+	}
+	if replayDir != "" {
+		config.ReplayDir = filepath.Join(replayDir, c.Name)
+	}
+	client, err := circleci.NewClient(config)
+	if err != nil {
+		return dashboard.Source{}, err
+	}
+	filter := c.Filter
+	return dashboard.Source{Name: c.Name, Provider: dashboard.NewCircleCIProvider(client, &filter), Stats: dashboard.NewAPIStats().Watch(client.Client)}, nil
+}
+
+// Parse reads a JSON list of sources. Each needs a unique name, which is
+// shown on its tiles.
+func Parse(sourcesJSON string) ([]Config, error) {
+	var sources []Config
+	if err := json.Unmarshal([]byte(sourcesJSON), &sources); err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("Must list at least one source")
+	}
+	names := map[string]bool{}
+	for i, source := range sources {
+		if source.Name == "" {
+			return nil, fmt.Errorf("Source %d needs a name", i+1)
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("Source %s is listed twice", source.Name)
+		}
+		names[source.Name] = true
+		switch source.Type {
+		case "":
+			sources[i].Type = TypeCircleCI
+		case TypeCircleCI, TypeGitHubActions, TypeGitLab:
+		default:
+			return nil, fmt.Errorf("Source %s has unknown type %q, must be %s, %s or %s", source.Name, source.Type, TypeCircleCI, TypeGitHubActions, TypeGitLab)
+		}
+		if source.Type == TypeGitHubActions && len(source.Repos) == 0 {
+			return nil, fmt.Errorf("Source %s needs repos", source.Name)
+		}
+		if source.Type == TypeGitLab && len(source.Projects) == 0 {
+			return nil, fmt.Errorf("Source %s needs projects", source.Name)
+		}
+	}
+	return sources, nil
+}
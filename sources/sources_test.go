@@ -0,0 +1,92 @@
+package sources_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/actions"
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/gitlab"
+	"github.com/armakuni/circleci-workflow-dashboard/sources"
+)
+
+var _ = Describe("Sources", func() {
+	Describe("#Parse", func() {
+		It("reads a list of sources", func() {
+			configs, err := sources.Parse(`[
+				{"name": "github", "token": "abc", "filter": {"myorg/*": null}},
+				{"name": "server", "api_url": "https://circleci.example.com", "jobs_url": "https://circleci.example.com", "token_env": "SERVER_TOKEN"},
+				{"name": "actions", "type": "github-actions", "repos": ["myorg/*"]}
+			]`)
+			Ω(err).Should(BeNil())
+			Ω(configs).Should(HaveLen(3))
+			Ω(configs[0].Name).Should(Equal("github"))
+			Ω(configs[0].Type).Should(Equal(sources.TypeCircleCI))
+			Ω(configs[0].Filter).Should(HaveKey("myorg/*"))
+			Ω(configs[1].APIURL).Should(Equal("https://circleci.example.com"))
+			Ω(configs[2].Repos).Should(Equal([]string{"myorg/*"}))
+		})
+
+		It("needs a name for each source", func() {
+			_, err := sources.Parse(`[{"token": "abc"}]`)
+			Ω(err).Should(MatchError("Source 1 needs a name"))
+		})
+
+		It("does not allow the same name twice", func() {
+			_, err := sources.Parse(`[{"name": "github"}, {"name": "github"}]`)
+			Ω(err).Should(MatchError("Source github is listed twice"))
+		})
+
+		It("needs at least one source", func() {
+			_, err := sources.Parse(`[]`)
+			Ω(err).Should(MatchError("Must list at least one source"))
+		})
+
+		It("rejects unknown types", func() {
+			_, err := sources.Parse(`[{"name": "jenkins", "type": "jenkins"}]`)
+			Ω(err).Should(MatchError(`Source jenkins has unknown type "jenkins", must be circleci, github-actions or gitlab`))
+		})
+
+		It("needs repos for GitHub Actions", func() {
+			_, err := sources.Parse(`[{"name": "actions", "type": "github-actions"}]`)
+			Ω(err).Should(MatchError("Source actions needs repos"))
+		})
+
+		It("needs projects for GitLab", func() {
+			_, err := sources.Parse(`[{"name": "gitlab", "type": "gitlab"}]`)
+			Ω(err).Should(MatchError("Source gitlab needs projects"))
+		})
+	})
+
+	Describe("#CircleCIConfig", func() {
+		It("reads the token from the environment when asked to", func() {
+			os.Setenv("SOURCES_TEST_TOKEN", "from-env")
+			defer os.Unsetenv("SOURCES_TEST_TOKEN")
+			config := sources.Config{Token: "inline", TokenEnv: "SOURCES_TEST_TOKEN"}.CircleCIConfig()
+			Ω(config.APIToken).Should(Equal("from-env"))
+		})
+
+		It("uses the inline token otherwise", func() {
+			Ω(sources.Config{Token: "inline"}.CircleCIConfig().APIToken).Should(Equal("inline"))
+		})
+	})
+
+	Describe("#Source", func() {
+		It("builds the provider for the type", func() {
+			source, err := sources.Config{Name: "actions", Type: sources.TypeGitHubActions, Repos: []string{"myorg/example"}}.Source("", "")
+			Ω(err).Should(BeNil())
+			Ω(source.Name).Should(Equal("actions"))
+			Ω(source.Provider).Should(BeAssignableToTypeOf(&actions.Client{}))
+
+			source, err = sources.Config{Name: "circleci", Type: sources.TypeCircleCI, Token: "abc"}.Source("", "")
+			Ω(err).Should(BeNil())
+			Ω(source.Provider).Should(BeAssignableToTypeOf(&dashboard.CircleCIProvider{}))
+
+			source, err = sources.Config{Name: "gitlab", Type: sources.TypeGitLab, Projects: []string{"mygroup/example"}}.Source("", "")
+			Ω(err).Should(BeNil())
+			Ω(source.Provider).Should(BeAssignableToTypeOf(&gitlab.Client{}))
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
+)
+
+var _ = Describe("Logging", func() {
+	var output *bytes.Buffer
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+	})
+
+	lines := func() []map[string]interface{} {
+		var entries []map[string]interface{}
+		for _, line := range bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var entry map[string]interface{}
+			Ω(json.Unmarshal(line, &entry)).Should(Succeed())
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	Describe("#NewLogger", func() {
+		It("logs JSON lines at info and above by default", func() {
+			logger, err := telemetry.NewLogger(output, "")
+			Ω(err).Should(BeNil())
+			logger.Debug("Collected project")
+			logger.Warn("Error collecting project", "project", "github/foobar/example")
+			entries := lines()
+			Ω(entries).Should(HaveLen(1))
+			Ω(entries[0]).Should(HaveKeyWithValue("level", "WARN"))
+			Ω(entries[0]).Should(HaveKeyWithValue("msg", "Error collecting project"))
+			Ω(entries[0]).Should(HaveKeyWithValue("project", "github/foobar/example"))
+		})
+
+		It("logs at the level asked for", func() {
+			logger, err := telemetry.NewLogger(output, "DEBUG")
+			Ω(err).Should(BeNil())
+			logger.Debug("Collected project")
+			Ω(lines()).Should(HaveLen(1))
+		})
+
+		It("rejects an unknown level", func() {
+			_, err := telemetry.NewLogger(output, "verbose")
+			Ω(err).Should(MatchError(`Unknown log level "verbose", must be debug, info, warn or error`))
+		})
+	})
+
+	Describe("#GinLogger", func() {
+		var router *gin.Engine
+
+		BeforeEach(func() {
+			logger, err := telemetry.NewLogger(output, "info")
+			Ω(err).Should(BeNil())
+			gin.SetMode(gin.TestMode)
+			router = gin.New()
+			router.Use(telemetry.GinLogger(logger))
+			router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+			router.GET("/healthz", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+		})
+
+		It("logs each request", func() {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			entries := lines()
+			Ω(entries).Should(HaveLen(1))
+			Ω(entries[0]).Should(HaveKeyWithValue("method", "GET"))
+			Ω(entries[0]).Should(HaveKeyWithValue("path", "/"))
+			Ω(entries[0]).Should(HaveKeyWithValue("status", BeNumerically("==", 200)))
+			Ω(entries[0]).Should(HaveKey("duration"))
+		})
+
+		It("only logs the probes at debug level", func() {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			Ω(lines()).Should(BeEmpty())
+		})
+	})
+})
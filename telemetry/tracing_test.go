@@ -0,0 +1,93 @@
+package telemetry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/armakuni/circleci-workflow-dashboard/telemetry"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		previous trace.TracerProvider
+	)
+
+	BeforeEach(func() {
+		previous = otel.GetTracerProvider()
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	Describe("#SetupTracing", func() {
+		It("does nothing without an exporter", func() {
+			shutdown, err := telemetry.SetupTracing(context.Background(), "")
+			Ω(err).Should(BeNil())
+			Ω(shutdown(context.Background())).Should(Succeed())
+		})
+
+		It("rejects an unknown exporter", func() {
+			_, err := telemetry.SetupTracing(context.Background(), "zipkin")
+			Ω(err).Should(MatchError(`Unknown traces exporter "zipkin", must be none, otlp or console`))
+		})
+	})
+
+	Describe("#Transport", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/missing" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("traces each call as a child of the span it is made in", func() {
+			ctx, parent := telemetry.Tracer().Start(context.Background(), "collect project")
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v2/pipeline?page-token=abc", nil)
+			Ω(err).Should(BeNil())
+			client := &http.Client{Transport: telemetry.Transport(nil)}
+			_, err = client.Do(request)
+			Ω(err).Should(BeNil())
+			parent.End()
+
+			spans := recorder.Ended()
+			Ω(spans).Should(HaveLen(2))
+			call := spans[0]
+			Ω(call.Name()).Should(Equal("HTTP GET"))
+			Ω(call.Parent().SpanID()).Should(Equal(parent.SpanContext().SpanID()))
+			Ω(call.Attributes()).Should(ContainElement(attribute.String("url.path", "/api/v2/pipeline")))
+			Ω(call.Attributes()).Should(ContainElement(attribute.String("url.query", "page-token=abc")))
+			Ω(call.Attributes()).Should(ContainElement(attribute.Int("http.response.status_code", 200)))
+			Ω(call.Status().Code).Should(Equal(codes.Unset))
+		})
+
+		It("marks failed calls as errors", func() {
+			client := &http.Client{Transport: telemetry.Transport(nil)}
+			_, err := client.Get(server.URL + "/missing")
+			Ω(err).Should(BeNil())
+			spans := recorder.Ended()
+			Ω(spans).Should(HaveLen(1))
+			Ω(spans[0].Status().Code).Should(Equal(codes.Error))
+		})
+	})
+})
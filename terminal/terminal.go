@@ -0,0 +1,174 @@
+// Package terminal renders dashboard monitors for the command line.
+package terminal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+const (
+	colourReset  = "\033[0m"
+	colourRed    = "\033[31m"
+	colourGreen  = "\033[32m"
+	colourBlue   = "\033[34m"
+	colourPurple = "\033[35m"
+	colourGrey   = "\033[90m"
+	clearScreen  = "\033[H\033[2J"
+)
+
+// Selector picks monitors using shell-style glob patterns. An empty pattern
+// matches everything.
+type Selector struct {
+	Project  string
+	Workflow string
+	Branch   string
+	Team     string
+}
+
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+func (s Selector) Matches(monitor dashboard.Monitor) bool {
+	return globMatch(s.Project, monitor.Name) &&
+		globMatch(s.Workflow, monitor.Workflow) &&
+		globMatch(s.Branch, monitor.Branch) &&
+		globMatch(s.Team, monitor.Team())
+}
+
+func (s Selector) Select(monitors dashboard.Monitors) dashboard.Monitors {
+	var selected dashboard.Monitors
+	for _, monitor := range monitors {
+		if s.Matches(monitor) {
+			selected = append(selected, monitor)
+		}
+	}
+	return selected
+}
+
+// Failing returns the monitors that show red.
+func Failing(monitors dashboard.Monitors) dashboard.Monitors {
+	var failing dashboard.Monitors
+	for _, monitor := range monitors {
+		if monitor.Failed() {
+			failing = append(failing, monitor)
+		}
+	}
+	return failing
+}
+
+func ValidFormat(format string) bool {
+	return format == FormatTable || format == FormatJSON || format == FormatCSV
+}
+
+// Render writes the monitors in the given format. Colour only applies to
+// tables.
+func Render(w io.Writer, monitors dashboard.Monitors, format string, colour bool) error {
+	switch format {
+	case FormatJSON:
+		if monitors == nil {
+			monitors = dashboard.Monitors{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(monitors)
+	case FormatCSV:
+		return renderCSV(w, monitors)
+	case FormatTable:
+		return renderTable(w, monitors, colour)
+	}
+	return fmt.Errorf("Unknown format %q, must be one of table, json or csv", format)
+}
+
+func renderCSV(w io.Writer, monitors dashboard.Monitors) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"name", "workflow", "branch", "status", "link", "tag", "trigger", "team"}); err != nil {
+		return err
+	}
+	for _, monitor := range monitors {
+		if err := writer.Write([]string{monitor.Name, monitor.Workflow, monitor.Branch, monitor.Status, monitor.Link, monitor.Tag, monitor.Trigger, monitor.Team()}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func statusColour(monitor dashboard.Monitor) string {
+	fields := strings.Fields(monitor.Status)
+	switch {
+	case monitor.Failed():
+		return colourRed
+	case len(fields) > 0 && (fields[0] == "running" || fields[0] == "failing"):
+		return colourBlue
+	case len(fields) > 0 && fields[0] == "on_hold":
+		return colourPurple
+	case len(fields) > 0 && fields[0] == "success":
+		return colourGreen
+	}
+	return colourGrey
+}
+
+func renderTable(w io.Writer, monitors dashboard.Monitors, colour bool) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "STATUS\tPROJECT\tWORKFLOW\tBRANCH")
+	for _, monitor := range monitors {
+		status := monitor.Status
+		if colour {
+			status = statusColour(monitor) + status + colourReset
+		}
+		ref := monitor.Branch
+		if monitor.Tag != "" {
+			ref = monitor.Tag
+		} else if monitor.Trigger != "" {
+			ref = fmt.Sprintf("%s (%s)", monitor.Branch, monitor.Trigger)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", status, monitor.Name, monitor.Workflow, ref)
+	}
+	return table.Flush()
+}
+
+// Watch redraws a table of the selected monitors every interval until stop is
+// closed.
+func Watch(w io.Writer, build func() (dashboard.Monitors, error), selector Selector, interval time.Duration, colour bool, stop <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		monitors, err := build()
+		fmt.Fprint(w, clearScreen)
+		fmt.Fprintf(w, "%s (every %s)\n\n", time.Now().Format("2006-01-02 15:04:05 -0700"), interval)
+		if err != nil {
+			fmt.Fprintf(w, "ERROR: %v\n", err)
+		}
+		// A build can fail for some sources and still have monitors from
+		// the others.
+		if err == nil || len(monitors) > 0 {
+			if err := renderTable(w, selector.Select(monitors), colour); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package terminal_test
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/dashboard"
+	"github.com/armakuni/circleci-workflow-dashboard/terminal"
+)
+
+var _ = Describe("Terminal", func() {
+	var monitors = dashboard.Monitors{
+		{Name: "foobar/example", Workflow: "test", Branch: "master", Status: "success", Link: "https://foobar.com/1"},
+		{Name: "foobar/example", Workflow: "deploy", Branch: "master", Status: "running failed", Link: "https://foobar.com/2"},
+		{Name: "another/example", Workflow: "test", Branch: "develop", Status: "failed", Link: "https://foobar.com/3"},
+	}
+
+	Describe("Selector", func() {
+		It("selects monitors matching every pattern", func() {
+			selector := terminal.Selector{Project: "foobar/*", Branch: "master"}
+			Ω(selector.Select(monitors)).Should(Equal(monitors[:2]))
+		})
+
+		It("selects monitors by their owning team", func() {
+			owned := append(dashboard.Monitors{{Name: "foobar/payments", Owner: &dashboard.Owner{Team: "payments"}}}, monitors...)
+			Ω(terminal.Selector{Team: "pay*"}.Select(owned)).Should(Equal(owned[:1]))
+		})
+
+		It("selects everything when empty", func() {
+			Ω(terminal.Selector{}.Select(monitors)).Should(Equal(monitors))
+		})
+
+		It("selects nothing with an invalid pattern", func() {
+			Ω(terminal.Selector{Workflow: "["}.Select(monitors)).Should(BeEmpty())
+		})
+	})
+
+	Describe("#Failing", func() {
+		It("returns the red monitors", func() {
+			Ω(terminal.Failing(monitors)).Should(Equal(monitors[1:]))
+		})
+	})
+
+	Describe("#Render", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+		})
+
+		It("renders a table", func() {
+			Ω(terminal.Render(out, monitors[:1], terminal.FormatTable, false)).Should(Succeed())
+			Ω(out.String()).Should(Equal("STATUS   PROJECT         WORKFLOW  BRANCH\nsuccess  foobar/example  test      master\n"))
+		})
+
+		It("shows the tag in place of the branch for tag monitors", func() {
+			release := dashboard.Monitors{{Name: "foobar/example", Workflow: "release", Tag: "v1.2.0", Status: "success"}}
+			Ω(terminal.Render(out, release, terminal.FormatTable, false)).Should(Succeed())
+			Ω(out.String()).Should(ContainSubstring("release   v1.2.0"))
+		})
+
+		It("shows the trigger source after the branch when monitors are split by it", func() {
+			nightly := dashboard.Monitors{{Name: "foobar/example", Workflow: "soak", Branch: "master", Trigger: "scheduled", Status: "failed"}}
+			Ω(terminal.Render(out, nightly, terminal.FormatTable, false)).Should(Succeed())
+			Ω(out.String()).Should(ContainSubstring("soak      master (scheduled)"))
+		})
+
+		It("colours the table by status", func() {
+			Ω(terminal.Render(out, monitors, terminal.FormatTable, true)).Should(Succeed())
+			Ω(out.String()).Should(ContainSubstring("\033[32msuccess\033[0m"))
+			Ω(out.String()).Should(ContainSubstring("\033[31mrunning failed\033[0m"))
+		})
+
+		It("renders JSON", func() {
+			Ω(terminal.Render(out, monitors[:1], terminal.FormatJSON, false)).Should(Succeed())
+			Ω(out.String()).Should(MatchJSON(`[{"name": "foobar/example", "workflow": "test", "branch": "master", "status": "success", "link": "https://foobar.com/1"}]`))
+		})
+
+		It("renders an empty JSON list", func() {
+			Ω(terminal.Render(out, nil, terminal.FormatJSON, false)).Should(Succeed())
+			Ω(out.String()).Should(MatchJSON(`[]`))
+		})
+
+		It("renders CSV", func() {
+			Ω(terminal.Render(out, monitors[:1], terminal.FormatCSV, false)).Should(Succeed())
+			Ω(out.String()).Should(Equal("name,workflow,branch,status,link,tag,trigger,team\nfoobar/example,test,master,success,https://foobar.com/1,,,\n"))
+		})
+
+		It("errors on an unknown format", func() {
+			Ω(terminal.Render(out, monitors, "xml", false)).Should(MatchError(`Unknown format "xml", must be one of table, json or csv`))
+		})
+	})
+
+	Describe("#Watch", func() {
+		It("redraws the selected monitors until stopped", func() {
+			out := &bytes.Buffer{}
+			stop := make(chan struct{})
+			builds := 0
+			build := func() (dashboard.Monitors, error) {
+				builds++
+				if builds == 2 {
+					close(stop)
+					return nil, fmt.Errorf("Error getting projects")
+				}
+				return monitors, nil
+			}
+			err := terminal.Watch(out, build, terminal.Selector{Branch: "develop"}, time.Millisecond, false, stop)
+			Ω(err).Should(BeNil())
+			Ω(builds).Should(Equal(2))
+			Ω(out.String()).Should(ContainSubstring("another/example"))
+			Ω(out.String()).ShouldNot(ContainSubstring("foobar/example"))
+			Ω(out.String()).Should(ContainSubstring("ERROR: Error getting projects"))
+		})
+	})
+})
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const SignatureHeader = "circleci-signature"
+
+// maxBodySize is far more than any CircleCI event needs, so that a huge body
+// is turned away before it is read into memory.
+const maxBodySize = 1 << 20
+
+var vcsSlugPrefixes = map[string]string{
+	"gh": "github",
+	"bb": "bitbucket",
+}
+
+var refreshEvents = map[string]interface{}{
+	"workflow-completed": nil,
+	"job-completed":      nil,
+}
+
+// Event is the part of a CircleCI webhook payload needed to find the monitor
+// it affects.
+type Event struct {
+	Type    string `json:"type"`
+	Project struct {
+		Slug string `json:"slug"`
+	} `json:"project"`
+	Pipeline struct {
+		VCS struct {
+			Branch string `json:"branch"`
+		} `json:"vcs"`
+	} `json:"pipeline"`
+}
+
+// ProjectSlug returns the project slug in the same long form as
+// circleci.Project.Slug, e.g. "gh/org/repo" becomes "github/org/repo".
+func (e Event) ProjectSlug() string {
+	parts := strings.SplitN(e.Project.Slug, "/", 2)
+	if prefix, ok := vcsSlugPrefixes[parts[0]]; ok && len(parts) == 2 {
+		return fmt.Sprintf("%s/%s", prefix, parts[1])
+	}
+	return e.Project.Slug
+}
+
+// Refresher is notified of the project and branch affected by each webhook.
+type Refresher interface {
+	RefreshBranch(slug, branch string) (bool, error)
+}
+
+// VerifySignature checks the body against every v1 HMAC-SHA256 signature in
+// the circleci-signature header.
+func VerifySignature(secret string, body []byte, header string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(signature), "=", 2)
+		if len(parts) != 2 || parts[0] != "v1" {
+			continue
+		}
+		actual, err := hex.DecodeString(parts[1])
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(actual, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns a circleci-signature header value for the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return fmt.Sprintf("v1=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Handler verifies CircleCI webhooks and refreshes the affected branch. The
+// refresh happens in the background so CircleCI gets a prompt response.
+func Handler(secret string, refresher Refresher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Body too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+		if !VerifySignature(secret, body, c.GetHeader(SignatureHeader)) {
+			c.AbortWithStatusJSON(401, gin.H{"message": "Invalid signature"})
+			return
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"message": err.Error()})
+			return
+		}
+		if _, ok := refreshEvents[event.Type]; !ok {
+			c.JSON(202, gin.H{"message": "Ignored"})
+			return
+		}
+		go func() {
+			if _, err := refresher.RefreshBranch(event.ProjectSlug(), event.Pipeline.VCS.Branch); err != nil {
+				slog.Error("Error refreshing from a webhook", "project", event.ProjectSlug(), "branch", event.Pipeline.VCS.Branch, "err", err)
+			}
+		}()
+		c.JSON(202, gin.H{"message": "Refreshing"})
+	}
+}
@@ -0,0 +1,148 @@
+package webhook_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/armakuni/circleci-workflow-dashboard/webhook"
+)
+
+const workflowCompleted = `{
+	"type": "workflow-completed",
+	"project": {"slug": "gh/foobar/example"},
+	"pipeline": {"vcs": {"branch": "master"}},
+	"workflow": {"name": "test-workflow", "status": "failed"}
+}`
+
+type refresh struct {
+	slug   string
+	branch string
+}
+
+type fakeRefresher struct {
+	refreshes chan refresh
+}
+
+func (f *fakeRefresher) RefreshBranch(slug, branch string) (bool, error) {
+	f.refreshes <- refresh{slug, branch}
+	return true, nil
+}
+
+var _ = Describe("Webhook", func() {
+	Describe("#VerifySignature", func() {
+		body := []byte(workflowCompleted)
+
+		It("accepts a body signed with the secret", func() {
+			Ω(webhook.VerifySignature("secret", body, webhook.Sign("secret", body))).Should(BeTrue())
+		})
+
+		It("accepts any matching v1 signature in the header", func() {
+			header := "v1=deadbeef," + webhook.Sign("secret", body)
+			Ω(webhook.VerifySignature("secret", body, header)).Should(BeTrue())
+		})
+
+		It("rejects a body signed with another secret", func() {
+			Ω(webhook.VerifySignature("secret", body, webhook.Sign("other", body))).Should(BeFalse())
+		})
+
+		It("rejects a missing or malformed signature", func() {
+			Ω(webhook.VerifySignature("secret", body, "")).Should(BeFalse())
+			Ω(webhook.VerifySignature("secret", body, "v1=not-hex")).Should(BeFalse())
+		})
+	})
+
+	Describe("#ProjectSlug", func() {
+		It("expands the short VCS prefix", func() {
+			var event webhook.Event
+			event.Project.Slug = "bb/foobar/example"
+			Ω(event.ProjectSlug()).Should(Equal("bitbucket/foobar/example"))
+			event.Project.Slug = "circleci/abc/def"
+			Ω(event.ProjectSlug()).Should(Equal("circleci/abc/def"))
+		})
+	})
+
+	Describe("#Handler", func() {
+		var (
+			refresher *fakeRefresher
+			router    *gin.Engine
+			body      string
+			signature string
+			recorder  *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			gin.SetMode(gin.TestMode)
+			refresher = &fakeRefresher{refreshes: make(chan refresh, 1)}
+			router = gin.New()
+			router.POST("/webhooks/circleci", webhook.Handler("secret", refresher))
+			body = workflowCompleted
+			signature = webhook.Sign("secret", []byte(body))
+		})
+
+		JustBeforeEach(func() {
+			recorder = httptest.NewRecorder()
+			request := httptest.NewRequest("POST", "/webhooks/circleci", bytes.NewBufferString(body))
+			request.Header.Set("circleci-signature", signature)
+			router.ServeHTTP(recorder, request)
+		})
+
+		Context("when the signature is valid", func() {
+			It("refreshes the affected project and branch", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusAccepted))
+				Eventually(refresher.refreshes).Should(Receive(Equal(refresh{"github/foobar/example", "master"})))
+			})
+		})
+
+		Context("when the event is not about a completed workflow or job", func() {
+			BeforeEach(func() {
+				body = `{"type": "ping"}`
+				signature = webhook.Sign("secret", []byte(body))
+			})
+
+			It("ignores it", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusAccepted))
+				Consistently(refresher.refreshes).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the signature is invalid", func() {
+			BeforeEach(func() {
+				signature = webhook.Sign("wrong", []byte(body))
+			})
+
+			It("rejects the webhook", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusUnauthorized))
+				Consistently(refresher.refreshes).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the body is too large", func() {
+			BeforeEach(func() {
+				body = strings.Repeat(" ", 1<<20) + workflowCompleted
+				signature = webhook.Sign("secret", []byte(body))
+			})
+
+			It("rejects the webhook", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusRequestEntityTooLarge))
+				Consistently(refresher.refreshes).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the body is not JSON", func() {
+			BeforeEach(func() {
+				body = "[}"
+				signature = webhook.Sign("secret", []byte(body))
+			})
+
+			It("rejects the webhook", func() {
+				Ω(recorder.Code).Should(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+})